@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/auth"
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// minAuthPasswordLength 基本认证密码的最小长度
+const minAuthPasswordLength = 6
+
+// BasicAuthUsersHandler 列出站点或项目的基本认证用户（不返回密码哈希）
+// 参数: ?owner_type=site|project&owner_id=1
+func BasicAuthUsersHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	rows, err := database.GetDB().Query(`SELECT id, owner_type, owner_id, path, username, created_at FROM basic_auth_users
+		WHERE owner_type = ? AND owner_id = ? ORDER BY path, username`, r.URL.Query().Get("owner_type"), ownerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	users := []models.BasicAuthUser{}
+	for rows.Next() {
+		var u models.BasicAuthUser
+		if err := rows.Scan(&u.ID, &u.OwnerType, &u.OwnerID, &u.Path, &u.Username, &u.CreatedAt); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// SaveBasicAuthUserHandler 添加基本认证用户，同一路径下已有同名用户时修改其密码。
+// 密码在服务端生成 bcrypt 哈希，不保存明文
+// 请求体: {"owner_type": "site", "owner_id": 1, "path": "/admin", "username": "alice", "password": "..."}
+func SaveBasicAuthUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		models.BasicAuthUser
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	u := req.BasicAuthUser
+	u.Username = strings.TrimSpace(u.Username)
+
+	path, err := caddyfile.NormalizePath(u.Path)
+	if err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	u.Path = path
+	if len(req.Password) < minAuthPasswordLength {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "基本认证", Option: "password", Value: u.Username,
+			Reason: fmt.Sprintf("密码至少 %d 位", minAuthPasswordLength)})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := checkBasicAuthUser(u, hash); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO basic_auth_users (owner_type, owner_id, path, username, password_hash) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(owner_type, owner_id, path, username) DO UPDATE SET password_hash = excluded.password_hash`,
+		u.OwnerType, u.OwnerID, u.Path, u.Username, hash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "认证用户已保存",
+	})
+}
+
+// DeleteBasicAuthUserHandler 删除基本认证用户
+func DeleteBasicAuthUserHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM basic_auth_users WHERE id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// basicAuthFor 读取站点或项目的认证用户，按路径分组
+func basicAuthFor(q database.Querier, ownerType string, ownerID int) ([]caddyfile.BasicAuth, error) {
+	if ownerID == 0 {
+		return nil, nil
+	}
+	rows, err := q.Query(`SELECT path, username, password_hash FROM basic_auth_users
+		WHERE owner_type = ? AND owner_id = ? ORDER BY path, id`, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []caddyfile.BasicAuth
+	for rows.Next() {
+		var path string
+		var user caddyfile.BasicAuthUser
+		if err := rows.Scan(&path, &user.Username, &user.Hash); err != nil {
+			return nil, err
+		}
+		if len(list) == 0 || list[len(list)-1].Path != path {
+			list = append(list, caddyfile.BasicAuth{Path: path})
+		}
+		list[len(list)-1].Users = append(list[len(list)-1].Users, user)
+	}
+	return list, rows.Err()
+}
+
+// checkBasicAuthUser 将候选用户加入站点或项目的路由后检查整个配置
+func checkBasicAuthUser(u models.BasicAuthUser, hash string) error {
+	user := caddyfile.BasicAuthUser{Username: u.Username, Hash: hash}
+	return checkOwnerRoutes("基本认证", u.OwnerType, u.OwnerID, func(route *caddyfile.Route) {
+		route.BasicAuth = withAuthUser(route.BasicAuth, u.Path, user)
+	})
+}
+
+// checkOwnerRoutes 构建当前配置，对站点或项目的每条路由调用 change 后检查整个配置。
+// 站点按 "站点 #N" 匹配，项目按 "项目 #N " 前缀匹配（每个域名一条路由）
+func checkOwnerRoutes(option, ownerType string, ownerID int, change func(route *caddyfile.Route)) error {
+	var owner string
+	switch ownerType {
+	case "site":
+		owner = fmt.Sprintf("站点 #%d", ownerID)
+	case "project":
+		owner = fmt.Sprintf("项目 #%d ", ownerID)
+	default:
+		return &caddyfile.OptionError{Owner: option, Option: "owner_type", Value: ownerType, Reason: "可选值: site, project"}
+	}
+
+	cfg, err := buildCaddyConfig(database.GetDB())
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i := range cfg.Sites {
+		for j := range cfg.Sites[i].Routes {
+			route := &cfg.Sites[i].Routes[j]
+			if route.Owner != owner && !(ownerType == "project" && strings.HasPrefix(route.Owner, owner)) {
+				continue
+			}
+			found = true
+			change(route)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s不存在或未配置域名", owner)
+	}
+	return cfg.Validate()
+}
+
+// withAuthUser 返回加入（或替换同名）用户后的认证列表，不修改原列表
+func withAuthUser(list []caddyfile.BasicAuth, path string, user caddyfile.BasicAuthUser) []caddyfile.BasicAuth {
+	result := make([]caddyfile.BasicAuth, 0, len(list)+1)
+	added := false
+	for _, auth := range list {
+		if auth.Path == path {
+			users := []caddyfile.BasicAuthUser{}
+			for _, existing := range auth.Users {
+				if existing.Username != user.Username {
+					users = append(users, existing)
+				}
+			}
+			auth.Users = append(users, user)
+			added = true
+		}
+		result = append(result, auth)
+	}
+	if !added {
+		result = append(result, caddyfile.BasicAuth{Path: path, Users: []caddyfile.BasicAuthUser{user}})
+	}
+	return result
+}
@@ -0,0 +1,771 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/certs"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// configMu 保证配置按数据库的最新内容依次应用，先提交的修改不会覆盖后提交的修改
+var configMu sync.Mutex
+
+// generateCaddyfile 根据 sites、projects 表和全局设置生成完整的 Caddyfile，校验后应用
+func generateCaddyfile() error {
+	return applyLatest(nil, nil)
+}
+
+// applyTx 按事务中尚未提交的修改生成 Caddyfile 并校验，通过后提交事务再应用。
+// 未通过校验时回滚事务；caddy validate 之后的加载、重启等耗时操作在提交后进行，不占用数据库写锁。
+// 加载失败时修改已保存，Caddy 恢复到最近一次可用的配置
+func applyTx(tx *sql.Tx) error {
+	defer tx.Rollback()
+	content, env, err := validateTx(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return applyLatest(content, env)
+}
+
+// validateTx 按事务中尚未提交的修改生成 Caddyfile 并校验，不提交事务
+func validateTx(tx *sql.Tx) ([]byte, map[string]string, error) {
+	content, env, err := renderCaddyfile(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := caddy.ValidateConfig(content, env); err != nil {
+		return nil, nil, err
+	}
+	return content, env, nil
+}
+
+// applyFileChange 执行 change 修改配置引用的文件（证书、错误页面、维护模板）后生成并校验 Caddyfile，
+// 未通过校验时把 paths 恢复为修改前的内容，不留下无法生成配置的文件
+func applyFileChange(change func() error, paths ...string) error {
+	type backup struct {
+		data []byte
+		mode os.FileMode
+	}
+	backups := make(map[string]*backup)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			backups[path] = nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		backups[path] = &backup{data: data, mode: info.Mode().Perm()}
+	}
+	restore := func() {
+		for path, b := range backups {
+			if b == nil {
+				os.Remove(path)
+			} else if err := os.WriteFile(path, b.data, b.mode); err != nil {
+				log.Printf("⚠️ 恢复 %s 失败: %v", path, err)
+			}
+		}
+	}
+
+	if err := change(); err != nil {
+		restore()
+		return err
+	}
+	content, env, err := renderCaddyfile(database.GetDB())
+	if err == nil {
+		err = caddy.ValidateConfig(content, env)
+	}
+	if err != nil {
+		restore()
+		return err
+	}
+	return applyLatest(content, env)
+}
+
+// applyLatest 按数据库当前内容生成并应用配置；与 validated 相同的配置已在提交前校验过，不再重复校验
+func applyLatest(validated []byte, validatedEnv map[string]string) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	content, env, err := renderCaddyfile(database.GetDB())
+	if err != nil {
+		return err
+	}
+	if validated != nil && bytes.Equal(content, validated) && reflect.DeepEqual(env, validatedEnv) {
+		return caddy.ApplyValidated(content, env)
+	}
+	return caddy.ApplyConfig(content, env)
+}
+
+// writeCaddyfile 生成并校验 Caddyfile，只写入文件而不重新加载 Caddy
+func writeCaddyfile() error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	content, env, err := renderCaddyfile(database.GetDB())
+	if err != nil {
+		return err
+	}
+	return caddy.WriteConfig(content, env)
+}
+
+// LoadCaddyEnv 按数据库中的设置准备 Caddy 需要的环境变量（DNS 服务商凭据），需在启动 Caddy 前调用
+func LoadCaddyEnv() {
+	cfg, err := buildCaddyConfig(database.GetDB())
+	if err != nil {
+		log.Printf("⚠️ 读取 Caddy 环境变量失败: %v", err)
+		return
+	}
+	caddy.SetEnv(cfg.Env())
+}
+
+// renderCaddyfile 通过 q 读取数据库生成 Caddyfile 及其需要的环境变量，q 为事务时包含尚未提交的修改
+func renderCaddyfile(q database.Querier) ([]byte, map[string]string, error) {
+	cfg, err := buildCaddyConfig(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := caddyfile.Render(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, cfg.Env(), nil
+}
+
+// buildCaddyConfig 从数据库构建 Caddyfile 中间模型
+func buildCaddyConfig(q database.Querier) (*caddyfile.Config, error) {
+	return candidateConfig(q, nil, nil)
+}
+
+// candidateConfig 从数据库构建模型，site、p 不为空时代替数据库中的同一站点、项目（新增时 ID 为 0），
+// 用于在保存前得到修改后的完整配置
+func candidateConfig(q database.Querier, site *models.Site, p *models.Project) (*caddyfile.Config, error) {
+	acme, err := globalACME(q)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &caddyfile.Config{
+		Global: caddyfile.Global{
+			Admin:           config.CaddyAdminAddr,
+			ACME:            acme,
+			TrustedProxies:  trustedProxies(database.GetSettingFrom(q, "trusted_proxies")),
+			ClientIPHeaders: strings.Fields(database.GetSettingFrom(q, "client_ip_headers")),
+		},
+	}
+
+	siteID, projectID := 0, 0
+	if site != nil {
+		siteID = site.ID
+	}
+	if p != nil {
+		projectID = p.ID
+	}
+	if err := addSiteRoutes(q, cfg, siteID); err != nil {
+		return nil, err
+	}
+	if err := addProjectRoutes(q, cfg, projectID); err != nil {
+		return nil, err
+	}
+	if site != nil {
+		if err := addSite(q, cfg, site); err != nil {
+			return nil, err
+		}
+	}
+	if p != nil {
+		if err := addProject(q, cfg, p); err != nil {
+			return nil, err
+		}
+	}
+
+	// 没有任何站点时保留一个默认响应，确保 Caddy 可以正常启动
+	if len(cfg.Sites) == 0 {
+		site := cfg.SiteFor(":80")
+		site.Routes = append(site.Routes, caddyfile.Route{
+			Owner:   "暂无站点和项目，通过管理界面添加后会自动生成配置",
+			Respond: &caddyfile.Respond{Body: "Caddy 正在运行", Status: 200},
+		})
+	}
+
+	return cfg, nil
+}
+
+// addSiteRoutes 将 sites 表中的站点加入模型，excludeID 对应的站点会被跳过
+func addSiteRoutes(q database.Querier, cfg *caddyfile.Config, excludeID int) error {
+	rows, err := q.Query(`SELECT id, domain, type, target, ssl_enabled, COALESCE(tls_mode, ''), COALESCE(ssl_email, ''),
+		COALESCE(acme_ca, ''), COALESCE(acme_ca_url, ''), COALESCE(acme_eab_key_id, ''), COALESCE(acme_eab_hmac_key, ''), COALESCE(acme_ca_root, ''), COALESCE(dns_provider_id, 0),
+		COALESCE(canonical_host, ''), COALESCE(force_https, 1), COALESCE(php_version, ''),
+		COALESCE(precompressed, 1), COALESCE(cache_hashed_assets, 1), COALESCE(redirect_status, 301), COALESCE(redirect_keep_path, 1)
+		FROM sites ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// 域名以 domains 表为准，旧数据中无效或重复、未迁移的域名不写入配置
+	domains, err := loadDomains(q, "site")
+	if err != nil {
+		return err
+	}
+
+	// 先读完站点列表再添加，addSite 还需要查询跳转规则
+	var sites []models.Site
+	for rows.Next() {
+		var row models.Site
+		if err := rows.Scan(&row.ID, &row.Domain, &row.Type, &row.Target, &row.SSLEnabled, &row.TLSMode, &row.SSLEmail,
+			&row.ACMECA, &row.ACMECAURL, &row.ACMEEABKeyID, &row.ACMEEABHMACKey, &row.ACMECARoot, &row.DNSProviderID,
+			&row.CanonicalHost, &row.ForceHTTPS, &row.PHPVersion,
+			&row.Precompressed, &row.CacheHashedAssets, &row.RedirectStatus, &row.RedirectKeepPath); err != nil {
+			return err
+		}
+		if row.ID == excludeID || len(domains[row.ID]) == 0 {
+			continue
+		}
+		row.Domain = domains[row.ID][0]
+		sites = append(sites, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for i := range sites {
+		if err := addSite(q, cfg, &sites[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSite 添加站点的路由和跳转规则；设置了规范域名时内容放在规范域名下，另一种写法整站跳转过去
+func addSite(q database.Querier, cfg *caddyfile.Config, row *models.Site) error {
+	acme, err := acmeFor(q, row.SSLEmail, row.ACMESettings)
+	if err != nil {
+		return err
+	}
+	redirects, err := siteRedirects(q, row.ID)
+	if err != nil {
+		return err
+	}
+	basicAuth, err := basicAuthFor(q, "site", row.ID)
+	if err != nil {
+		return err
+	}
+	ipAccess, err := ipAccessFor(q, "site", row.ID)
+	if err != nil {
+		return err
+	}
+	headers, err := headersFor(q, "site", row.ID)
+	if err != nil {
+		return err
+	}
+	maintenance, err := maintenanceFor(q, "site", row.ID, row.Domain)
+	if err != nil {
+		return err
+	}
+	errorPages, err := errorPagesFor(q, "site", row.ID)
+	if err != nil {
+		return err
+	}
+
+	host := row.Domain
+	from, canonical := caddyfile.CanonicalHost(row.Domain, row.CanonicalHost)
+	if from != "" {
+		host = canonical
+	}
+
+	owner := fmt.Sprintf("站点 #%d", row.ID)
+	tls, err := siteTLS(owner, row, host, acme)
+	if err != nil {
+		return err
+	}
+	route := caddyfile.Route{
+		Owner:       owner,
+		TLS:         tls,
+		Redirects:   redirects,
+		BasicAuth:   basicAuth,
+		IPAccess:    ipAccess,
+		Headers:     headers,
+		Maintenance: maintenance,
+		ErrorPages:  errorPages,
+	}
+	switch row.Type {
+	case "proxy":
+		transport, err := loadUpstreamTransport(q, "site", row.ID)
+		if err != nil {
+			return err
+		}
+		route.Proxy = &caddyfile.ReverseProxy{}
+		if err := applyUpstreamTransport(route.Proxy, []string{row.Target}, transport); err != nil {
+			return err
+		}
+	case "static":
+		route.Static = &caddyfile.FileServer{Root: row.Target}
+	case "spa":
+		route.SPA = &caddyfile.SPA{Root: row.Target, Precompressed: row.Precompressed, CacheHashedAssets: row.CacheHashedAssets}
+	case "redirect":
+		// 跳转规则中更具体的路径优先，其余请求整站跳转
+		to := row.Target
+		if row.RedirectKeepPath {
+			to = strings.TrimRight(to, "/") + "{uri}"
+		}
+		route.Redirects = append(route.Redirects, caddyfile.Redirect{To: to, Status: row.RedirectStatus})
+	case "php":
+		upstreams, err := phpUpstreamsFor(q, row.PHPVersion)
+		if err != nil {
+			return err
+		}
+		route.PHP = &caddyfile.PHPFastCGI{Root: row.Target, Upstreams: upstreams}
+	default:
+		return nil
+	}
+
+	site := cfg.SiteFor(host)
+	site.Routes = append(site.Routes, route)
+
+	if from != "" {
+		scheme := "https"
+		if route.TLS != nil && route.TLS.Mode == "off" {
+			scheme = "http"
+		}
+		aliasTLS, err := siteTLS(owner, row, from, acme)
+		if err != nil {
+			return err
+		}
+		alias := cfg.SiteFor(from)
+		alias.Routes = append(alias.Routes, caddyfile.Route{
+			Owner:     owner + " 规范域名",
+			TLS:       aliasTLS,
+			Redirects: []caddyfile.Redirect{{To: scheme + "://" + canonical + "{uri}", Status: 301}},
+		})
+	}
+	return nil
+}
+
+// siteTLS 返回站点的 HTTPS 设置，关闭强制 HTTPS 时同时提供 HTTP 访问
+func siteTLS(owner string, row *models.Site, host string, acme caddyfile.ACME) (*caddyfile.TLS, error) {
+	tls, err := tlsFor(owner, row.TLSMode, row.SSLEnabled, host, acme)
+	if err != nil || row.ForceHTTPS || (tls != nil && tls.Mode == "off") {
+		return tls, err
+	}
+	if tls == nil {
+		return &caddyfile.TLS{AllowHTTP: true}, nil
+	}
+	allow := *tls
+	allow.AllowHTTP = true
+	return &allow, nil
+}
+
+// siteRedirects 读取站点的跳转规则
+func siteRedirects(q database.Querier, siteID int) ([]caddyfile.Redirect, error) {
+	if siteID == 0 {
+		return nil, nil
+	}
+	rules, err := loadRedirectRules(q, siteID)
+	if err != nil {
+		return nil, err
+	}
+	return redirectsFor(rules), nil
+}
+
+// redirectsFor 将跳转规则转换为模型，未设置状态码时使用 301
+func redirectsFor(rules []models.RedirectRule) []caddyfile.Redirect {
+	var redirects []caddyfile.Redirect
+	for _, rule := range rules {
+		status := rule.Status
+		if status == 0 {
+			status = 301
+		}
+		redirects = append(redirects, caddyfile.Redirect{From: strings.TrimSpace(rule.Source), To: strings.TrimSpace(rule.Target), Status: status})
+	}
+	return redirects
+}
+
+// addProjectRoutes 将 projects 表中配置了域名的项目加入模型，excludeID 对应的项目会被跳过
+func addProjectRoutes(q database.Querier, cfg *caddyfile.Config, excludeID int) error {
+	rows, err := q.Query(`SELECT id, name, domains, port, extra_headers, COALESCE(use_ipv4, 1), reverse_proxy_path, COALESCE(strip_path_prefix, 0),
+		COALESCE(instances, 1), COALESCE(lb_policy, ''), COALESCE(lb_retries, 0), COALESCE(lb_try_duration, ''), COALESCE(health_uri, ''), COALESCE(health_interval, ''),
+		ssl_enabled, COALESCE(tls_mode, ''), COALESCE(ssl_email, ''),
+		COALESCE(acme_ca, ''), COALESCE(acme_ca_url, ''), COALESCE(acme_eab_key_id, ''), COALESCE(acme_eab_hmac_key, ''), COALESCE(acme_ca_root, ''), COALESCE(dns_provider_id, 0)
+		FROM projects ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	domains, err := loadDomains(q, "project")
+	if err != nil {
+		return err
+	}
+
+	// 先读完项目列表再添加，addProject 还需要查询认证用户
+	var projects []models.Project
+	for rows.Next() {
+		var p models.Project
+		var extraHeaders, proxyPath *string
+		if err := rows.Scan(&p.ID, &p.Name, &p.Domains, &p.Port, &extraHeaders, &p.UseIPv4, &proxyPath, &p.StripPathPrefix,
+			&p.Instances, &p.LBPolicy, &p.LBRetries, &p.LBTryDuration, &p.HealthURI, &p.HealthInterval,
+			&p.SSLEnabled, &p.TLSMode, &p.SSLEmail,
+			&p.ACMECA, &p.ACMECAURL, &p.ACMEEABKeyID, &p.ACMEEABHMACKey, &p.ACMECARoot, &p.DNSProviderID); err != nil {
+			return err
+		}
+		if p.ID == excludeID || len(domains[p.ID]) == 0 {
+			continue
+		}
+		p.Domains = strings.Join(domains[p.ID], "\n")
+		if extraHeaders != nil {
+			p.ExtraHeaders = *extraHeaders
+		}
+		if proxyPath != nil {
+			p.ReverseProxyPath = *proxyPath
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for i := range projects {
+		if err := addProject(q, cfg, &projects[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addProject 为项目的每个域名添加一条反向代理路由，设置了路径时与同域名的其他站点、项目共用站点块
+func addProject(q database.Querier, cfg *caddyfile.Config, p *models.Project) error {
+	path, err := caddyfile.NormalizePath(p.ReverseProxyPath)
+	if err != nil {
+		return err
+	}
+	acme, err := acmeFor(q, p.SSLEmail, p.ACMESettings)
+	if err != nil {
+		return err
+	}
+	basicAuth, err := basicAuthFor(q, "project", p.ID)
+	if err != nil {
+		return err
+	}
+	ipAccess, err := ipAccessFor(q, "project", p.ID)
+	if err != nil {
+		return err
+	}
+	headers, err := headersFor(q, "project", p.ID)
+	if err != nil {
+		return err
+	}
+	maintenance, err := maintenanceFor(q, "project", p.ID, p.Name)
+	if err != nil {
+		return err
+	}
+	errorPages, err := errorPagesFor(q, "project", p.ID)
+	if err != nil {
+		return err
+	}
+
+	transport, err := loadUpstreamTransport(q, "project", p.ID)
+	if err != nil {
+		return err
+	}
+	var host string
+	if transport != nil {
+		host = transport.Host
+	}
+	upstreams, err := projectUpstreams(p, host)
+	if err != nil {
+		return err
+	}
+	proxy := &caddyfile.ReverseProxy{
+		LBPolicy:       p.LBPolicy,
+		LBRetries:      p.LBRetries,
+		LBTryDuration:  p.LBTryDuration,
+		HealthURI:      p.HealthURI,
+		HealthInterval: p.HealthInterval,
+	}
+	if err := applyUpstreamTransport(proxy, upstreams, transport); err != nil {
+		return err
+	}
+	for _, header := range strings.Split(p.ExtraHeaders, "\n") {
+		if header = strings.TrimSpace(header); header != "" {
+			proxy.HeaderUp = append(proxy.HeaderUp, header)
+		}
+	}
+
+	domains, err := normalizeDomains(p.Domains)
+	if err != nil {
+		return err
+	}
+	owner := fmt.Sprintf("项目 #%d %s", p.ID, p.Name)
+	for _, domain := range domains {
+		tls, err := tlsFor(owner, p.TLSMode, p.SSLEnabled, domain, acme)
+		if err != nil {
+			return err
+		}
+		site := cfg.SiteFor(domain)
+		site.Routes = append(site.Routes, caddyfile.Route{
+			Owner:       owner,
+			Path:        path,
+			StripPrefix: p.StripPathPrefix && path != "",
+			TLS:         tls,
+			Proxy:       proxy,
+			BasicAuth:   basicAuth,
+			IPAccess:    ipAccess,
+			Headers:     headers,
+			Maintenance: maintenance,
+			ErrorPages:  errorPages,
+		})
+	}
+
+	return nil
+}
+
+// tlsFor 根据 HTTPS 设置返回路由的 TLS 配置，nil 表示使用全局设置自动申请证书。
+// 自动模式下，未启用 SSL 的站点只提供 HTTP，局域网主机名使用 Caddy 内部 CA，
+// 设置了单独的 ACME 选项时写入站点的 tls 选项。
+// 使用上传证书但证书文件不存在时返回错误，而不是改为自动申请
+func tlsFor(owner, mode string, sslEnabled bool, domain string, acme caddyfile.ACME) (*caddyfile.TLS, error) {
+	if mode == "" || mode == "auto" {
+		switch {
+		case !sslEnabled:
+			mode = "off"
+		case certs.IsInternalHost(domain):
+			mode = "internal"
+		case !acme.Empty():
+			return &caddyfile.TLS{Mode: "acme", ACME: acme}, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	switch mode {
+	case "custom":
+		if !certs.Exists(domain) {
+			return nil, &caddyfile.OptionError{Owner: owner, Option: "tls_mode", Value: mode,
+				Reason: "域名 " + domain + " 的证书不存在，请重新上传证书或改用其他 HTTPS 模式"}
+		}
+		certFile, keyFile := certs.Paths(domain)
+		return &caddyfile.TLS{Mode: "custom", CertFile: certFile, KeyFile: keyFile}, nil
+	case "internal", "off":
+		return &caddyfile.TLS{Mode: mode}, nil
+	}
+	return nil, nil
+}
+
+// tlsModes 可选的 HTTPS 模式
+var tlsModes = []string{"", "auto", "internal", "custom", "off"}
+
+// globalACME 读取全局 ACME 设置
+func globalACME(q database.Querier) (caddyfile.ACME, error) {
+	settings := models.ACMESettings{
+		ACMECA:         database.GetSettingFrom(q, "acme_ca"),
+		ACMECAURL:      database.GetSettingFrom(q, "acme_ca_url"),
+		ACMEEABKeyID:   database.GetSettingFrom(q, "acme_eab_key_id"),
+		ACMEEABHMACKey: database.GetSettingFrom(q, "acme_eab_hmac_key"),
+		ACMECARoot:     database.GetSettingFrom(q, "acme_ca_root"),
+	}
+	return acmeFor(q, database.GetSettingFrom(q, "acme_email"), settings)
+}
+
+// acmeFor 将 ACME 设置转换为模型，CA 选项解析为目录地址，选择了 DNS 服务商时使用 DNS 验证
+func acmeFor(q database.Querier, email string, s models.ACMESettings) (caddyfile.ACME, error) {
+	dir, err := caddyfile.ACMEDirectory(s.ACMECA, s.ACMECAURL)
+	if err != nil {
+		return caddyfile.ACME{}, err
+	}
+	acme := caddyfile.ACME{
+		Email:     strings.TrimSpace(email),
+		CA:        dir,
+		CARoot:    strings.TrimSpace(s.ACMECARoot),
+		EABKeyID:  strings.TrimSpace(s.ACMEEABKeyID),
+		EABMACKey: strings.TrimSpace(s.ACMEEABHMACKey),
+	}
+	if s.DNSProviderID > 0 {
+		provider, err := getDNSProvider(q, s.DNSProviderID)
+		if err != nil {
+			return caddyfile.ACME{}, &caddyfile.OptionError{Owner: "ACME 设置", Option: "dns_provider_id", Value: fmt.Sprint(s.DNSProviderID), Reason: "DNS 服务商不存在"}
+		}
+		if acme.DNS, err = caddyfile.NewDNSChallenge(provider.Provider, caddyfile.DNSEnvPrefix(provider.ID), provider.Credentials); err != nil {
+			return caddyfile.ACME{}, err
+		}
+	}
+	return acme, nil
+}
+
+// checkACME 检查 ACME 设置，私有 CA 根证书文件必须存在，DNS 验证要求 Caddy 包含服务商模块
+func checkACME(owner, email string, s models.ACMESettings) error {
+	acme, err := acmeFor(database.GetDB(), email, s)
+	if err != nil {
+		return err
+	}
+	if acme.DNS != nil {
+		spec := caddyfile.DNSProviders[acme.DNS.Provider]
+		if err := caddy.RequireModule(spec.Module, spec.Package); err != nil {
+			return err
+		}
+	}
+	if acme.CARoot != "" {
+		if _, err := os.Stat(acme.CARoot); err != nil {
+			return &caddyfile.OptionError{Owner: owner, Option: "acme_ca_root", Value: acme.CARoot, Reason: "根证书文件不存在"}
+		}
+	}
+	cfg := &caddyfile.Config{Global: caddyfile.Global{ACME: acme}}
+	return cfg.Validate()
+}
+
+// checkTLSMode 检查 HTTPS 模式取值，使用上传证书时要求每个域名都已上传证书
+func checkTLSMode(owner, mode string, domains []string) error {
+	valid := false
+	for _, m := range tlsModes {
+		if mode == m {
+			valid = true
+		}
+	}
+	if !valid {
+		return &caddyfile.OptionError{Owner: owner, Option: "tls_mode", Value: mode, Reason: "可选值: auto, internal, custom, off"}
+	}
+
+	if mode == "custom" {
+		for _, domain := range domains {
+			if !certs.Exists(domain) {
+				return &caddyfile.OptionError{Owner: owner, Option: "tls_mode", Value: mode, Reason: "域名 " + domain + " 尚未上传证书"}
+			}
+		}
+	}
+	return nil
+}
+
+// checkSiteRoutes 在保存站点前检查其域名和 HTTPS 设置是否与已有站点、项目冲突，并把域名改写为规范形式；
+// 最后用 caddy validate 校验修改后的完整配置，Caddy 不接受的站点不会写入数据库
+func checkSiteRoutes(site *models.Site) error {
+	owner := "站点"
+	if site.ID > 0 {
+		owner = fmt.Sprintf("站点 #%d", site.ID)
+	}
+	domain, err := caddyfile.NormalizeHost(site.Domain)
+	if err != nil {
+		return err
+	}
+	site.Domain = domain
+	if err := checkTLSMode(owner, site.TLSMode, []string{site.Domain}); err != nil {
+		return err
+	}
+	if err := checkACME(owner, site.SSLEmail, site.ACMESettings); err != nil {
+		return err
+	}
+	if err := checkSiteType(owner, site); err != nil {
+		return err
+	}
+	if site.CanonicalHost != "" {
+		if from, _ := caddyfile.CanonicalHost(site.Domain, site.CanonicalHost); from == "" {
+			return &caddyfile.OptionError{Owner: owner, Option: "canonical_host", Value: site.CanonicalHost, Reason: "可选值: www, apex，且域名不能是通配符、IP 或单级主机名"}
+		}
+	}
+
+	cfg, err := candidateConfig(database.GetDB(), site, nil)
+	if err != nil {
+		return err
+	}
+	return validateCandidate(cfg)
+}
+
+// siteTypes 站点类型：静态文件、反向代理、PHP、单页应用和整站跳转
+var siteTypes = []string{"static", "proxy", "php", "spa", "redirect"}
+
+// checkSiteType 检查站点类型和对应的目标：单页应用的目录中需要有 index.html（目录尚不存在时允许先添加），
+// 跳转站点的目标应为其他主机的完整地址
+func checkSiteType(owner string, site *models.Site) error {
+	valid := false
+	for _, t := range siteTypes {
+		valid = valid || t == site.Type
+	}
+	if !valid {
+		return &caddyfile.OptionError{Owner: owner, Option: "type", Value: site.Type, Reason: "可选值: " + strings.Join(siteTypes, ", ")}
+	}
+	site.Target = strings.TrimSpace(site.Target)
+	if site.Target == "" {
+		return &caddyfile.OptionError{Owner: owner, Option: "target", Reason: "目标不能为空"}
+	}
+
+	switch site.Type {
+	case "spa":
+		if info, err := os.Stat(site.Target); err == nil {
+			if !info.IsDir() {
+				return &caddyfile.OptionError{Owner: owner, Option: "target", Value: site.Target, Reason: "应为单页应用构建输出的目录"}
+			}
+			if _, err := os.Stat(filepath.Join(site.Target, "index.html")); err != nil {
+				return &caddyfile.OptionError{Owner: owner, Option: "target", Value: site.Target, Reason: "目录中没有 index.html，请选择构建输出目录（如 dist）"}
+			}
+		}
+	case "redirect":
+		u, err := url.Parse(site.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(site.Target, " \t{}") {
+			return &caddyfile.OptionError{Owner: owner, Option: "target", Value: site.Target, Reason: "应为完整地址，如 https://example.com"}
+		}
+		if host, _ := caddyfile.NormalizeHost(u.Host); host == site.Domain {
+			return &caddyfile.OptionError{Owner: owner, Option: "target", Value: site.Target, Reason: "跳转目标不能是站点自身的域名"}
+		}
+		valid := false
+		for _, status := range caddyfile.RedirectStatuses {
+			valid = valid || status == site.RedirectStatus
+		}
+		if !valid {
+			return &caddyfile.OptionError{Owner: owner, Option: "redirect_status", Value: strconv.Itoa(site.RedirectStatus), Reason: "可选值: 301, 302, 307, 308"}
+		}
+	}
+	return nil
+}
+
+// checkProjectRoutes 在保存项目前检查其域名和路径是否与已有站点、项目冲突，并把域名改写为规范形式（每行一个）；
+// 与 checkSiteRoutes 一样在写入数据库前校验完整配置
+func checkProjectRoutes(p *models.Project) error {
+	domains, err := normalizeDomains(p.Domains)
+	if err != nil {
+		return err
+	}
+	p.Domains = strings.Join(domains, "\n")
+	owner := fmt.Sprintf("项目 %s", p.Name)
+	if err := checkTLSMode(owner, p.TLSMode, domains); err != nil {
+		return err
+	}
+	if err := checkACME(owner, p.SSLEmail, p.ACMESettings); err != nil {
+		return err
+	}
+
+	cfg, err := candidateConfig(database.GetDB(), nil, p)
+	if err != nil {
+		return err
+	}
+	return validateCandidate(cfg)
+}
+
+// validateCandidate 渲染候选配置并用 caddy validate 校验，不影响正在使用的配置
+func validateCandidate(cfg *caddyfile.Config) error {
+	content, err := caddyfile.Render(cfg)
+	if err != nil {
+		return err
+	}
+	return caddy.ValidateConfig(content, cfg.Env())
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+)
+
+// stubCaddy 使用 sh 脚本模拟 caddy：配置含 reject.example.com 时校验失败，管理 API 总是接受配置
+func stubCaddy(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 脚本模拟 caddy")
+	}
+	config.CaddyDir = t.TempDir()
+	config.CaddyConfig = filepath.Join(config.CaddyDir, "Caddyfile")
+	config.CaddyBin = filepath.Join(config.CaddyDir, "caddy")
+	script := `#!/bin/sh
+case "$1" in
+validate) if grep -q reject.example.com "$3"; then echo "Error: rejected"; exit 1; fi ;;
+adapt) echo '{}' ;;
+esac
+`
+	if err := os.WriteFile(config.CaddyBin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+	config.CaddyAdminAddr = strings.TrimPrefix(srv.URL, "http://")
+}
+
+func countSites(t *testing.T, domain string) int {
+	t.Helper()
+	var n int
+	if err := database.GetDB().QueryRow("SELECT COUNT(*) FROM sites WHERE domain = ?", domain).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func addSiteTx(t *testing.T, domain string) error {
+	t.Helper()
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tx.Exec("INSERT INTO sites (domain, type, target) VALUES (?, 'proxy', 'localhost:8080')", domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	if _, err := tx.Exec("INSERT INTO domains (host, owner_type, owner_id) VALUES (?, 'site', ?)", domain, id); err != nil {
+		t.Fatal(err)
+	}
+	return applyTx(tx)
+}
+
+func TestApplyTx(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+
+	// 配置应用成功后才提交，生成的配置包含未提交时写入的站点
+	if err := addSiteTx(t, "accept.example.com"); err != nil {
+		t.Fatalf("applyTx = %v", err)
+	}
+	if n := countSites(t, "accept.example.com"); n != 1 {
+		t.Errorf("站点数 = %d; 期望 1", n)
+	}
+	data, err := os.ReadFile(config.CaddyConfig)
+	if err != nil || !strings.Contains(string(data), "accept.example.com") {
+		t.Errorf("配置 = %q, err = %v", data, err)
+	}
+
+	// 配置校验失败时回滚，数据库和配置文件都保持原样
+	var validationErr *caddy.ValidationError
+	if err := addSiteTx(t, "reject.example.com"); !errors.As(err, &validationErr) {
+		t.Errorf("applyTx = %v; 期望 ValidationError", err)
+	}
+	if n := countSites(t, "reject.example.com"); n != 0 {
+		t.Errorf("校验失败的站点已保存: %d", n)
+	}
+	if after, _ := os.ReadFile(config.CaddyConfig); string(after) != string(data) {
+		t.Errorf("校验失败后配置被修改: %q", after)
+	}
+}
+
+func TestApplyTxReleasesLock(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+
+	// 加载配置时其他写入（如项目重启事件）不被事务阻塞；加载失败时修改已保存，配置恢复为上一次可用的版本
+	var writeErr error
+	reject := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeErr = database.SetSetting("during_load", "1")
+		if reject {
+			// 只拒绝新配置，恢复的配置正常加载
+			reject = false
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"loading new config: bind: address already in use"}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	config.CaddyAdminAddr = strings.TrimPrefix(srv.URL, "http://")
+
+	if err := addSiteTx(t, "accept.example.com"); err != nil {
+		t.Fatalf("applyTx = %v", err)
+	}
+	if writeErr != nil {
+		t.Errorf("加载配置期间写入数据库失败: %v", writeErr)
+	}
+	good, _ := os.ReadFile(config.CaddyConfig)
+
+	reject = true
+	var reloadErr *caddy.ReloadError
+	if err := addSiteTx(t, "second.example.com"); !errors.As(err, &reloadErr) || !reloadErr.RolledBack {
+		t.Errorf("applyTx = %v; 期望已恢复的 ReloadError", err)
+	}
+	if n := countSites(t, "second.example.com"); n != 1 {
+		t.Errorf("站点数 = %d; 期望修改已保存", n)
+	}
+	if after, _ := os.ReadFile(config.CaddyConfig); string(after) != string(good) {
+		t.Errorf("加载失败后配置 = %q; 期望恢复为 %q", after, good)
+	}
+}
+
+func TestDeleteSiteHandler(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+	db := database.GetDB()
+
+	if err := addSiteTx(t, "accept.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	db.QueryRow("SELECT id FROM sites WHERE domain = 'accept.example.com'").Scan(&id)
+	for _, stmt := range []string{
+		"INSERT INTO redirect_rules (site_id, source, target) VALUES (?, '/old', '/new')",
+		"INSERT INTO header_rules (owner_type, owner_id, direction, operation, name, value) VALUES ('site', ?, 'response', 'set', 'X-Test', '1')",
+	} {
+		if _, err := db.Exec(stmt, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dependents := func() int {
+		var n int
+		for _, table := range []string{"sites WHERE id = ?", "domains WHERE owner_type = 'site' AND owner_id = ?",
+			"redirect_rules WHERE site_id = ?", "header_rules WHERE owner_type = 'site' AND owner_id = ?"} {
+			var c int
+			if err := db.QueryRow("SELECT COUNT(*) FROM "+table, id).Scan(&c); err != nil {
+				t.Fatal(err)
+			}
+			n += c
+		}
+		return n
+	}
+	deleteSite := func() int {
+		rec := httptest.NewRecorder()
+		DeleteSiteHandler(rec, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/sites?id=%d", id), nil))
+		return rec.Code
+	}
+
+	// Caddy 未安装时不校验，写入一份校验会失败的配置；之后删除站点时新配置校验失败，全部回滚
+	bin := config.CaddyBin
+	os.Rename(bin, bin+".bak")
+	if err := addSiteTx(t, "reject.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	os.Rename(bin+".bak", bin)
+	if code := deleteSite(); code == http.StatusOK {
+		t.Errorf("配置校验失败时删除返回 %d", code)
+	}
+	if n := dependents(); n != 4 {
+		t.Errorf("删除失败后剩余 %d 行; 期望 4", n)
+	}
+
+	// 校验通过时站点和依附的设置一起删除
+	db.Exec("DELETE FROM domains WHERE host = 'reject.example.com'")
+	if code := deleteSite(); code != http.StatusOK {
+		t.Fatalf("删除返回 %d", code)
+	}
+	if n := dependents(); n != 0 {
+		t.Errorf("删除后剩余 %d 行; 期望 0", n)
+	}
+}
+
+func TestEditUnknownOwner(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+	db := database.GetDB()
+	countDomains := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM domains").Scan(&n)
+		return n
+	}
+
+	// 不存在的站点和项目返回 404，不留下无主的域名记录
+	rec := httptest.NewRecorder()
+	EditSiteHandler(rec, httptest.NewRequest(http.MethodPost, "/api/sites/edit",
+		strings.NewReader(`{"id": 42, "domain": "ghost.example.com", "type": "proxy", "target": "localhost:8080"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("修改不存在的站点返回 %d; 期望 404", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	UpdateProjectHandler(rec, httptest.NewRequest(http.MethodPost, "/api/projects/update",
+		strings.NewReader(`{"id": 42, "name": "ghost", "port": 3000, "domains": "ghost-app.example.com"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("修改不存在的项目返回 %d; 期望 404", rec.Code)
+	}
+	if n := countDomains(); n != 0 {
+		t.Errorf("剩余 %d 个域名; 期望 0", n)
+	}
+
+	// 旧版本留下的无主域名在启动迁移时清理，域名可以重新使用
+	if err := addSiteTx(t, "kept.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("INSERT INTO domains (host, owner_type, owner_id) VALUES ('ghost.example.com', 'site', 42), ('ghost-app.example.com', 'project', 42)")
+	MigrateDomains()
+	if n := countDomains(); n != 1 {
+		t.Errorf("清理后剩余 %d 个域名; 期望 1", n)
+	}
+	if err := addSiteTx(t, "ghost.example.com"); err != nil {
+		t.Errorf("清理后添加站点失败: %v", err)
+	}
+}
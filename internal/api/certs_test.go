@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+
+	"caddy-manager/internal/database"
+)
+
+func TestCertInUse(t *testing.T) {
+	testDB(t)
+	db := database.GetDB()
+	for _, query := range []string{
+		"INSERT INTO sites (domain, type, target, tls_mode) VALUES ('xn--fsqu00a.xn--fiqs8s', 'proxy', 'localhost:8080', 'custom')",
+		"INSERT INTO domains (host, owner_type, owner_id) VALUES ('xn--fsqu00a.xn--fiqs8s', 'site', 1)",
+		"INSERT INTO projects (name, project_type, root_dir, port, tls_mode) VALUES ('app', 'custom', '/srv/app', 3000, 'custom')",
+		"INSERT INTO domains (host, path, owner_type, owner_id) VALUES ('app.example.com', '/api', 'project', 1)",
+		"INSERT INTO sites (domain, type, target) VALUES ('auto.example.com', 'proxy', 'localhost:8080')",
+		"INSERT INTO domains (host, owner_type, owner_id) VALUES ('auto.example.com', 'site', 2)",
+		"INSERT INTO sites (domain, type, target, tls_mode) VALUES ('[2001:db8::1]:8443', 'proxy', 'localhost:8080', 'custom')",
+		"INSERT INTO domains (host, owner_type, owner_id) VALUES ('[2001:db8::1]:8443', 'site', 3)",
+		"INSERT INTO domains (host, owner_type, owner_id) VALUES ('port.example.com:8443', 'site', 3)",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 按规范化后的主机名比较：国际化域名、大写和末尾的点都视为同一域名
+	for _, tt := range []struct {
+		domain string
+		owner  string
+		used   bool
+	}{
+		{"例子.中国", " 站点 #1 ", true},
+		{"XN--FSQU00A.xn--fiqs8s.", " 站点 #1 ", true},
+		{"App.Example.com.", " 项目 #1 app ", true},
+		{"auto.example.com", "", false},
+		{"other.example.com", "", false},
+		{"invalid domain", "", false},
+		{"2001:db8::1", " 站点 #3 ", true},
+		{"[2001:db8::1]:443", " 站点 #3 ", true},
+		{"port.example.com", " 站点 #3 ", true},
+		{"port.example", "", false},
+	} {
+		owner, used, err := certInUse(tt.domain)
+		if err != nil || used != tt.used || owner != tt.owner {
+			t.Errorf("certInUse(%q) = %q, %v, %v; 期望 %q, %v", tt.domain, owner, used, err, tt.owner, tt.used)
+		}
+	}
+}
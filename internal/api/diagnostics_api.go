@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
 	"caddy-manager/internal/diagnostics"
 	"caddy-manager/internal/system"
 )
@@ -24,15 +26,48 @@ func CheckSSLHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	issues := diagnostics.CheckSSLIssues(domain)
+	status, issues := diagnostics.CheckSSL(domain, httpsRedirectExpected(domain))
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"domain": domain,
+		"tls":    status,
 		"issues": issues,
 	})
 }
 
+// httpsRedirectExpected 域名所属的站点、项目是否把 HTTP 跳转到 HTTPS：关闭 HTTPS 或关闭强制跳转时为 false，
+// 不属于任何站点、项目时按 Caddy 的默认行为返回 true
+func httpsRedirectExpected(domain string) bool {
+	host, err := caddyfile.NormalizeHost(domain)
+	if err != nil {
+		return true
+	}
+	db := database.GetDB()
+	var ownerType string
+	var ownerID int
+	if err := db.QueryRow("SELECT owner_type, owner_id FROM domains WHERE host=? ORDER BY id LIMIT 1", host).Scan(&ownerType, &ownerID); err != nil {
+		return true
+	}
+
+	var mode string
+	var sslEnabled bool
+	forceHTTPS := true
+	if ownerType == "site" {
+		err = db.QueryRow("SELECT COALESCE(tls_mode, ''), ssl_enabled, COALESCE(force_https, 1) FROM sites WHERE id=?", ownerID).
+			Scan(&mode, &sslEnabled, &forceHTTPS)
+	} else {
+		err = db.QueryRow("SELECT COALESCE(tls_mode, ''), ssl_enabled FROM projects WHERE id=?", ownerID).Scan(&mode, &sslEnabled)
+	}
+	if err != nil {
+		return true
+	}
+	if mode == "off" || ((mode == "" || mode == "auto") && !sslEnabled) {
+		return false
+	}
+	return forceHTTPS
+}
+
 // AutoFixHandler 自动修复问题
 func AutoFixHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -0,0 +1,476 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// DNSProvidersHandler 列出已保存的 DNS 服务商（凭据已隐藏）和支持的服务商类型
+func DNSProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	rows, err := db.Query("SELECT id, name, provider, credentials, created_at FROM dns_providers ORDER BY id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	providers := []models.DNSProvider{}
+	for rows.Next() {
+		var p models.DNSProvider
+		var credentials string
+		if err := rows.Scan(&p.ID, &p.Name, &p.Provider, &credentials, &p.CreatedAt); err != nil {
+			continue
+		}
+		// 无法解密时不返回任何凭据字段
+		p.Credentials, _ = decodeCredentials(credentials)
+		p.Credentials = maskCredentials(p.Provider, p.Credentials)
+		providers = append(providers, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": providers,
+		"types":     caddyfile.DNSProviders,
+	})
+}
+
+// SaveDNSProviderHandler 添加或修改 DNS 服务商，凭据为占位符时保留原值
+func SaveDNSProviderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var p models.DNSProvider
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		p.Name = p.Provider
+	}
+	if err := resolveCredentials(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if _, err := caddyfile.NewDNSChallenge(p.Provider, caddyfile.DNSEnvPrefix(p.ID), p.Credentials); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	credentials, err := encodeCredentials(p.Credentials)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	db := database.GetDB()
+	if p.ID > 0 {
+		_, used := dnsProviderInUse(p.ID)
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec("UPDATE dns_providers SET name=?, provider=?, credentials=? WHERE id=?",
+			p.Name, p.Provider, credentials, p.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// 正在使用的服务商凭据变化后需要重新生成配置，通过校验后才提交
+		if used {
+			err = applyTx(tx)
+		} else {
+			err = tx.Commit()
+		}
+		if err != nil {
+			writeCaddyError(w, err)
+			return
+		}
+	} else {
+		result, err := db.Exec("INSERT INTO dns_providers (name, provider, credentials) VALUES (?, ?, ?)",
+			p.Name, p.Provider, credentials)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		id, _ := result.LastInsertId()
+		p.ID = int(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "DNS 服务商已保存",
+		"id":      p.ID,
+	})
+}
+
+// DeleteDNSProviderHandler 删除 DNS 服务商，仍被站点或项目使用时拒绝删除
+func DeleteDNSProviderHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	if owner, used := dnsProviderInUse(id); used {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("DNS 服务商正在被%s使用，请先修改其证书设置", owner),
+			"code":    "DNS_PROVIDER_IN_USE",
+		})
+		return
+	}
+
+	if _, err := database.GetDB().Exec("DELETE FROM dns_providers WHERE id = ?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "DNS 服务商已删除",
+	})
+}
+
+// TestDNSProviderHandler 测试 DNS 服务商凭据
+// 请求体: {"id": 1} 测试已保存的服务商，或直接提交 provider 和 credentials；
+// RFC2136 可额外提交 domain，向 DNS 服务器查询该区域的 SOA 记录
+func TestDNSProviderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		models.DNSProvider
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := req.DNSProvider
+	if err := resolveCredentials(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if _, err := caddyfile.NewDNSChallenge(p.Provider, caddyfile.DNSEnvPrefix(p.ID), p.Credentials); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	var message string
+	var err error
+	switch p.Provider {
+	case "cloudflare":
+		message, err = testCloudflareToken(p.Credentials["api_token"])
+	case "rfc2136":
+		message, err = testRFC2136(p.Credentials["server"], req.Domain)
+	default:
+		message = "凭据格式正确，该服务商不支持在线验证，将在申请证书时验证"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+	})
+}
+
+// CaddyModulesHandler 返回当前 Caddy 是否包含各 DNS 服务商模块，以及缺少时的下载地址
+func CaddyModulesHandler(w http.ResponseWriter, r *http.Request) {
+	type moduleStatus struct {
+		Provider    string `json:"provider"`
+		Module      string `json:"module"`
+		Installed   bool   `json:"installed"`
+		DownloadURL string `json:"download_url"`
+	}
+
+	modules, err := caddy.ListModules()
+	statuses := []moduleStatus{}
+	for name, spec := range caddyfile.DNSProviders {
+		statuses = append(statuses, moduleStatus{
+			Provider:    name,
+			Module:      spec.Module,
+			Installed:   modules[spec.Module],
+			DownloadURL: caddy.CustomBuildURL(spec.Package),
+		})
+	}
+
+	response := map[string]interface{}{
+		"dns_modules": statuses,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getDNSProvider 读取 DNS 服务商及其凭据
+func getDNSProvider(q database.Querier, id int) (*models.DNSProvider, error) {
+	var p models.DNSProvider
+	var credentials string
+	err := q.QueryRow("SELECT id, name, provider, credentials, created_at FROM dns_providers WHERE id = ?", id).
+		Scan(&p.ID, &p.Name, &p.Provider, &credentials, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if p.Credentials, err = decodeCredentials(credentials); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// encodeCredentials 凭据与密钥一样加密保存
+func encodeCredentials(credentials map[string]string) (string, error) {
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return "", err
+	}
+	return encryptSecret(string(data))
+}
+
+// decodeCredentials 解密凭据，旧版本以明文 JSON 保存的凭据直接解析
+func decodeCredentials(stored string) (map[string]string, error) {
+	plain := stored
+	if !strings.HasPrefix(strings.TrimSpace(stored), "{") {
+		var err error
+		if plain, err = decryptSecret(stored); err != nil {
+			return nil, err
+		}
+	}
+	var credentials map[string]string
+	if err := json.Unmarshal([]byte(plain), &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// EncryptDNSCredentials 加密旧版本以明文保存的 DNS 服务商凭据
+func EncryptDNSCredentials() {
+	db := database.GetDB()
+	rows, err := db.Query("SELECT id, credentials FROM dns_providers WHERE credentials LIKE '{%'")
+	if err != nil {
+		log.Printf("⚠️ 读取 DNS 服务商凭据失败: %v", err)
+		return
+	}
+	plain := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var credentials string
+		if err := rows.Scan(&id, &credentials); err == nil {
+			plain[id] = credentials
+		}
+	}
+	rows.Close()
+
+	encrypted := 0
+	for id, credentials := range plain {
+		decoded, err := decodeCredentials(credentials)
+		if err == nil {
+			credentials, err = encodeCredentials(decoded)
+		}
+		if err == nil {
+			_, err = db.Exec("UPDATE dns_providers SET credentials=? WHERE id=?", credentials, id)
+		}
+		if err != nil {
+			log.Printf("⚠️ 加密 DNS 服务商 #%d 的凭据失败: %v", id, err)
+			continue
+		}
+		encrypted++
+	}
+	if encrypted > 0 {
+		log.Printf("✅ 已加密 %d 个 DNS 服务商的凭据", encrypted)
+	}
+}
+
+// resolveCredentials 已保存的服务商提交占位符时，替换为数据库中的原值
+func resolveCredentials(p *models.DNSProvider) error {
+	if p.ID == 0 {
+		return nil
+	}
+	stored, err := getDNSProvider(database.GetDB(), p.ID)
+	if err != nil {
+		return fmt.Errorf("DNS 服务商 #%d 不存在", p.ID)
+	}
+	if p.Provider == "" {
+		p.Provider = stored.Provider
+	}
+	if p.Credentials == nil {
+		p.Credentials = stored.Credentials
+	}
+	for field, value := range p.Credentials {
+		if value == secretMask {
+			p.Credentials[field] = stored.Credentials[field]
+		}
+	}
+	return nil
+}
+
+// maskCredentials 隐藏凭据中的密钥，服务器地址、密钥名称等非敏感字段保留明文
+func maskCredentials(provider string, credentials map[string]string) map[string]string {
+	public := make(map[string]bool)
+	for _, field := range caddyfile.DNSProviders[provider].Public {
+		public[field] = true
+	}
+	masked := make(map[string]string, len(credentials))
+	for field, value := range credentials {
+		if public[field] {
+			masked[field] = value
+		} else {
+			masked[field] = maskSecret(value)
+		}
+	}
+	return masked
+}
+
+// dnsProviderInUse 检查是否有站点或项目使用该 DNS 服务商
+func dnsProviderInUse(id int) (string, bool) {
+	db := database.GetDB()
+	var ownerID int
+	if err := db.QueryRow("SELECT id FROM sites WHERE dns_provider_id = ?", id).Scan(&ownerID); err == nil {
+		return fmt.Sprintf(" 站点 #%d ", ownerID), true
+	}
+	var name string
+	if err := db.QueryRow("SELECT id, name FROM projects WHERE dns_provider_id = ?", id).Scan(&ownerID, &name); err == nil {
+		return fmt.Sprintf(" 项目 #%d %s ", ownerID, name), true
+	}
+	return "", false
+}
+
+// testCloudflareToken 调用 Cloudflare API 验证 API Token 是否有效
+func testCloudflareToken(token string) (string, error) {
+	req, err := http.NewRequest("GET", "https://api.cloudflare.com/client/v4/user/tokens/verify", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("无法连接 Cloudflare API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result struct {
+			Status string `json:"status"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("Cloudflare API 返回无法解析: %v", err)
+	}
+	if !result.Success {
+		var messages []string
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return "", fmt.Errorf("API Token 无效: %s", strings.Join(messages, "; "))
+	}
+	if result.Result.Status != "active" {
+		return "", fmt.Errorf("API Token 状态为 %s", result.Result.Status)
+	}
+	return "API Token 有效（请确认已授予 Zone.DNS 编辑权限）", nil
+}
+
+// dnsRcodes DNS 响应码名称
+var dnsRcodes = map[int]string{0: "NOERROR", 1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED"}
+
+// testRFC2136 向 DNS 服务器发送 SOA 查询，确认服务器可以访问；TSIG 密钥在申请证书时验证
+func testRFC2136(server, zone string) (string, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	zone = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(zone), "*."), ".")
+
+	query, id, err := soaQuery(zone)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.DialTimeout("udp", server, 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("无法连接 DNS 服务器 %s: %v", server, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write(query); err != nil {
+		return "", fmt.Errorf("发送查询失败: %v", err)
+	}
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("DNS 服务器 %s 无响应: %v", server, err)
+	}
+	if n < 12 || binary.BigEndian.Uint16(buf[0:2]) != id || buf[2]&0x80 == 0 {
+		return "", fmt.Errorf("DNS 服务器 %s 返回了无效的响应", server)
+	}
+
+	rcode := int(buf[3] & 0x0F)
+	name := dnsRcodes[rcode]
+	if name == "" {
+		name = fmt.Sprintf("RCODE %d", rcode)
+	}
+	if zone == "" {
+		return fmt.Sprintf("DNS 服务器 %s 可以访问（%s）", server, name), nil
+	}
+	if rcode != 0 {
+		return "", fmt.Errorf("DNS 服务器 %s 查询 %s 的 SOA 记录返回 %s，请确认该服务器负责此区域", server, zone, name)
+	}
+	if binary.BigEndian.Uint16(buf[6:8]) == 0 && binary.BigEndian.Uint16(buf[8:10]) == 0 {
+		return "", fmt.Errorf("DNS 服务器 %s 没有区域 %s 的 SOA 记录", server, zone)
+	}
+	return fmt.Sprintf("DNS 服务器 %s 可以访问，区域 %s 存在", server, zone), nil
+}
+
+// soaQuery 构造查询 zone SOA 记录的 DNS 报文，zone 为空时查询根区域
+func soaQuery(zone string) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	rand.Read(idBytes[:])
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	// 头部: ID、标志（RD）、问题数 1
+	msg := []byte{idBytes[0], idBytes[1], 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	if zone != "" {
+		for _, label := range strings.Split(zone, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, 0, fmt.Errorf("域名 %s 格式不正确", zone)
+			}
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+	}
+	// 根标签、QTYPE=SOA(6)、QCLASS=IN(1)
+	msg = append(msg, 0, 0, 6, 0, 1)
+	return msg, id, nil
+}
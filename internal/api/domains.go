@@ -0,0 +1,303 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// execer 同时适用于 *sql.DB 和 *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// DomainsHandler 列出所有站点、项目使用的域名，以及旧数据中无法识别、未写入配置的域名
+func DomainsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.GetDB().Query(`SELECT d.id, d.host, d.path, d.owner_type, d.owner_id, COALESCE(p.name, ''), d.created_at
+		FROM domains d LEFT JOIN projects p ON d.owner_type = 'project' AND p.id = d.owner_id
+		ORDER BY d.host, d.path`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	domains := []models.Domain{}
+	for rows.Next() {
+		var d models.Domain
+		var name string
+		if err := rows.Scan(&d.ID, &d.Host, &d.Path, &d.OwnerType, &d.OwnerID, &name, &d.CreatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		d.Display = caddyfile.DisplayHost(d.Host)
+		d.Owner = ownerLabel(d.OwnerType, d.OwnerID, name)
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rows.Close()
+
+	invalid, err := unmigratedDomains()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domains": domains,
+		"invalid": invalid,
+	})
+}
+
+// normalizeDomains 规范化域名列表（每行一个），去掉空行和重复项；任何一个无效都返回 HostError
+func normalizeDomains(list string) ([]string, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		host, err := caddyfile.NormalizeHost(line)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// replaceDomains 用 hosts 替换站点或项目在 domains 表中的记录，path 为项目的反向代理路径（站点为空）。
+// 同一域名和路径已属于其他站点、项目时返回 DuplicateHostError
+func replaceDomains(ex execer, ownerType string, ownerID int, path string, hosts []string) error {
+	if _, err := ex.Exec("DELETE FROM domains WHERE owner_type=? AND owner_id=?", ownerType, ownerID); err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		if err := insertDomain(ex, ownerType, ownerID, path, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertDomain 为站点或项目添加一个已规范化的域名
+func insertDomain(ex execer, ownerType string, ownerID int, path, host string) error {
+	var otherType, otherName string
+	var otherID int
+	err := ex.QueryRow(`SELECT d.owner_type, d.owner_id, COALESCE(p.name, '') FROM domains d
+		LEFT JOIN projects p ON d.owner_type = 'project' AND p.id = d.owner_id
+		WHERE d.host=? AND d.path=?`, host, path).Scan(&otherType, &otherID, &otherName)
+	if err == nil {
+		var name string
+		if ownerType == "project" {
+			ex.QueryRow("SELECT name FROM projects WHERE id=?", ownerID).Scan(&name)
+		}
+		return &caddyfile.DuplicateHostError{Host: host, Owners: []string{ownerLabel(ownerType, ownerID, name), ownerLabel(otherType, otherID, otherName)}}
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	_, err = ex.Exec("INSERT INTO domains (host, path, owner_type, owner_id) VALUES (?, ?, ?, ?)", host, path, ownerType, ownerID)
+	return err
+}
+
+// saveProjectDomains 按项目的域名和反向代理路径更新 domains 表，p 应已通过 checkProjectRoutes 检查
+func saveProjectDomains(ex execer, p *models.Project) error {
+	path, err := caddyfile.NormalizePath(p.ReverseProxyPath)
+	if err != nil {
+		return err
+	}
+	hosts, err := normalizeDomains(p.Domains)
+	if err != nil {
+		return err
+	}
+	return replaceDomains(ex, "project", p.ID, path, hosts)
+}
+
+// loadDomains 读取某类所有者的域名，按所有者 ID 分组，保持添加顺序
+func loadDomains(q database.Querier, ownerType string) (map[int][]string, error) {
+	rows, err := q.Query("SELECT owner_id, host FROM domains WHERE owner_type=? ORDER BY id", ownerType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := make(map[int][]string)
+	for rows.Next() {
+		var id int
+		var host string
+		if err := rows.Scan(&id, &host); err != nil {
+			return nil, err
+		}
+		domains[id] = append(domains[id], host)
+	}
+	return domains, rows.Err()
+}
+
+// domainOwner 检查域名（任意路径）是否已被站点或项目使用
+func domainOwner(host string) (string, bool) {
+	var ownerType, name string
+	var id int
+	err := database.GetDB().QueryRow(`SELECT d.owner_type, d.owner_id, COALESCE(p.name, '') FROM domains d
+		LEFT JOIN projects p ON d.owner_type = 'project' AND p.id = d.owner_id
+		WHERE d.host=? ORDER BY d.id LIMIT 1`, host).Scan(&ownerType, &id, &name)
+	if err != nil {
+		return "", false
+	}
+	return ownerLabel(ownerType, id, name) + " ", true
+}
+
+func ownerLabel(ownerType string, id int, name string) string {
+	if ownerType == "project" {
+		return strings.TrimSpace(fmt.Sprintf("项目 #%d %s", id, name))
+	}
+	return fmt.Sprintf("站点 #%d", id)
+}
+
+// MigrateDomains 把 sites.domain 和 projects.domains 中尚未写入 domains 表的域名迁移过去，同时把原列改写为规范形式。
+// 无效或与其他站点、项目重复的域名不会写入配置，记录到日志，并在域名列表中列出；
+// 迁移前先清理所属站点或项目已被删除的域名记录
+func MigrateDomains() {
+	db := database.GetDB()
+	// 清理所属站点或项目已不存在的域名记录，否则这些域名会被当作已占用而无法再使用
+	if result, err := db.Exec(`DELETE FROM domains WHERE
+		(owner_type = 'site' AND owner_id NOT IN (SELECT id FROM sites)) OR
+		(owner_type = 'project' AND owner_id NOT IN (SELECT id FROM projects))`); err != nil {
+		log.Printf("⚠️ 清理无主域名失败: %v", err)
+	} else if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("🧹 已清理 %d 个不属于任何站点或项目的域名", n)
+	}
+	type owner struct {
+		ownerType, name, domains, path string
+		id                             int
+	}
+	var owners []owner
+	rows, err := db.Query(`SELECT 'site', id, '', domain, '' FROM sites WHERE domain != ''
+		AND id NOT IN (SELECT owner_id FROM domains WHERE owner_type = 'site')
+		UNION ALL
+		SELECT 'project', id, name, domains, COALESCE(reverse_proxy_path, '') FROM projects WHERE domains != ''
+		AND id NOT IN (SELECT owner_id FROM domains WHERE owner_type = 'project')`)
+	if err != nil {
+		log.Printf("⚠️ 迁移域名失败: %v", err)
+		return
+	}
+	for rows.Next() {
+		var o owner
+		if err := rows.Scan(&o.ownerType, &o.id, &o.name, &o.domains, &o.path); err == nil {
+			owners = append(owners, o)
+		}
+	}
+	rows.Close()
+
+	migrated := 0
+	for _, o := range owners {
+		label := ownerLabel(o.ownerType, o.id, o.name)
+		path, err := caddyfile.NormalizePath(o.path)
+		if err != nil {
+			log.Printf("⚠️ %s 的路径无效，域名未迁移: %v", label, err)
+			continue
+		}
+		// 逐个写入，无效或重复的域名跳过，其余照常迁移
+		var kept []string
+		dropped := false
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(o.domains, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			host, err := caddyfile.NormalizeHost(line)
+			if err == nil && !seen[host] {
+				err = insertDomain(db, o.ownerType, o.id, path, host)
+			}
+			if err != nil {
+				log.Printf("⚠️ %s: %v，该域名不会写入配置", label, err)
+				dropped = true
+				continue
+			}
+			if !seen[host] {
+				seen[host] = true
+				kept = append(kept, host)
+			}
+		}
+		migrated += len(kept)
+		// 有跳过的域名时保留原列，便于在域名列表中查看和修改
+		if len(kept) == 0 || dropped {
+			continue
+		}
+		if o.ownerType == "site" {
+			db.Exec("UPDATE sites SET domain=? WHERE id=?", kept[0], o.id)
+		} else {
+			db.Exec("UPDATE projects SET domains=? WHERE id=?", strings.Join(kept, "\n"), o.id)
+		}
+	}
+	if migrated > 0 {
+		log.Printf("✅ 已迁移 %d 个域名到 domains 表", migrated)
+	}
+}
+
+// unmigratedDomains 返回站点、项目中没有对应 domains 记录的域名及原因，这些域名不会写入配置
+func unmigratedDomains() ([]map[string]string, error) {
+	registered := make(map[string]bool)
+	for _, ownerType := range []string{"site", "project"} {
+		domains, err := loadDomains(database.GetDB(), ownerType)
+		if err != nil {
+			return nil, err
+		}
+		for id, hosts := range domains {
+			for _, host := range hosts {
+				registered[fmt.Sprintf("%s/%d/%s", ownerType, id, host)] = true
+			}
+		}
+	}
+
+	rows, err := database.GetDB().Query(`SELECT 'site', id, '', domain FROM sites WHERE domain != ''
+		UNION ALL SELECT 'project', id, name, domains FROM projects WHERE domains != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invalid := []map[string]string{}
+	for rows.Next() {
+		var ownerType, name, list string
+		var id int
+		if err := rows.Scan(&ownerType, &id, &name, &list); err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(list, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			reason := "与其他站点或项目重复"
+			host, err := caddyfile.NormalizeHost(line)
+			var hostErr *caddyfile.HostError
+			if errors.As(err, &hostErr) {
+				reason = hostErr.Reason
+			} else if registered[fmt.Sprintf("%s/%d/%s", ownerType, id, host)] {
+				continue
+			}
+			invalid = append(invalid, map[string]string{
+				"host":   strings.TrimSpace(line),
+				"owner":  ownerLabel(ownerType, id, name),
+				"reason": reason,
+			})
+		}
+	}
+	return invalid, rows.Err()
+}
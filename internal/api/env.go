@@ -0,0 +1,410 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// managedEnvVars 由管理器为每个实例设置，不能在项目中覆盖，但可以用 ${PORT} 引用
+var managedEnvVars = []string{"PORT", "INSTANCE_INDEX"}
+
+// ProjectEnvHandler 获取项目的环境变量和 .env 文件路径，值按保存时的原样返回（密钥只保存引用）
+// 参数: ?id=1
+func ProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	env, err := loadProjectEnv(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(env)
+}
+
+// SaveProjectEnvHandler 保存项目的环境变量（整体替换），下次启动实例时生效
+// 请求体: {"project_id": 1, "env_file": ".env", "vars": [{"key": "DATABASE_URL", "value": "postgres://app:${secret:DB_PASSWORD}@localhost/app"}]}
+func SaveProjectEnvHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var env models.ProjectEnv
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkProjectEnv(&env); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE projects SET env_file=? WHERE id=?", env.EnvFile, env.ProjectID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM project_env WHERE project_id=?", env.ProjectID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, v := range env.Vars {
+		if _, err := tx.Exec("INSERT INTO project_env (project_id, key, value) VALUES (?, ?, ?)", env.ProjectID, v.Key, v.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "环境变量已保存，重启项目后生效",
+		"env":     env,
+	})
+}
+
+// ProjectEnvPreviewHandler 预览实例启动时设置的环境变量（不含继承自管理器的系统环境变量），
+// 引用了密钥的值以 ******** 显示
+// 参数: ?id=1&instance=0
+func ProjectEnvPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	index, _ := strconv.Atoi(r.URL.Query().Get("instance"))
+
+	var rootDir string
+	var port, instances int
+	err := database.GetDB().QueryRow("SELECT root_dir, port, COALESCE(instances, 1) FROM projects WHERE id=?", id).Scan(&rootDir, &port, &instances)
+	if err != nil {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+	if index < 0 || index >= instances {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: fmt.Sprintf("项目 #%d", id), Option: "instance", Value: strconv.Itoa(index), Reason: fmt.Sprintf("应为 0-%d", instances-1)})
+		return
+	}
+
+	entries, warnings, err := resolveProjectEnv(id, rootDir, index, port+index)
+	if err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	for i := range entries {
+		if entries[i].Masked {
+			entries[i].Value = secretMask
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"instance": index,
+		"port":     port + index,
+		"env":      entries,
+		"warnings": warnings,
+	})
+}
+
+// checkProjectEnv 检查变量名和引用的密钥，去掉 .env 路径两端的空格
+func checkProjectEnv(env *models.ProjectEnv) error {
+	owner := fmt.Sprintf("项目 #%d", env.ProjectID)
+	env.EnvFile = strings.TrimSpace(env.EnvFile)
+	if env.Vars == nil {
+		env.Vars = []models.ProjectEnvVar{}
+	}
+
+	seen := make(map[string]bool)
+	for i, v := range env.Vars {
+		key := strings.TrimSpace(v.Key)
+		env.Vars[i].Key = key
+		if !envNamePattern.MatchString(key) {
+			return &caddyfile.OptionError{Owner: owner, Option: "vars", Value: key, Reason: "变量名只能包含字母、数字和下划线，且不能以数字开头"}
+		}
+		for _, managed := range managedEnvVars {
+			if key == managed {
+				return &caddyfile.OptionError{Owner: owner, Option: "vars", Value: key, Reason: "由管理器为每个实例设置，可以用 ${" + key + "} 引用"}
+			}
+		}
+		if seen[key] {
+			return &caddyfile.OptionError{Owner: owner, Option: "vars", Value: key, Reason: "变量名重复"}
+		}
+		seen[key] = true
+		for _, name := range secretRefs(v.Value) {
+			if !secretExists(name) {
+				return &caddyfile.OptionError{Owner: owner, Option: "vars", Value: key, Reason: "引用的密钥 " + name + " 不存在"}
+			}
+		}
+	}
+	return nil
+}
+
+// loadProjectEnv 读取项目的环境变量设置
+func loadProjectEnv(id int) (models.ProjectEnv, error) {
+	env := models.ProjectEnv{ProjectID: id, Vars: []models.ProjectEnvVar{}}
+	db := database.GetDB()
+	if err := db.QueryRow("SELECT COALESCE(env_file, '') FROM projects WHERE id=?", id).Scan(&env.EnvFile); err != nil {
+		return env, err
+	}
+
+	rows, err := db.Query("SELECT key, value FROM project_env WHERE project_id=? ORDER BY id", id)
+	if err != nil {
+		return env, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v models.ProjectEnvVar
+		if err := rows.Scan(&v.Key, &v.Value); err != nil {
+			return env, err
+		}
+		env.Vars = append(env.Vars, v)
+	}
+	return env, rows.Err()
+}
+
+// resolveProjectEnv 计算实例启动时设置的环境变量：依次为 .env 文件、项目中的变量、PORT 和 INSTANCE_INDEX，
+// 同名时后者覆盖前者。值中的 ${NAME} 按已设置的变量和系统环境变量展开，${secret:NAME} 替换为解密后的密钥。
+// 未定义的变量展开为空并返回警告；.env 文件不存在或密钥无法读取时返回错误
+func resolveProjectEnv(id int, rootDir string, index, port int) ([]models.EnvEntry, []string, error) {
+	owner := fmt.Sprintf("项目 #%d", id)
+	env, err := loadProjectEnv(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type source struct {
+		vars   []models.ProjectEnvVar
+		source string
+	}
+	var sources []source
+	if env.EnvFile != "" {
+		path := env.EnvFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(rootDir, path)
+		}
+		vars, err := parseEnvFile(path)
+		if err != nil {
+			return nil, nil, &caddyfile.OptionError{Owner: owner, Option: "env_file", Value: env.EnvFile, Reason: err.Error()}
+		}
+		sources = append(sources, source{vars, ".env"})
+	}
+	sources = append(sources, source{env.Vars, "project"})
+
+	managed := []models.ProjectEnvVar{{Key: "PORT", Value: strconv.Itoa(port)}, {Key: "INSTANCE_INDEX", Value: strconv.Itoa(index)}}
+	values := make(map[string]string)
+	masked := make(map[string]bool)
+	for _, v := range managed {
+		values[v.Key] = v.Value
+	}
+
+	var entries []models.EnvEntry
+	var warnings []string
+	position := make(map[string]int)
+	set := func(e models.EnvEntry) {
+		values[e.Key] = e.Value
+		masked[e.Key] = e.Masked
+		if i, ok := position[e.Key]; ok {
+			entries[i] = e
+			return
+		}
+		position[e.Key] = len(entries)
+		entries = append(entries, e)
+	}
+
+	for _, src := range sources {
+		for _, v := range src.vars {
+			if src.source == ".env" && (v.Key == "PORT" || v.Key == "INSTANCE_INDEX") {
+				warnings = append(warnings, fmt.Sprintf(".env 中的 %s 由管理器设置的值覆盖", v.Key))
+				continue
+			}
+			if v.Literal {
+				set(models.EnvEntry{Key: v.Key, Value: v.Value, Source: src.source})
+				continue
+			}
+			value, secret, missing, err := expandEnvValue(v.Value, values, masked)
+			if err != nil {
+				return nil, nil, &caddyfile.OptionError{Owner: owner, Option: "vars", Value: v.Key, Reason: err.Error()}
+			}
+			for _, name := range missing {
+				warnings = append(warnings, fmt.Sprintf("%s 引用的 ${%s} 未定义，展开为空", v.Key, name))
+			}
+			set(models.EnvEntry{Key: v.Key, Value: value, Source: src.source, Masked: secret})
+		}
+	}
+	for _, v := range managed {
+		set(models.EnvEntry{Key: v.Key, Value: v.Value, Source: "manager"})
+	}
+	return entries, warnings, nil
+}
+
+// projectEnviron 返回启动实例时使用的完整环境：管理器自身的环境变量加上项目的环境变量
+func projectEnviron(id int, rootDir string, index, port int) ([]string, error) {
+	entries, _, err := resolveProjectEnv(id, rootDir, index, port)
+	if err != nil {
+		return nil, err
+	}
+	environ := os.Environ()
+	for _, e := range entries {
+		environ = append(environ, e.Key+"="+e.Value)
+	}
+	return environ, nil
+}
+
+// expandEnvValue 展开值中的 ${NAME} 和 ${secret:NAME}，其他形式的 $ 原样保留。
+// 引用了密钥或引用了含密钥的变量时 secret 为 true；missing 为未定义的变量名
+func expandEnvValue(value string, values map[string]string, masked map[string]bool) (expanded string, secret bool, missing []string, err error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			break
+		}
+		name := value[start+2 : start+end]
+		b.WriteString(value[:start])
+		value = value[start+end+1:]
+
+		if secretName, ok := strings.CutPrefix(name, "secret:"); ok {
+			plain, err := loadSecret(secretName)
+			if err == sql.ErrNoRows {
+				return "", false, nil, fmt.Errorf("引用的密钥 %s 不存在", secretName)
+			}
+			if err != nil {
+				return "", false, nil, fmt.Errorf("读取密钥 %s 失败: %v", secretName, err)
+			}
+			b.WriteString(plain)
+			secret = true
+			continue
+		}
+		if !envNamePattern.MatchString(name) {
+			b.WriteString("${" + name + "}")
+			continue
+		}
+		if v, ok := values[name]; ok {
+			b.WriteString(v)
+			secret = secret || masked[name]
+		} else if v, ok := os.LookupEnv(name); ok {
+			b.WriteString(v)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	b.WriteString(value)
+	return b.String(), secret, missing, nil
+}
+
+// secretRefs 返回值中引用的密钥名称
+func secretRefs(value string) []string {
+	var names []string
+	for {
+		start := strings.Index(value, "${secret:")
+		if start < 0 {
+			return names
+		}
+		value = value[start+len("${secret:"):]
+		end := strings.Index(value, "}")
+		if end < 0 {
+			return names
+		}
+		names = append(names, value[:end])
+		value = value[end+1:]
+	}
+}
+
+// parseEnvFile 读取 .env 文件：每行 KEY=VALUE，支持 export 前缀、# 注释、
+// 双引号（可用 \n \" \\ 转义）和单引号（原样，不展开 ${...}）；未加引号的值中 " #" 之后为注释
+func parseEnvFile(path string) ([]models.ProjectEnvVar, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("文件 %s 不存在", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var vars []models.ProjectEnvVar
+	for n, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envNamePattern.MatchString(key) {
+			return nil, fmt.Errorf("第 %d 行格式无效，应为 KEY=VALUE", n+1)
+		}
+		value = strings.TrimSpace(value)
+
+		literal := false
+		switch {
+		case strings.HasPrefix(value, `"`):
+			var b strings.Builder
+			closed := false
+			for i := 1; i < len(value) && !closed; i++ {
+				switch c := value[i]; {
+				case c == '"':
+					closed = true
+				case c == '\\' && i+1 < len(value):
+					i++
+					switch value[i] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(value[i])
+					}
+				default:
+					b.WriteByte(c)
+				}
+			}
+			if !closed {
+				return nil, fmt.Errorf("第 %d 行缺少结束的双引号", n+1)
+			}
+			value = b.String()
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("第 %d 行缺少结束的单引号", n+1)
+			}
+			value = value[1 : end+1]
+			literal = true
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars = append(vars, models.ProjectEnvVar{Key: key, Value: value, Literal: literal})
+	}
+	return vars, nil
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+)
+
+// errorPageCodes 可以自定义页面的状态码
+var errorPageCodes = []int{404, 500, 502, 503}
+
+// maxErrorPageSize 错误页面的最大大小
+const maxErrorPageSize = 1 << 20
+
+// startingPageCodes 项目停止时显示"正在启动"页面的状态码（上游无法连接时 Caddy 返回 502）
+var startingPageCodes = []int{502, 503}
+
+// startingPageHTML 内置的"项目正在启动"页面，每 5 秒自动刷新
+const startingPageHTML = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="5">
+<title>服务正在启动</title>
+<style>
+body { margin: 0; min-height: 100vh; display: flex; align-items: center; justify-content: center;
+  font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; background: #f5f7fa; color: #333; }
+.box { max-width: 480px; padding: 40px; background: #fff; border-radius: 12px; box-shadow: 0 4px 20px rgba(0,0,0,.08); text-align: center; }
+h1 { font-size: 24px; margin: 0 0 16px; }
+p { line-height: 1.7; color: #666; margin: 8px 0; }
+</style>
+</head>
+<body>
+<div class="box">
+<h1>⏳ 服务正在启动</h1>
+<p>服务暂时不可用，可能正在启动或重启。</p>
+<p>页面将在几秒后自动刷新。</p>
+</div>
+</body>
+</html>
+`
+
+// ErrorPagesHandler 列出站点或项目的自定义错误页面
+// 参数: ?owner_type=site|project&owner_id=1
+func ErrorPagesHandler(w http.ResponseWriter, r *http.Request) {
+	ownerType := r.URL.Query().Get("owner_type")
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	if err := checkErrorPageOwner(ownerType, ownerID); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	dir := errorPagesDir(ownerType, ownerID)
+
+	pages := []map[string]interface{}{}
+	for _, code := range errorPageCodes {
+		page := map[string]interface{}{"code": code, "exists": false}
+		if data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.html", code))); err == nil {
+			page["exists"] = true
+			page["content"] = string(data)
+		}
+		pages = append(pages, page)
+	}
+
+	response := map[string]interface{}{
+		"dir":   dir,
+		"pages": pages,
+	}
+	if ownerType == "project" {
+		enabled, err := startingPageEnabled(database.GetDB(), ownerID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["starting_page"] = enabled
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SaveErrorPageHandler 保存错误页面，上传文件时前端读取文件内容后提交
+// 请求体: {"owner_type": "site", "owner_id": 1, "code": 502, "content": "<!DOCTYPE html>..."}
+func SaveErrorPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		OwnerType string `json:"owner_type"`
+		OwnerID   int    `json:"owner_id"`
+		Code      int    `json:"code"`
+		Content   string `json:"content"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxErrorPageSize)).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkErrorPage(req.OwnerType, req.OwnerID, req.Code); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" || len(req.Content) > maxErrorPageSize {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "错误页面", Option: "content", Value: strconv.Itoa(req.Code), Reason: "页面内容不能为空，且不能超过 1 MB"})
+		return
+	}
+
+	dir := errorPagesDir(req.OwnerType, req.OwnerID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 第一个页面需要生成 handle_errors 块，未通过校验时恢复原来的页面
+	path := filepath.Join(dir, fmt.Sprintf("%d.html", req.Code))
+	if err := applyFileChange(func() error {
+		return os.WriteFile(path, []byte(req.Content), 0644)
+	}, path); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("%d 错误页面已保存", req.Code),
+	})
+}
+
+// DeleteErrorPageHandler 删除错误页面，恢复 Caddy 默认的错误响应
+// 参数: ?owner_type=site&owner_id=1&code=502
+func DeleteErrorPageHandler(w http.ResponseWriter, r *http.Request) {
+	ownerType := r.URL.Query().Get("owner_type")
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+	if err := checkErrorPage(ownerType, ownerID, code); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	path := filepath.Join(errorPagesDir(ownerType, ownerID), fmt.Sprintf("%d.html", code))
+	if err := applyFileChange(func() error {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}, path); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// StartingPageHandler 开启或关闭项目的"正在启动"页面：项目停止时，上游无法连接返回内置页面而不是 502
+// 请求体: {"project_id": 1, "enabled": true}
+func StartingPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ProjectID int  `json:"project_id"`
+		Enabled   bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	var status string
+	if err := db.QueryRow("SELECT COALESCE(status, 'stopped') FROM projects WHERE id=?", req.ProjectID).Scan(&status); err != nil {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE projects SET starting_page=? WHERE id=?", req.Enabled, req.ProjectID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	if err := syncStartingPage(req.ProjectID, status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "设置已保存",
+	})
+}
+
+// errorPagesDir 站点或项目的错误页面目录 data/error_pages/<site|project>-<id>
+func errorPagesDir(ownerType string, ownerID int) string {
+	return filepath.Join(config.DataDir, "error_pages", fmt.Sprintf("%s-%d", ownerType, ownerID))
+}
+
+// checkErrorPage 检查所有者类型和状态码
+func checkErrorPage(ownerType string, ownerID int, code int) error {
+	if err := checkErrorPageOwner(ownerType, ownerID); err != nil {
+		return err
+	}
+	for _, c := range errorPageCodes {
+		if c == code {
+			return nil
+		}
+	}
+	return &caddyfile.OptionError{Owner: "错误页面", Option: "code", Value: strconv.Itoa(code), Reason: "可选值: 404, 500, 502, 503"}
+}
+
+// checkErrorPageOwner 检查所有者类型和 ID，错误页面目录名由两者拼成
+func checkErrorPageOwner(ownerType string, ownerID int) error {
+	if (ownerType != "site" && ownerType != "project") || ownerID <= 0 {
+		return &caddyfile.OptionError{Owner: "错误页面", Option: "owner_type", Value: ownerType, Reason: "可选值: site, project"}
+	}
+	return nil
+}
+
+// errorPagesFor 站点或项目有自定义错误页面或开启了"正在启动"页面时返回路由的错误页面设置。
+// "正在启动"页面只在项目停止时存在（见 syncStartingPage），优先于自定义的 502/503 页面
+func errorPagesFor(q database.Querier, ownerType string, ownerID int) (*caddyfile.ErrorPages, error) {
+	if ownerID == 0 {
+		return nil, nil
+	}
+	dir := errorPagesDir(ownerType, ownerID)
+	pages := &caddyfile.ErrorPages{Root: dir}
+	if ownerType == "project" {
+		enabled, err := startingPageEnabled(q, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			pages.Files = []string{"/starting-{err.status_code}.html", "/{err.status_code}.html"}
+			return pages, nil
+		}
+	}
+	for _, code := range errorPageCodes {
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%d.html", code))); err == nil {
+			return pages, nil
+		}
+	}
+	return nil, nil
+}
+
+// startingPageEnabled 项目是否开启了"正在启动"页面，项目不存在时视为未开启
+func startingPageEnabled(q database.Querier, projectID int) (bool, error) {
+	var enabled bool
+	err := q.QueryRow("SELECT COALESCE(starting_page, 0) FROM projects WHERE id=?", projectID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabled, err
+}
+
+// syncStartingPage 开启了"正在启动"页面的项目停止时写入页面文件，运行时删除，
+// Caddy 按文件是否存在决定返回哪个页面，无需重新加载配置
+func syncStartingPage(projectID int, status string) error {
+	dir := errorPagesDir("project", projectID)
+	enabled, err := startingPageEnabled(database.GetDB(), projectID)
+	if err != nil {
+		return err
+	}
+	show := status != "running" && enabled
+	if show {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	for _, code := range startingPageCodes {
+		path := filepath.Join(dir, fmt.Sprintf("starting-%d.html", code))
+		if show {
+			if err := os.WriteFile(path, []byte(startingPageHTML), 0644); err != nil {
+				return err
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setProjectStatus 更新项目状态并同步"正在启动"页面
+func setProjectStatus(projectID int, status string) {
+	database.GetDB().Exec("UPDATE projects SET status=? WHERE id=?", status, projectID)
+	syncStartingPage(projectID, status)
+}
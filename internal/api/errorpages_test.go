@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"caddy-manager/internal/database"
+)
+
+func TestErrorPagesHandler(t *testing.T) {
+	testDB(t)
+
+	// 所有者类型和 ID 用于拼接目录名，无效时不读取任何目录
+	for _, query := range []string{"owner_type=..&owner_id=1", "owner_type=site/..&owner_id=1", "owner_type=&owner_id=1",
+		"owner_type=site&owner_id=0", "owner_type=site&owner_id=-1", "owner_type=site"} {
+		rec := httptest.NewRecorder()
+		ErrorPagesHandler(rec, httptest.NewRequest(http.MethodGet, "/api/error-pages?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 = %d; 期望 400", query, rec.Code)
+		}
+	}
+
+	dir := errorPagesDir("site", 1)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "404.html"), []byte("not found"), 0644)
+	rec := httptest.NewRecorder()
+	ErrorPagesHandler(rec, httptest.NewRequest(http.MethodGet, "/api/error-pages?owner_type=site&owner_id=1", nil))
+	var resp struct {
+		Pages []struct {
+			Code    int    `json:"code"`
+			Exists  bool   `json:"exists"`
+			Content string `json:"content"`
+		} `json:"pages"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, err = %v", rec.Code, err)
+	}
+	for _, page := range resp.Pages {
+		if page.Exists != (page.Code == 404) || (page.Code == 404 && page.Content != "not found") {
+			t.Errorf("页面 %d: exists = %v, content = %q", page.Code, page.Exists, page.Content)
+		}
+	}
+}
+
+func TestErrorPagesForQueryError(t *testing.T) {
+	testDB(t)
+	if _, err := database.GetDB().Exec("INSERT INTO projects (name, project_type, root_dir, port, starting_page) VALUES ('app', 'custom', '/srv/app', 3000, 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := errorPagesFor(database.GetDB(), "project", 1)
+	if err != nil || pages == nil || len(pages.Files) != 2 {
+		t.Fatalf("errorPagesFor = %+v, %v; 期望包含正在启动页面", pages, err)
+	}
+
+	// 读取设置失败时返回错误，而不是在生成的配置中丢掉错误页面
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if _, err := errorPagesFor(tx, "project", 1); err == nil {
+		t.Error("查询失败时期望返回错误")
+	}
+}
+
+func TestSaveErrorPageRejected(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+	if err := addSiteTx(t, "shop.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(errorPagesDir("site", 1), "404.html")
+	save := func(content string) int {
+		rec := httptest.NewRecorder()
+		body := `{"owner_type": "site", "owner_id": 1, "code": 404, "content": "` + content + `"}`
+		SaveErrorPageHandler(rec, httptest.NewRequest(http.MethodPost, "/api/error-pages", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := save("old"); code != http.StatusOK {
+		t.Fatalf("状态码 = %d", code)
+	}
+
+	// 配置未通过 caddy validate 时恢复原来的页面
+	if _, err := database.GetDB().Exec("UPDATE domains SET host = 'reject.example.com' WHERE owner_type = 'site' AND owner_id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if code := save("new"); code == http.StatusOK {
+		t.Error("期望校验失败")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("页面内容 = %q; 期望恢复为 old", data)
+	}
+}
@@ -2,12 +2,16 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"caddy-manager/internal/auth"
 	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/certs"
 	"caddy-manager/internal/config"
 	"caddy-manager/internal/database"
 	"caddy-manager/internal/models"
@@ -20,7 +24,11 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 
 func SitesHandler(w http.ResponseWriter, r *http.Request) {
 	db := database.GetDB()
-	rows, err := db.Query("SELECT id, domain, type, target, ssl_enabled, environment, php_version FROM sites ORDER BY created_at DESC")
+	rows, err := db.Query(`SELECT id, domain, type, target, ssl_enabled, environment, php_version, COALESCE(tls_mode, ''), COALESCE(ssl_email, ''),
+		COALESCE(acme_ca, ''), COALESCE(acme_ca_url, ''), COALESCE(acme_eab_key_id, ''), COALESCE(acme_eab_hmac_key, ''), COALESCE(acme_ca_root, ''), COALESCE(dns_provider_id, 0),
+		COALESCE(canonical_host, ''), COALESCE(force_https, 1),
+		COALESCE(precompressed, 1), COALESCE(cache_hashed_assets, 1), COALESCE(redirect_status, 301), COALESCE(redirect_keep_path, 1)
+		FROM sites ORDER BY created_at DESC`)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -31,7 +39,10 @@ func SitesHandler(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var site models.Site
 		var env, phpVer *string
-		if err := rows.Scan(&site.ID, &site.Domain, &site.Type, &site.Target, &site.SSLEnabled, &env, &phpVer); err != nil {
+		if err := rows.Scan(&site.ID, &site.Domain, &site.Type, &site.Target, &site.SSLEnabled, &env, &phpVer, &site.TLSMode, &site.SSLEmail,
+			&site.ACMECA, &site.ACMECAURL, &site.ACMEEABKeyID, &site.ACMEEABHMACKey, &site.ACMECARoot, &site.DNSProviderID,
+			&site.CanonicalHost, &site.ForceHTTPS,
+			&site.Precompressed, &site.CacheHashedAssets, &site.RedirectStatus, &site.RedirectKeepPath); err != nil {
 			continue
 		}
 		if env != nil {
@@ -40,6 +51,7 @@ func SitesHandler(w http.ResponseWriter, r *http.Request) {
 		if phpVer != nil {
 			site.PHPVersion = *phpVer
 		}
+		site.ACMEEABHMACKey = maskSecret(site.ACMEEABHMACKey)
 		sites = append(sites, site)
 	}
 
@@ -47,63 +59,140 @@ func SitesHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(sites)
 }
 
+// newSite 未提交的选项使用默认值：跳转到 HTTPS，单页应用开启预压缩和资源缓存，跳转站点 301 并保留路径
+func newSite() models.Site {
+	return models.Site{ForceHTTPS: true, Precompressed: true, CacheHashedAssets: true, RedirectStatus: 301, RedirectKeepPath: true}
+}
+
 func AddSiteHandler(w http.ResponseWriter, r *http.Request) {
-	var site models.Site
+	site := newSite()
 	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	db := database.GetDB()
-	_, err := db.Exec("INSERT INTO sites (domain, type, target, ssl_enabled, environment, php_version) VALUES (?, ?, ?, ?, ?, ?)",
-		site.Domain, site.Type, site.Target, site.SSLEnabled, site.Environment, site.PHPVersion)
+	// 保存前检查域名和 HTTPS 设置是否与其他站点、项目冲突
+	site.ID = 0
+	if err := checkSiteRoutes(&site); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	generateCaddyfile()
-	caddy.Restart()
+	defer tx.Rollback()
+	result, err := tx.Exec(`INSERT INTO sites (domain, type, target, ssl_enabled, environment, php_version, tls_mode, ssl_email,
+		acme_ca, acme_ca_url, acme_eab_key_id, acme_eab_hmac_key, acme_ca_root, dns_provider_id, canonical_host, force_https,
+		precompressed, cache_hashed_assets, redirect_status, redirect_keep_path)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		site.Domain, site.Type, site.Target, site.SSLEnabled, site.Environment, site.PHPVersion, site.TLSMode, site.SSLEmail,
+		site.ACMECA, site.ACMECAURL, site.ACMEEABKeyID, site.ACMEEABHMACKey, site.ACMECARoot, site.DNSProviderID, site.CanonicalHost, site.ForceHTTPS,
+		site.Precompressed, site.CacheHashedAssets, site.RedirectStatus, site.RedirectKeepPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	siteID, _ := result.LastInsertId()
+	if err := replaceDomains(tx, "site", int(siteID), "", []string{site.Domain}); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
 func EditSiteHandler(w http.ResponseWriter, r *http.Request) {
-	var site models.Site
+	site := newSite()
 	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	db := database.GetDB()
-	_, err := db.Exec("UPDATE sites SET domain=?, type=?, target=?, ssl_enabled=?, environment=?, php_version=?, updated_at=CURRENT_TIMESTAMP WHERE id=?",
-		site.Domain, site.Type, site.Target, site.SSLEnabled, site.Environment, site.PHPVersion, site.ID)
+	site.ACMEEABHMACKey = storedEABKey("sites", site.ID, site.ACMEEABHMACKey)
+	if err := checkSiteRoutes(&site); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	generateCaddyfile()
-	caddy.Restart()
+	defer tx.Rollback()
+	result, err := tx.Exec(`UPDATE sites SET domain=?, type=?, target=?, ssl_enabled=?, environment=?, php_version=?, tls_mode=?, ssl_email=?,
+		acme_ca=?, acme_ca_url=?, acme_eab_key_id=?, acme_eab_hmac_key=?, acme_ca_root=?, dns_provider_id=?,
+		canonical_host=?, force_https=?, precompressed=?, cache_hashed_assets=?, redirect_status=?, redirect_keep_path=?,
+		updated_at=CURRENT_TIMESTAMP WHERE id=?`,
+		site.Domain, site.Type, site.Target, site.SSLEnabled, site.Environment, site.PHPVersion, site.TLSMode, site.SSLEmail,
+		site.ACMECA, site.ACMECAURL, site.ACMEEABKeyID, site.ACMEEABHMACKey, site.ACMECARoot, site.DNSProviderID,
+		site.CanonicalHost, site.ForceHTTPS, site.Precompressed, site.CacheHashedAssets, site.RedirectStatus, site.RedirectKeepPath, site.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 站点不存在时不写入域名，避免留下不属于任何站点的域名记录
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		http.Error(w, "站点不存在", http.StatusNotFound)
+		return
+	}
+	if err := replaceDomains(tx, "site", site.ID, "", []string{site.Domain}); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
 func DeleteSiteHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
-	db := database.GetDB()
-	_, err := db.Exec("DELETE FROM sites WHERE id=?", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// 站点和依附于它的设置在同一个事务中删除，配置应用成功后才提交
+	if err := deleteOwner(id, []string{
+		"DELETE FROM sites WHERE id=?",
+		"DELETE FROM redirect_rules WHERE site_id=?",
+		"DELETE FROM basic_auth_users WHERE owner_type='site' AND owner_id=?",
+		"DELETE FROM ip_access_rules WHERE owner_type='site' AND owner_id=?",
+		"DELETE FROM header_rules WHERE owner_type='site' AND owner_id=?",
+		"DELETE FROM upstream_transports WHERE owner_type='site' AND owner_id=?",
+		"DELETE FROM maintenance WHERE owner_type='site' AND owner_id=?",
+		"DELETE FROM domains WHERE owner_type='site' AND owner_id=?",
+	}); err != nil {
+		writeCaddyError(w, err)
 		return
 	}
-
-	generateCaddyfile()
-	caddy.Restart()
+	if siteID, err := strconv.Atoi(id); err == nil && siteID > 0 {
+		os.RemoveAll(errorPagesDir("site", siteID))
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// deleteOwner 在一个事务中执行删除语句（参数均为 id），生成并应用新配置后提交
+func deleteOwner(id interface{}, statements []string) error {
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return applyTx(tx)
+}
+
 func CaddyStatusHandler(w http.ResponseWriter, r *http.Request) {
 	running := caddy.IsRunning()
 	version := caddy.GetVersion()
@@ -134,7 +223,7 @@ func CaddySSLStatusHandler(w http.ResponseWriter, r *http.Request) {
 			errors = append(errors, "连接超时，请检查网络和防火墙设置")
 		}
 		if contains(logContent, "rate limit") {
-			errors = append(errors, "证书申请频率限制，请稍后再试")
+			errors = append(errors, "证书申请频率限制，请稍后再试；测试时可在设置中将 ACME CA 切换为 Let's Encrypt 测试环境 (staging)")
 		}
 		if contains(logContent, "unauthorized") {
 			errors = append(errors, "域名验证失败，请确认域名已正确解析")
@@ -231,12 +320,7 @@ func CaddyRestartHandler(w http.ResponseWriter, r *http.Request) {
 
 func CaddyReloadHandler(w http.ResponseWriter, r *http.Request) {
 	if err := caddy.Reload(); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		})
+		writeCaddyError(w, err)
 		return
 	}
 	
@@ -247,6 +331,111 @@ func CaddyReloadHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// CaddyHistoryHandler 列出历史可用配置
+func CaddyHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	history, err := caddy.ListHistory()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// CaddyHistoryRestoreHandler 恢复指定的历史配置
+func CaddyHistoryRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if err := caddy.RestoreHistory(name); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "已恢复历史配置",
+	})
+}
+
+// writeCaddyError 返回 Caddy 配置校验或加载失败的详细信息，
+// 附带 caddy validate/adapt 的输出和管理 API 的结构化错误，便于前端展示具体原因
+func writeCaddyError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	response := map[string]interface{}{
+		"success": false,
+		"error":   err.Error(),
+	}
+	
+	var validationErr *caddy.ValidationError
+	var adminErr *caddy.AdminError
+	var adaptErr *caddy.AdaptError
+	var reloadErr *caddy.ReloadError
+	var dupErr *caddyfile.DuplicateHostError
+	var hostErr *caddyfile.HostError
+	var pathConflict *caddyfile.PathConflictError
+	var pathErr *caddyfile.PathError
+	var optErr *caddyfile.OptionError
+	var certErr *certs.CertError
+	var loopErr *caddyfile.RedirectLoopError
+	var moduleErr *caddy.MissingModuleError
+	if errors.As(err, &dupErr) {
+		status = http.StatusConflict
+		response["code"] = "DUPLICATE_HOST"
+		response["duplicate"] = dupErr
+	} else if errors.As(err, &hostErr) {
+		status = http.StatusBadRequest
+		response["code"] = "INVALID_HOST"
+		response["host_error"] = hostErr
+	} else if errors.As(err, &pathConflict) {
+		status = http.StatusConflict
+		response["code"] = "PATH_CONFLICT"
+		response["conflict"] = pathConflict
+	} else if errors.As(err, &pathErr) {
+		status = http.StatusBadRequest
+		response["code"] = "INVALID_PATH"
+		response["path_error"] = pathErr
+	} else if errors.As(err, &optErr) {
+		status = http.StatusBadRequest
+		response["code"] = "INVALID_OPTION"
+		response["option_error"] = optErr
+	} else if errors.As(err, &certErr) {
+		status = http.StatusBadRequest
+		response["code"] = "INVALID_CERT"
+		response["cert_error"] = certErr
+	} else if errors.As(err, &loopErr) {
+		status = http.StatusConflict
+		response["code"] = "REDIRECT_LOOP"
+		response["loop"] = loopErr
+	} else if errors.As(err, &moduleErr) {
+		status = http.StatusUnprocessableEntity
+		response["code"] = "MISSING_MODULE"
+		response["module_error"] = moduleErr
+	} else if errors.As(err, &validationErr) {
+		status = http.StatusUnprocessableEntity
+		response["code"] = "VALIDATION_ERROR"
+		response["validation_error"] = validationErr
+	} else if errors.As(err, &adminErr) {
+		response["code"] = "ADMIN_API_ERROR"
+		response["admin_error"] = adminErr
+	} else if errors.As(err, &adaptErr) {
+		response["code"] = "ADAPT_ERROR"
+		response["adapt_error"] = adaptErr
+	}
+	if errors.As(err, &reloadErr) {
+		response["rolled_back"] = reloadErr.RolledBack
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
 func SetupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
 		isFirst := database.IsFirstRun()
@@ -367,39 +556,3 @@ func ShutdownHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 }
-
-func generateCaddyfile() error {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT domain, type, target, ssl_enabled, environment, php_version FROM sites")
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	var content string
-	for rows.Next() {
-		var domain, siteType, target string
-		var environment, phpVersion *string
-		var sslEnabled bool
-		rows.Scan(&domain, &siteType, &target, &sslEnabled, &environment, &phpVersion)
-
-		if siteType == "proxy" {
-			content += domain + " {\n"
-			content += "    reverse_proxy " + target + "\n"
-			content += "}\n\n"
-		} else if siteType == "static" {
-			content += domain + " {\n"
-			content += "    root * " + target + "\n"
-			content += "    file_server\n"
-			content += "}\n\n"
-		} else if siteType == "php" {
-			content += domain + " {\n"
-			content += "    root * " + target + "\n"
-			content += "    php_fastcgi localhost:9000\n"
-			content += "    file_server\n"
-			content += "}\n\n"
-		}
-	}
-
-	return os.WriteFile(config.CaddyConfig, []byte(content), 0644)
-}
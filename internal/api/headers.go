@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// HeaderRulesHandler 列出站点或项目的请求头/响应头规则
+// 参数: ?owner_type=site|project&owner_id=1
+func HeaderRulesHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	rows, err := database.GetDB().Query(`SELECT id, owner_type, owner_id, direction, operation, name, value, created_at FROM header_rules
+		WHERE owner_type = ? AND owner_id = ? ORDER BY id`, r.URL.Query().Get("owner_type"), ownerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	rules := []models.HeaderRule{}
+	for rows.Next() {
+		var h models.HeaderRule
+		if err := rows.Scan(&h.ID, &h.OwnerType, &h.OwnerID, &h.Direction, &h.Operation, &h.Name, &h.Value, &h.CreatedAt); err != nil {
+			continue
+		}
+		rules = append(rules, h)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// SaveHeaderRuleHandler 添加或修改请求头/响应头规则
+// 请求体: {"id": 0, "owner_type": "site", "owner_id": 1, "direction": "response", "operation": "set", "name": "X-Frame-Options", "value": "DENY"}
+func SaveHeaderRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var h models.HeaderRule
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.Name = strings.TrimSpace(h.Name)
+	h.Value = strings.TrimSpace(h.Value)
+	if h.Operation == "" {
+		h.Operation = "set"
+	}
+
+	header := caddyfile.Header{Direction: h.Direction, Operation: h.Operation, Name: h.Name, Value: h.Value}
+	if err := checkOwnerRoutes("请求头规则", h.OwnerType, h.OwnerID, func(route *caddyfile.Route) {
+		route.Headers = append(route.Headers, header)
+	}); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if h.ID > 0 {
+		_, err = tx.Exec("UPDATE header_rules SET direction=?, operation=?, name=?, value=? WHERE id=? AND owner_type=? AND owner_id=?",
+			h.Direction, h.Operation, h.Name, h.Value, h.ID, h.OwnerType, h.OwnerID)
+	} else {
+		_, err = tx.Exec("INSERT INTO header_rules (owner_type, owner_id, direction, operation, name, value) VALUES (?, ?, ?, ?, ?, ?)",
+			h.OwnerType, h.OwnerID, h.Direction, h.Operation, h.Name, h.Value)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "请求头规则已保存",
+	})
+}
+
+// DeleteHeaderRuleHandler 删除请求头/响应头规则
+func DeleteHeaderRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM header_rules WHERE id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HeaderPresetsHandler 列出可一键添加的响应头预设
+func HeaderPresetsHandler(w http.ResponseWriter, r *http.Request) {
+	presets := []map[string]interface{}{}
+	for _, key := range caddyfile.HeaderPresetKeys() {
+		preset := caddyfile.HeaderPresets[key]
+		presets = append(presets, map[string]interface{}{
+			"key":         key,
+			"name":        preset.Name,
+			"description": preset.Description,
+			"headers":     preset.Headers,
+			"static_only": preset.StaticOnly,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presets)
+}
+
+// ApplyHeaderPresetHandler 为站点或项目添加一组预设响应头，已有的同名响应头规则会被替换
+// 请求体: {"owner_type": "site", "owner_id": 1, "preset": "security"}
+func ApplyHeaderPresetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		OwnerType string `json:"owner_type"`
+		OwnerID   int    `json:"owner_id"`
+		Preset    string `json:"preset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	preset, ok := caddyfile.HeaderPresets[req.Preset]
+	if !ok {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "请求头规则", Option: "preset", Value: req.Preset,
+			Reason: "可选值: " + strings.Join(caddyfile.HeaderPresetKeys(), ", ")})
+		return
+	}
+	if preset.StaticOnly {
+		var siteType string
+		if req.OwnerType == "site" {
+			database.GetDB().QueryRow("SELECT type FROM sites WHERE id = ?", req.OwnerID).Scan(&siteType)
+		}
+		if siteType != "static" && siteType != "spa" {
+			writeCaddyError(w, &caddyfile.OptionError{Owner: "请求头规则", Option: "preset", Value: req.Preset,
+				Reason: fmt.Sprintf("%s只适用于静态文件和单页应用站点", preset.Name)})
+			return
+		}
+	}
+
+	if err := checkOwnerRoutes("请求头规则", req.OwnerType, req.OwnerID, func(route *caddyfile.Route) {
+		route.Headers = append(route.Headers, preset.Headers...)
+	}); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for _, h := range preset.Headers {
+		if _, err := tx.Exec("DELETE FROM header_rules WHERE owner_type=? AND owner_id=? AND direction=? AND name=? COLLATE NOCASE",
+			req.OwnerType, req.OwnerID, h.Direction, h.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec("INSERT INTO header_rules (owner_type, owner_id, direction, operation, name, value) VALUES (?, ?, ?, ?, ?, ?)",
+			req.OwnerType, req.OwnerID, h.Direction, h.Operation, h.Name, h.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("已添加%s", preset.Name),
+	})
+}
+
+// headersFor 读取站点或项目的请求头/响应头规则，按添加顺序排列
+func headersFor(q database.Querier, ownerType string, ownerID int) ([]caddyfile.Header, error) {
+	if ownerID == 0 {
+		return nil, nil
+	}
+	rows, err := q.Query(`SELECT direction, operation, name, value FROM header_rules
+		WHERE owner_type = ? AND owner_id = ? ORDER BY id`, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []caddyfile.Header
+	for rows.Next() {
+		var h caddyfile.Header
+		if err := rows.Scan(&h.Direction, &h.Operation, &h.Name, &h.Value); err != nil {
+			return nil, err
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
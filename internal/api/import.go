@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+)
+
+// ImportReport 导入结果报告
+type ImportReport struct {
+	DryRun   bool                 `json:"dry_run"`
+	Sites    []ImportedItem       `json:"sites"`
+	Projects []ImportedItem       `json:"projects"`
+	Skipped  []ImportedItem       `json:"skipped"`
+	Unmapped []caddyfile.Unmapped `json:"unmapped"`
+}
+
+// ImportedItem 导入的一条记录
+type ImportedItem struct {
+	Host   string `json:"host"`
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	ID     int    `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ImportCaddyfileHandler 导入现有 Caddyfile（或 caddy adapt 输出的 JSON）
+// 请求体: {"content": "...", "path": "...", "dry_run": true}；两者都为空时导入当前的 Caddyfile
+func ImportCaddyfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+		Path    string `json:"path"`
+		DryRun  bool   `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data := []byte(req.Content)
+	if req.Content == "" {
+		path := req.Path
+		if path == "" {
+			path = config.CaddyConfig
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			http.Error(w, "读取配置文件失败: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := ImportCaddyfile(data, req.DryRun, true)
+	if err != nil {
+		var validationErr *caddy.ValidationError
+		if report == nil && !errors.As(err, &validationErr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// ImportCaddyfile 将配置中的站点写入数据库：本机端口的反向代理如果与已有项目端口一致，
+// 则把域名追加到该项目，其余写入 sites 表；已存在的域名会被跳过。
+// 所有记录在一个事务中写入，中途出错时不导入任何记录。reload 为 false 时只写入新的 Caddyfile，不重新加载 Caddy。
+// 解析失败、生成的配置未通过校验或未写入数据库时 report 为 nil，写入后应用配置失败时同时返回 report 和错误
+func ImportCaddyfile(data []byte, dryRun, reload bool) (*ImportReport, error) {
+	result, err := caddyfile.Import(data)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{
+		DryRun:   dryRun,
+		Sites:    []ImportedItem{},
+		Projects: []ImportedItem{},
+		Skipped:  []ImportedItem{},
+		Unmapped: result.Unmapped,
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changed := false
+	seen := make(map[string]bool)
+	for _, site := range result.Sites {
+		item := ImportedItem{Host: site.Host, Type: site.Type, Target: site.Target}
+
+		host, err := caddyfile.NormalizeHost(site.Host)
+		if err != nil {
+			item.Reason = err.Error()
+			report.Skipped = append(report.Skipped, item)
+			continue
+		}
+		if seen[host] {
+			item.Reason = "配置中重复出现的域名"
+			report.Skipped = append(report.Skipped, item)
+			continue
+		}
+		seen[host] = true
+
+		if owner, exists := domainOwner(host); exists {
+			item.Reason = "域名已被" + owner + "使用"
+			report.Skipped = append(report.Skipped, item)
+			continue
+		}
+
+		if site.Type == "proxy" {
+			if port, ok := caddyfile.LocalPort(site.Target); ok {
+				var id int
+				var name string
+				var domains, proxyPath *string
+				err := tx.QueryRow("SELECT id, name, domains, reverse_proxy_path FROM projects WHERE port = ? ORDER BY id LIMIT 1", port).
+					Scan(&id, &name, &domains, &proxyPath)
+				if err == nil {
+					item.ID, item.Name = id, name
+					if !dryRun {
+						current := ""
+						if domains != nil {
+							current = strings.TrimSpace(*domains)
+						}
+						if current != "" {
+							current += "\n"
+						}
+						path := ""
+						if proxyPath != nil {
+							path, _ = caddyfile.NormalizePath(*proxyPath)
+						}
+						if err := insertDomain(tx, "project", id, path, host); err != nil {
+							return nil, err
+						}
+						if _, err := tx.Exec("UPDATE projects SET domains = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", current+host, id); err != nil {
+							return nil, err
+						}
+						changed = true
+					}
+					report.Projects = append(report.Projects, item)
+					continue
+				}
+			}
+		}
+
+		if site.Type == "php" && site.PHPAddress != "localhost:9000" && site.PHPAddress != "127.0.0.1:9000" {
+			report.Unmapped = append(report.Unmapped, caddyfile.Unmapped{
+				Block:     site.Host,
+				Directive: "php_fastcgi " + site.PHPAddress,
+				Line:      site.Line,
+				Reason:    "PHP 站点转发到对应版本的 PHP 进程池，没有进程池时使用 localhost:9000，请确认 PHP-FPM 地址",
+			})
+		}
+
+		if !dryRun {
+			res, err := tx.Exec("INSERT INTO sites (domain, type, target, ssl_enabled, tls_mode) VALUES (?, ?, ?, ?, ?)",
+				host, site.Type, site.Target, site.SSLEnabled, site.TLSMode)
+			if err != nil {
+				return nil, err
+			}
+			if id, err := res.LastInsertId(); err == nil {
+				item.ID = int(id)
+			}
+			if err := insertDomain(tx, "site", item.ID, "", host); err != nil {
+				return nil, err
+			}
+			changed = true
+		}
+		report.Sites = append(report.Sites, item)
+	}
+
+	if changed {
+		// 导入后的配置通过校验才提交，未通过时不导入任何记录，原文件保持不变
+		content, env, err := validateTx(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		// 生成的配置会覆盖原文件，先保留一份导入前的副本，无法备份时不导入
+		original, err := os.ReadFile(config.CaddyConfig)
+		if err == nil {
+			err = os.WriteFile(config.CaddyConfig+".before-import", original, 0644)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("备份原配置失败，未导入任何记录: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		if reload {
+			err = applyLatest(content, env)
+		} else {
+			err = writeCaddyfile()
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/config"
+)
+
+func TestImportCaddyfileRejected(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+	if err := os.WriteFile(config.CaddyConfig, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 导入后的配置未通过 caddy validate 时不写入任何记录，原配置不被覆盖也不备份
+	data := []byte("shop.example.com {\n\treverse_proxy localhost:8080\n}\nreject.example.com {\n\treverse_proxy localhost:8081\n}\n")
+	for _, reload := range []bool{false, true} {
+		report, err := ImportCaddyfile(data, false, reload)
+		var validationErr *caddy.ValidationError
+		if report != nil || !errors.As(err, &validationErr) {
+			t.Fatalf("reload=%v: report = %+v, err = %v; 期望校验失败", reload, report, err)
+		}
+		if n := countSites(t, "shop.example.com"); n != 0 {
+			t.Errorf("reload=%v: 校验失败后仍导入了 %d 个站点", reload, n)
+		}
+		if got := readFile(t, config.CaddyConfig); got != "original" {
+			t.Errorf("reload=%v: 配置 = %q; 期望保持原内容", reload, got)
+		}
+		if _, err := os.Stat(config.CaddyConfig + ".before-import"); !os.IsNotExist(err) {
+			t.Errorf("reload=%v: 未导入时不应备份原配置: %v", reload, err)
+		}
+	}
+
+	report, err := ImportCaddyfile(data[:len("shop.example.com {\n\treverse_proxy localhost:8080\n}\n")], false, false)
+	if err != nil || len(report.Sites) != 1 {
+		t.Fatalf("report = %+v, err = %v", report, err)
+	}
+	if got := readFile(t, config.CaddyConfig+".before-import"); got != "original" {
+		t.Errorf("备份 = %q; 期望 original", got)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// IPAccessRulesHandler 列出站点或项目的 IP 访问规则
+// 参数: ?owner_type=site|project&owner_id=1
+func IPAccessRulesHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	rows, err := database.GetDB().Query(`SELECT id, owner_type, owner_id, path, allow, deny, status, body, created_at FROM ip_access_rules
+		WHERE owner_type = ? AND owner_id = ? ORDER BY path`, r.URL.Query().Get("owner_type"), ownerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	rules := []models.IPAccessRule{}
+	for rows.Next() {
+		var rule models.IPAccessRule
+		var allow, deny string
+		if err := rows.Scan(&rule.ID, &rule.OwnerType, &rule.OwnerID, &rule.Path, &allow, &deny, &rule.Status, &rule.Body, &rule.CreatedAt); err != nil {
+			continue
+		}
+		rule.Allow, rule.Deny = splitIPList(allow), splitIPList(deny)
+		rules = append(rules, rule)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// SaveIPAccessRuleHandler 保存 IP 访问规则，同一路径只有一条规则，已存在时覆盖
+// 请求体: {"owner_type": "site", "owner_id": 1, "path": "/admin", "allow": ["10.0.0.0/8"], "deny": [], "status": 403, "body": ""}
+func SaveIPAccessRuleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rule models.IPAccessRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path, err := caddyfile.NormalizePath(rule.Path)
+	if err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	rule.Path = path
+	rule.Allow, rule.Deny = cleanIPList(rule.Allow), cleanIPList(rule.Deny)
+	if rule.Status == 0 {
+		rule.Status = http.StatusForbidden
+	}
+	rule.Body = strings.TrimSpace(rule.Body)
+
+	if err := checkIPAccessRule(rule); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO ip_access_rules (owner_type, owner_id, path, allow, deny, status, body) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(owner_type, owner_id, path) DO UPDATE SET allow = excluded.allow, deny = excluded.deny, status = excluded.status, body = excluded.body`,
+		rule.OwnerType, rule.OwnerID, rule.Path, strings.Join(rule.Allow, "\n"), strings.Join(rule.Deny, "\n"), rule.Status, rule.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "IP 访问规则已保存",
+	})
+}
+
+// DeleteIPAccessRuleHandler 删除 IP 访问规则
+func DeleteIPAccessRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM ip_access_rules WHERE id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ipAccessFor 读取站点或项目的 IP 访问规则
+func ipAccessFor(q database.Querier, ownerType string, ownerID int) ([]caddyfile.IPAccess, error) {
+	if ownerID == 0 {
+		return nil, nil
+	}
+	rows, err := q.Query(`SELECT path, allow, deny, status, body FROM ip_access_rules
+		WHERE owner_type = ? AND owner_id = ? ORDER BY path`, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []caddyfile.IPAccess
+	for rows.Next() {
+		var access caddyfile.IPAccess
+		var allow, deny string
+		if err := rows.Scan(&access.Path, &allow, &deny, &access.Status, &access.Body); err != nil {
+			return nil, err
+		}
+		access.Allow, access.Deny = splitIPList(allow), splitIPList(deny)
+		list = append(list, access)
+	}
+	return list, rows.Err()
+}
+
+// checkIPAccessRule 用候选规则替换同一路径的规则后检查整个配置
+func checkIPAccessRule(rule models.IPAccessRule) error {
+	candidate := caddyfile.IPAccess{Path: rule.Path, Allow: rule.Allow, Deny: rule.Deny, Status: rule.Status, Body: rule.Body}
+	return checkOwnerRoutes("IP 访问规则", rule.OwnerType, rule.OwnerID, func(route *caddyfile.Route) {
+		list := []caddyfile.IPAccess{}
+		for _, access := range route.IPAccess {
+			if access.Path != rule.Path {
+				list = append(list, access)
+			}
+		}
+		route.IPAccess = append(list, candidate)
+	})
+}
+
+// cleanIPList 去掉空白项和重复项
+func cleanIPList(list []string) []string {
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// splitIPList 数据库中以换行分隔的地址列表
+func splitIPList(s string) []string {
+	return cleanIPList(strings.Split(s, "\n"))
+}
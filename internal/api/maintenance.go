@@ -0,0 +1,444 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// defaultRetryAfter 未设置结束时间时 Retry-After 的默认秒数
+const defaultRetryAfter = 3600
+
+// defaultMaintenanceTemplate 默认维护页面，可通过 /api/maintenance/template 自定义。
+// 可用变量: .Title（域名或项目名）、.Message、.Until（预计恢复时间，可能为空）
+const defaultMaintenanceTemplate = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - 维护中</title>
+<style>
+body { margin: 0; min-height: 100vh; display: flex; align-items: center; justify-content: center;
+  font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; background: #f5f7fa; color: #333; }
+.box { max-width: 480px; padding: 40px; background: #fff; border-radius: 12px; box-shadow: 0 4px 20px rgba(0,0,0,.08); text-align: center; }
+h1 { font-size: 24px; margin: 0 0 16px; }
+p { line-height: 1.7; color: #666; margin: 8px 0; }
+</style>
+</head>
+<body>
+<div class="box">
+<h1>🛠️ {{.Title}} 正在维护</h1>
+<p>{{if .Message}}{{.Message}}{{else}}我们正在进行系统升级，请稍后再访问。{{end}}</p>
+{{if .Until}}<p>预计恢复时间：{{.Until}}</p>{{end}}
+</div>
+</body>
+</html>
+`
+
+// maintenanceWake 保存维护设置后唤醒调度器重新计算下一个时间点
+var maintenanceWake = make(chan struct{}, 1)
+
+// MaintenanceHandler 获取站点或项目的维护模式设置和当前状态
+// 参数: ?owner_type=site|project&owner_id=1
+func MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	m, err := loadMaintenance(database.GetDB(), r.URL.Query().Get("owner_type"), ownerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"maintenance": m,
+		"status":      maintenanceStatus(m, time.Now()),
+		"bypass":      maintenanceBypass(database.GetDB()),
+	})
+}
+
+// SaveMaintenanceHandler 保存维护模式设置。开始、结束时间可选，支持 RFC 3339 或 "2006-01-02T15:04"（本地时间）
+// 请求体: {"owner_type": "project", "owner_id": 1, "enabled": true, "starts_at": "", "ends_at": "2026-10-18T02:00", "message": "", "retry_after": 3600}
+func SaveMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var m models.Maintenance
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.Message = strings.TrimSpace(m.Message)
+	if m.RetryAfter <= 0 {
+		m.RetryAfter = defaultRetryAfter
+	}
+
+	start, err := parseMaintenanceTime("starts_at", m.StartsAt)
+	if err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	end, err := parseMaintenanceTime("ends_at", m.EndsAt)
+	if err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "维护模式", Option: "ends_at", Value: m.EndsAt, Reason: "结束时间必须晚于开始时间"})
+		return
+	}
+	m.StartsAt, m.EndsAt = formatMaintenanceTime(start), formatMaintenanceTime(end)
+
+	if err := checkOwnerRoutes("维护模式", m.OwnerType, m.OwnerID, func(route *caddyfile.Route) {}); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO maintenance (owner_type, owner_id, enabled, starts_at, ends_at, message, retry_after) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(owner_type, owner_id) DO UPDATE SET enabled = excluded.enabled, starts_at = excluded.starts_at, ends_at = excluded.ends_at,
+		message = excluded.message, retry_after = excluded.retry_after, updated_at = CURRENT_TIMESTAMP`,
+		m.OwnerType, m.OwnerID, m.Enabled, m.StartsAt, m.EndsAt, m.Message, m.RetryAfter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	select {
+	case maintenanceWake <- struct{}{}:
+	default:
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"status":  maintenanceStatus(&m, time.Now()),
+		"message": "维护模式设置已保存",
+	})
+}
+
+// MaintenanceTemplateHandler GET 返回维护页面模板，POST 保存自定义模板，内容为空时恢复默认模板
+// 请求体: {"content": "<!DOCTYPE html>..."}
+func MaintenanceTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	path := filepath.Join(maintenanceDir(), "template.html")
+
+	if r.Method != http.MethodPost {
+		content, custom := defaultMaintenanceTemplate, false
+		if data, err := os.ReadFile(path); err == nil {
+			content, custom = string(data), true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"content": content,
+			"custom":  custom,
+			"path":    path,
+		})
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	custom := strings.TrimSpace(req.Content) != ""
+	if custom {
+		if _, err := template.New("maintenance").Parse(req.Content); err != nil {
+			writeCaddyError(w, &caddyfile.OptionError{Owner: "维护模式", Option: "template", Value: "template.html", Reason: err.Error()})
+			return
+		}
+		if err := os.MkdirAll(maintenanceDir(), 0755); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// 重新生成配置时会用新模板重新生成正在维护的页面，未通过校验时恢复原来的模板
+	if err := applyFileChange(func() error {
+		if custom {
+			return os.WriteFile(path, []byte(req.Content), 0644)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}, path); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "维护页面模板已保存",
+	})
+}
+
+// RunMaintenanceScheduler 在维护时间窗口开始和结束时重新生成配置。
+// 保存设置时已重新生成配置，被唤醒时只重新计算下一个时间点
+func RunMaintenanceScheduler() {
+	runMaintenanceScheduler(nil)
+}
+
+// runMaintenanceScheduler 运行维护调度器，直到 stop 被关闭
+func runMaintenanceScheduler(stop <-chan struct{}) {
+	// 与上次生成配置时的维护状态比较：管理器停止期间可能已经过了维护窗口的开始或结束时间
+	last := database.GetSetting("maintenance_state")
+	startup := true
+	woke := false
+	for {
+		key, next, err := maintenanceState(time.Now())
+		if err != nil {
+			log.Printf("读取维护设置失败: %v", err)
+		} else if key != last {
+			switch {
+			case woke:
+				// 保存维护设置时已重新生成配置
+				saveMaintenanceState(key)
+			case startup:
+				if regenerateAtStartup() {
+					saveMaintenanceState(key)
+				}
+			default:
+				log.Println("🛠️  维护时间窗口变化，重新生成配置...")
+				if err := generateCaddyfile(); err != nil {
+					log.Printf("⚠️  重新生成配置失败: %v", err)
+				} else {
+					saveMaintenanceState(key)
+				}
+			}
+			last = key
+		}
+		startup = false
+
+		wait := time.Hour
+		if !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next) + time.Second
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			woke = false
+		case <-maintenanceWake:
+			timer.Stop()
+			woke = true
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// regenerateAtStartup 维护状态在管理器停止期间发生变化时重新生成配置。配置文件不存在时交给 Caddy 启动时创建，
+// 不是管理器生成的（手动维护或等待导入）时不覆盖；Caddy 尚未启动时只写入配置文件，由随后的启动加载
+func regenerateAtStartup() bool {
+	data, err := os.ReadFile(config.CaddyConfig)
+	if err != nil {
+		return false
+	}
+	if !caddyfile.IsGenerated(data) {
+		log.Println("⚠️  维护状态已变化，但配置文件不是由管理器生成的，未自动覆盖")
+		return false
+	}
+
+	log.Println("🛠️  维护状态在管理器停止期间发生变化，重新生成配置...")
+	generate := generateCaddyfile
+	if !caddy.IsRunning() {
+		generate = writeCaddyfile
+	}
+	if err := generate(); err != nil {
+		log.Printf("⚠️  重新生成配置失败: %v", err)
+		return false
+	}
+	return true
+}
+
+// saveMaintenanceState 记录已写入配置的维护状态，供下次启动时比较
+func saveMaintenanceState(key string) {
+	if err := database.SetSetting("maintenance_state", key); err != nil {
+		log.Printf("保存维护状态失败: %v", err)
+	}
+}
+
+// maintenanceState 返回当前处于维护中的站点和项目（用于比较是否变化）以及下一个开始或结束时间
+func maintenanceState(now time.Time) (string, time.Time, error) {
+	rows, err := database.GetDB().Query(`SELECT owner_type, owner_id, enabled, starts_at, ends_at, message, retry_after FROM maintenance WHERE enabled = 1`)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer rows.Close()
+
+	var active []string
+	var next time.Time
+	for rows.Next() {
+		var m models.Maintenance
+		if err := rows.Scan(&m.OwnerType, &m.OwnerID, &m.Enabled, &m.StartsAt, &m.EndsAt, &m.Message, &m.RetryAfter); err != nil {
+			return "", time.Time{}, err
+		}
+		if maintenanceStatus(&m, now) == "active" {
+			active = append(active, fmt.Sprintf("%s-%d", m.OwnerType, m.OwnerID))
+		}
+		for _, value := range []string{m.StartsAt, m.EndsAt} {
+			if t, err := time.Parse(time.RFC3339, value); err == nil && t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	sort.Strings(active)
+	return strings.Join(active, ","), next, rows.Err()
+}
+
+// maintenanceFor 站点或项目处于维护时间窗口内时生成维护页面，返回路由的维护设置
+func maintenanceFor(q database.Querier, ownerType string, ownerID int, title string) (*caddyfile.Maintenance, error) {
+	if ownerID == 0 {
+		return nil, nil
+	}
+	m, err := loadMaintenance(q, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if maintenanceStatus(m, time.Now()) != "active" {
+		return nil, nil
+	}
+
+	retryAfter := strconv.Itoa(m.RetryAfter)
+	var until string
+	if end, err := time.Parse(time.RFC3339, m.EndsAt); err == nil {
+		retryAfter = end.UTC().Format(http.TimeFormat)
+		until = end.Local().Format("2006-01-02 15:04")
+	}
+
+	file := fmt.Sprintf("%s-%d.html", ownerType, ownerID)
+	if err := writeMaintenancePage(file, title, m.Message, until); err != nil {
+		return nil, err
+	}
+	return &caddyfile.Maintenance{
+		Root:       maintenanceDir(),
+		File:       file,
+		RetryAfter: retryAfter,
+		Bypass:     maintenanceBypass(q),
+	}, nil
+}
+
+// loadMaintenance 读取维护设置，没有记录时返回未启用的默认设置
+func loadMaintenance(q database.Querier, ownerType string, ownerID int) (*models.Maintenance, error) {
+	m := &models.Maintenance{OwnerType: ownerType, OwnerID: ownerID, RetryAfter: defaultRetryAfter}
+	err := q.QueryRow(`SELECT enabled, starts_at, ends_at, message, retry_after FROM maintenance WHERE owner_type = ? AND owner_id = ?`,
+		ownerType, ownerID).Scan(&m.Enabled, &m.StartsAt, &m.EndsAt, &m.Message, &m.RetryAfter)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return m, nil
+}
+
+// maintenanceStatus 返回维护状态: off（未启用）/ scheduled（等待开始）/ active / ended（已过结束时间）
+func maintenanceStatus(m *models.Maintenance, now time.Time) string {
+	if !m.Enabled {
+		return "off"
+	}
+	if start, err := time.Parse(time.RFC3339, m.StartsAt); err == nil && now.Before(start) {
+		return "scheduled"
+	}
+	if end, err := time.Parse(time.RFC3339, m.EndsAt); err == nil && !now.Before(end) {
+		return "ended"
+	}
+	return "active"
+}
+
+// parseMaintenanceTime 解析 RFC 3339 或浏览器 datetime-local 格式（按本地时间），空字符串返回零值
+func parseMaintenanceTime(option, value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, &caddyfile.OptionError{Owner: "维护模式", Option: option, Value: value, Reason: "应为时间，如 2026-10-18T02:00"}
+}
+
+func formatMaintenanceTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// maintenanceDir 维护页面目录，自定义模板保存为其中的 template.html
+func maintenanceDir() string {
+	return filepath.Join(config.DataDir, "maintenance")
+}
+
+// writeMaintenancePage 用自定义模板（没有时用默认模板）生成维护页面
+func writeMaintenancePage(file, title, message, until string) error {
+	content := defaultMaintenanceTemplate
+	if data, err := os.ReadFile(filepath.Join(maintenanceDir(), "template.html")); err == nil {
+		content = string(data)
+	}
+	tmpl, err := template.New("maintenance").Parse(content)
+	if err != nil {
+		return &caddyfile.OptionError{Owner: "维护模式", Option: "template", Value: "template.html", Reason: err.Error()}
+	}
+
+	var buf bytes.Buffer
+	data := map[string]string{"Title": title, "Message": message, "Until": until}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return &caddyfile.OptionError{Owner: "维护模式", Option: "template", Value: "template.html", Reason: err.Error()}
+	}
+	if err := os.MkdirAll(maintenanceDir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(maintenanceDir(), file), buf.Bytes(), 0644)
+}
+
+// maintenanceBypass 维护期间仍可访问真实服务的地址：本机回环地址、本机网卡地址，
+// 以及设置项 maintenance_bypass 中的 IP 或 CIDR（如 NAT 后的公网出口地址）
+func maintenanceBypass(q database.Querier) []string {
+	list := []string{"127.0.0.0/8", "::1"}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			list = append(list, ipNet.IP.String())
+		}
+	}
+	list = append(list, strings.Fields(strings.ReplaceAll(database.GetSettingFrom(q, "maintenance_bypass"), ",", " "))...)
+	return cleanIPList(list)
+}
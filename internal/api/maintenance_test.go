@@ -0,0 +1,216 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+func TestMaintenanceStatus(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour).Format(time.RFC3339)
+	after := now.Add(time.Hour).Format(time.RFC3339)
+	cases := []struct {
+		enabled          bool
+		startsAt, endsAt string
+		want             string
+	}{
+		{false, "", "", "off"},
+		{false, before, after, "off"},
+		{true, "", "", "active"},
+		{true, before, "", "active"},
+		{true, "", after, "active"},
+		{true, before, after, "active"},
+		{true, now.Format(time.RFC3339), after, "active"},
+		{true, after, "", "scheduled"},
+		{true, after, now.Add(2 * time.Hour).Format(time.RFC3339), "scheduled"},
+		{true, "", before, "ended"},
+		{true, before, now.Format(time.RFC3339), "ended"},
+		{true, "invalid", "invalid", "active"},
+	}
+	for _, tc := range cases {
+		m := &models.Maintenance{Enabled: tc.enabled, StartsAt: tc.startsAt, EndsAt: tc.endsAt}
+		if got := maintenanceStatus(m, now); got != tc.want {
+			t.Errorf("maintenanceStatus(%v, %q, %q) = %q; 期望 %q", tc.enabled, tc.startsAt, tc.endsAt, got, tc.want)
+		}
+	}
+}
+
+func TestParseMaintenanceTime(t *testing.T) {
+	// 浏览器 datetime-local 格式没有时区，按服务器本地时间解析
+	local := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*3600)
+	t.Cleanup(func() { time.Local = local })
+
+	cases := map[string]string{
+		"":                          "",
+		"  ":                        "",
+		"2026-10-18T02:00:00Z":      "2026-10-18T02:00:00Z",
+		"2026-10-18T10:00:00+08:00": "2026-10-18T10:00:00+08:00",
+		"2026-10-18T10:00":          "2026-10-18T10:00:00+08:00",
+		"2026-10-18T10:00:30":       "2026-10-18T10:00:30+08:00",
+		"2026-10-18 10:00":          "2026-10-18T10:00:00+08:00",
+		" 2026-10-18T10:00 ":        "2026-10-18T10:00:00+08:00",
+	}
+	for in, want := range cases {
+		got, err := parseMaintenanceTime("starts_at", in)
+		if err != nil || formatMaintenanceTime(got) != want {
+			t.Errorf("parseMaintenanceTime(%q) = %v, %v; 期望 %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"tomorrow", "2026-13-01T10:00", "2026-10-18", "10:00", "2026/10/18 10:00"} {
+		var optErr *caddyfile.OptionError
+		if _, err := parseMaintenanceTime("ends_at", in); !errors.As(err, &optErr) || optErr.Option != "ends_at" {
+			t.Errorf("parseMaintenanceTime(%q) = %v; 期望 OptionError", in, err)
+		}
+	}
+}
+
+func TestMaintenanceState(t *testing.T) {
+	testDB(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+
+	rows := []models.Maintenance{
+		{OwnerType: "site", OwnerID: 2, Enabled: true, EndsAt: at(3 * time.Hour)},                               // 维护中，3 小时后结束
+		{OwnerType: "site", OwnerID: 1, Enabled: true},                                                          // 一直维护
+		{OwnerType: "project", OwnerID: 1, Enabled: true, StartsAt: at(time.Hour), EndsAt: at(2 * time.Hour)},   // 1 小时后开始
+		{OwnerType: "project", OwnerID: 2, Enabled: true, StartsAt: at(-2 * time.Hour), EndsAt: at(-time.Hour)}, // 已结束
+		{OwnerType: "project", OwnerID: 3, Enabled: false, StartsAt: at(30 * time.Minute)},                      // 未启用
+	}
+	for _, m := range rows {
+		if _, err := database.GetDB().Exec("INSERT INTO maintenance (owner_type, owner_id, enabled, starts_at, ends_at) VALUES (?, ?, ?, ?, ?)",
+			m.OwnerType, m.OwnerID, m.Enabled, m.StartsAt, m.EndsAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key, next, err := maintenanceState(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "site-1,site-2" {
+		t.Errorf("维护中 = %q; 期望 site-1,site-2", key)
+	}
+	if !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("下一个时间点 = %v; 期望 %v", next, now.Add(time.Hour))
+	}
+
+	// 项目开始维护后，下一个时间点是它的结束时间
+	key, next, err = maintenanceState(now.Add(90 * time.Minute))
+	if err != nil || key != "project-1,site-1,site-2" || !next.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("maintenanceState = %q, %v, %v", key, next, err)
+	}
+
+	// 全部结束后没有下一个时间点
+	key, next, err = maintenanceState(now.Add(4 * time.Hour))
+	if err != nil || key != "site-1" || !next.IsZero() {
+		t.Errorf("maintenanceState = %q, %v, %v", key, next, err)
+	}
+}
+
+func TestMaintenanceSchedulerStartsInActiveWindow(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+	config.CaddyPIDFile = filepath.Join(config.CaddyDir, "caddy.pid")
+
+	if err := addSiteTx(t, "shop.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	database.GetDB().QueryRow("SELECT id FROM sites WHERE domain = 'shop.example.com'").Scan(&id)
+
+	// 管理器停止期间维护窗口已经开始，配置文件中还没有维护页面
+	now := time.Now()
+	if _, err := database.GetDB().Exec("INSERT INTO maintenance (owner_type, owner_id, enabled, starts_at, ends_at) VALUES ('site', ?, 1, ?, ?)",
+		id, now.Add(-time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	page := "site-" + strconv.Itoa(id) + ".html"
+	if data, _ := os.ReadFile(config.CaddyConfig); strings.Contains(string(data), page) {
+		t.Fatalf("启动前配置已包含维护页面: %s", data)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runMaintenanceScheduler(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(config.CaddyConfig)
+		if strings.Contains(string(data), page) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("调度器启动后未重新生成配置: %s", data)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestMaintenanceSchedulerKeepsConfigAtStartup(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+	config.CaddyPIDFile = filepath.Join(config.CaddyDir, "caddy.pid")
+
+	if err := addSiteTx(t, "shop.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := database.GetDB().Exec("INSERT INTO maintenance (owner_type, owner_id, enabled, starts_at, ends_at) VALUES ('site', 1, 1, ?, ?)",
+		now.Add(-time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	runOnce := func() {
+		stop := make(chan struct{})
+		close(stop)
+		runMaintenanceScheduler(stop)
+	}
+
+	// 手动维护的配置文件不被覆盖，下次启动时仍会比较维护状态
+	handwritten := "shop.example.com {\n\treverse_proxy localhost:9000\n}\n"
+	if err := os.WriteFile(config.CaddyConfig, []byte(handwritten), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runOnce()
+	if data, _ := os.ReadFile(config.CaddyConfig); string(data) != handwritten {
+		t.Errorf("手动维护的配置被覆盖: %s", data)
+	}
+	if got := database.GetSetting("maintenance_state"); got != "" {
+		t.Errorf("maintenance_state = %q; 未重新生成时不应记录", got)
+	}
+
+	// 维护状态变化后重新生成，状态未变化时不再写入配置
+	if err := writeCaddyfile(); err != nil {
+		t.Fatal(err)
+	}
+	runOnce()
+	if got := database.GetSetting("maintenance_state"); got != "site-1" {
+		t.Fatalf("maintenance_state = %q; 期望 site-1", got)
+	}
+	generated := "# Caddy 配置文件\n# 由 Caddy 管理器自动生成，手动修改会在下次保存时被覆盖\n"
+	if err := os.WriteFile(config.CaddyConfig, []byte(generated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runOnce()
+	if data, _ := os.ReadFile(config.CaddyConfig); string(data) != generated {
+		t.Errorf("维护状态未变化时重新生成了配置: %s", data)
+	}
+}
@@ -0,0 +1,642 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+	"caddy-manager/internal/system"
+)
+
+// defaultPHPAddress 没有对应版本的进程池时 PHP 站点使用的 FastCGI 地址（自行运行的 PHP-FPM）
+const defaultPHPAddress = "localhost:9000"
+
+// maxPHPWorkers 单个进程池最多的工作进程数
+const maxPHPWorkers = 64
+
+// phpVersionPattern 匹配 -v 输出的第一行，如 "PHP 8.2.7 (fpm-fcgi) (built: ...)"
+var phpVersionPattern = regexp.MustCompile(`^PHP (\d+)\.(\d+)\S* \(([a-z-]+)\)`)
+
+// phpINIKeyPattern php.ini 配置项名称
+var phpINIKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// PHPInstallsHandler 列出已登记的 PHP 安装
+func PHPInstallsHandler(w http.ResponseWriter, r *http.Request) {
+	installs, err := loadPHPInstalls()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(installs)
+}
+
+// DetectPHPInstallsHandler 在 PATH 和常见安装目录中查找 php-cgi / php-fpm，返回检测到的版本，
+// 不会自动登记
+func DetectPHPInstallsHandler(w http.ResponseWriter, r *http.Request) {
+	registered := make(map[string]bool)
+	if installs, err := loadPHPInstalls(); err == nil {
+		for _, install := range installs {
+			registered[install.Path] = true
+		}
+	}
+
+	found := []map[string]interface{}{}
+	for _, path := range phpCandidates() {
+		version, kind, err := probePHP(path)
+		if err != nil {
+			continue
+		}
+		found = append(found, map[string]interface{}{
+			"path":       path,
+			"version":    version,
+			"kind":       kind,
+			"registered": registered[path],
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+// SavePHPInstallHandler 登记或修改 PHP 安装，版本和类型从可执行文件的 -v 输出检测
+// 请求体: {"id": 0, "path": "/usr/sbin/php-fpm8.2"}
+func SavePHPInstallHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var install models.PHPInstall
+	if err := json.NewDecoder(r.Body).Decode(&install); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	install.Path = strings.TrimSpace(install.Path)
+	if !filepath.IsAbs(install.Path) {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "PHP 安装", Option: "path", Value: install.Path, Reason: "请填写 php-cgi 或 php-fpm 的完整路径"})
+		return
+	}
+	version, kind, err := probePHP(install.Path)
+	if err != nil {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "PHP 安装", Option: "path", Value: install.Path, Reason: err.Error()})
+		return
+	}
+	install.Version, install.Kind = version, kind
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if install.ID > 0 {
+		_, err = tx.Exec("UPDATE php_installs SET version=?, path=?, kind=? WHERE id=?", install.Version, install.Path, install.Kind, install.ID)
+	} else {
+		_, err = tx.Exec("INSERT INTO php_installs (version, path, kind) VALUES (?, ?, ?)", install.Version, install.Path, install.Kind)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	// 修改了可执行文件时按新的安装重启进程池
+	if install.ID > 0 {
+		if pool, err := loadPHPPoolByInstall(install.ID); err == nil && phpPoolRunning(pool.ID) {
+			if err := startPHPPool(*pool); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("已登记 PHP %s (%s)", install.Version, install.Kind),
+	})
+}
+
+// DeletePHPInstallHandler 删除 PHP 安装及其进程池，使用该版本的站点改用 localhost:9000
+func DeletePHPInstallHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	pool, poolErr := loadPHPPoolByInstall(id)
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM php_pools WHERE install_id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM php_installs WHERE id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	// 配置不再使用该进程池后再停止
+	if poolErr == nil {
+		stopPHPPool(pool.ID)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PHPPoolsHandler 列出进程池及运行状态、FastCGI 地址和使用该进程池的站点
+func PHPPoolsHandler(w http.ResponseWriter, r *http.Request) {
+	pools, err := loadPHPPools(database.GetDB())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sites, err := phpSitesByPool(pools)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := []map[string]interface{}{}
+	for _, pool := range pools {
+		item := map[string]interface{}{
+			"id":         pool.ID,
+			"install_id": pool.InstallID,
+			"version":    pool.Install.Version,
+			"kind":       pool.Install.Kind,
+			"path":       pool.Install.Path,
+			"listen":     pool.Listen,
+			"workers":    pool.Workers,
+			"ini":        pool.INI,
+			"auto_start": pool.AutoStart,
+			"upstreams":  phpPoolUpstreams(pool),
+			"sites":      sites[pool.ID],
+			"status":     "stopped",
+			"log":        phpPoolLogPath(pool.ID),
+		}
+		phpMutex.Lock()
+		if runner := phpRunners[pool.ID]; runner != nil {
+			status, pids := runner.status()
+			item["status"] = status
+			item["pids"] = pids
+			item["started_at"] = runner.startedAt.Format("2006-01-02 15:04:05")
+			runner.mu.Lock()
+			item["last_error"] = runner.lastError
+			runner.mu.Unlock()
+		}
+		phpMutex.Unlock()
+		list = append(list, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// SavePHPPoolHandler 保存 PHP 安装的进程池设置，每个安装只有一个进程池，已存在时覆盖；
+// 运行中的进程池按新设置重启。listen 为空时按版本分配端口（如 8.2 使用 9820）
+// 请求体: {"install_id": 1, "listen": "9820", "workers": 4, "ini": "memory_limit=256M\nupload_max_filesize=64M", "auto_start": true}
+func SavePHPPoolHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var pool models.PHPPool
+	if err := json.NewDecoder(r.Body).Decode(&pool); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	install, err := loadPHPInstall(pool.InstallID)
+	if err != nil {
+		http.Error(w, "PHP 安装不存在", http.StatusNotFound)
+		return
+	}
+	pool.Listen = strings.TrimSpace(pool.Listen)
+	if pool.Listen == "" {
+		pool.Listen = defaultPHPPoolPort(install.Version)
+	}
+	if pool.Workers == 0 {
+		pool.Workers = 4
+	}
+	pool.INI = strings.TrimSpace(strings.ReplaceAll(pool.INI, "\r\n", "\n"))
+
+	cfg := phpPoolConfig{PHPPool: pool, Install: *install}
+	if existing, err := loadPHPPoolByInstall(pool.InstallID); err == nil {
+		cfg.ID = existing.ID
+	}
+	if err := checkPHPPool(cfg); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO php_pools (install_id, listen, workers, ini, auto_start) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(install_id) DO UPDATE SET listen = excluded.listen, workers = excluded.workers, ini = excluded.ini, auto_start = excluded.auto_start`,
+		pool.InstallID, pool.Listen, pool.Workers, pool.INI, pool.AutoStart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	saved, err := loadPHPPoolByInstall(pool.InstallID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if phpPoolRunning(saved.ID) {
+		if err := startPHPPool(*saved); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"message":   fmt.Sprintf("PHP %s 进程池已保存", install.Version),
+		"upstreams": phpPoolUpstreams(*saved),
+	})
+}
+
+// DeletePHPPoolHandler 停止并删除进程池
+func DeletePHPPoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM php_pools WHERE id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	stopPHPPool(id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// StartPHPPoolHandler 启动进程池，已在运行时重启
+func StartPHPPoolHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	pool, err := loadPHPPool(id)
+	if err != nil {
+		http.Error(w, "进程池不存在", http.StatusNotFound)
+		return
+	}
+	if err := startPHPPool(*pool); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("PHP %s 进程池已启动", pool.Install.Version),
+	})
+}
+
+// StopPHPPoolHandler 停止进程池
+func StopPHPPoolHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	stopPHPPool(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "进程池已停止",
+	})
+}
+
+// phpUpstreamsFor 返回 PHP 站点使用的 FastCGI 地址：转发到站点所选版本的进程池，
+// 未选择版本时使用最高版本的进程池，没有对应的进程池时使用 localhost:9000
+func phpUpstreamsFor(q database.Querier, version string) ([]string, error) {
+	pools, err := loadPHPPools(q)
+	if err != nil {
+		return nil, err
+	}
+	if pool := choosePHPPool(pools, version); pool != nil {
+		return phpPoolUpstreams(*pool), nil
+	}
+	if version != "" && len(pools) > 0 {
+		log.Printf("⚠️  没有 PHP %s 的进程池，使用 %s", version, defaultPHPAddress)
+	}
+	return []string{defaultPHPAddress}, nil
+}
+
+// choosePHPPool 按主次版本号选择进程池，pools 已按版本从高到低排列
+func choosePHPPool(pools []phpPoolConfig, version string) *phpPoolConfig {
+	want := phpMinorVersion(version)
+	for i := range pools {
+		if want == "" || pools[i].Install.Version == want {
+			return &pools[i]
+		}
+	}
+	return nil
+}
+
+// phpSitesByPool 进程池 ID -> 转发到该进程池的站点域名
+func phpSitesByPool(pools []phpPoolConfig) (map[int][]string, error) {
+	rows, err := database.GetDB().Query("SELECT domain, COALESCE(php_version, '') FROM sites WHERE type = 'php' ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sites := make(map[int][]string)
+	for rows.Next() {
+		var domain, version string
+		if err := rows.Scan(&domain, &version); err != nil {
+			return nil, err
+		}
+		if pool := choosePHPPool(pools, version); pool != nil {
+			sites[pool.ID] = append(sites[pool.ID], domain)
+		}
+	}
+	return sites, rows.Err()
+}
+
+// checkPHPPool 检查工作进程数、监听地址和 php.ini 覆盖，监听地址不能与其他进程池重叠
+func checkPHPPool(pool phpPoolConfig) error {
+	owner := fmt.Sprintf("PHP %s 进程池", pool.Install.Version)
+	if pool.Workers < 1 || pool.Workers > maxPHPWorkers {
+		return &caddyfile.OptionError{Owner: owner, Option: "workers", Value: strconv.Itoa(pool.Workers), Reason: fmt.Sprintf("应为 1-%d", maxPHPWorkers)}
+	}
+
+	if port, err := strconv.Atoi(pool.Listen); err == nil {
+		ports := phpPoolPorts(pool)
+		if port < 1 || ports[len(ports)-1] > 65535 {
+			return &caddyfile.OptionError{Owner: owner, Option: "listen", Value: pool.Listen, Reason: "端口应为 1-65535（Windows 上每个工作进程占用一个端口）"}
+		}
+	} else if runtime.GOOS == "windows" || !filepath.IsAbs(pool.Listen) {
+		return &caddyfile.OptionError{Owner: owner, Option: "listen", Value: pool.Listen, Reason: "应为端口号，或 Unix 套接字的完整路径（Windows 不支持套接字）"}
+	} else if strings.IndexFunc(pool.Listen, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		// 套接字路径写入 Caddyfile 和 php-fpm 配置，不能包含空白和控制字符
+		return &caddyfile.OptionError{Owner: owner, Option: "listen", Value: pool.Listen, Reason: "套接字路径不能包含空格或控制字符"}
+	}
+
+	if _, err := checkPHPINI(owner, pool.INI); err != nil {
+		return err
+	}
+
+	pools, err := loadPHPPools(database.GetDB())
+	if err != nil {
+		return err
+	}
+	used := make(map[string]string)
+	for _, other := range pools {
+		if other.ID == pool.ID {
+			continue
+		}
+		for _, addr := range phpPoolUpstreams(other) {
+			used[addr] = fmt.Sprintf("PHP %s 进程池", other.Install.Version)
+		}
+	}
+	for _, addr := range phpPoolUpstreams(pool) {
+		if other, ok := used[addr]; ok {
+			return &caddyfile.OptionError{Owner: owner, Option: "listen", Value: pool.Listen, Reason: fmt.Sprintf("%s 已被%s使用", addr, other)}
+		}
+	}
+	return nil
+}
+
+// checkPHPINI 解析 php.ini 覆盖，每行一个 key=value，空行和 ; 开头的注释忽略
+func checkPHPINI(owner, text string) ([][2]string, error) {
+	var list [][2]string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || !phpINIKeyPattern.MatchString(key) {
+			return nil, &caddyfile.OptionError{Owner: owner, Option: "ini", Value: line, Reason: "每行应为 key=value，如 memory_limit=256M"}
+		}
+		list = append(list, [2]string{key, strings.Trim(value, `"`)})
+	}
+	return list, nil
+}
+
+// parsePHPINI 解析已保存（已检查过）的 php.ini 覆盖
+func parsePHPINI(text string) [][2]string {
+	list, _ := checkPHPINI("", text)
+	return list
+}
+
+// defaultPHPPoolPort 按版本分配的默认端口，如 7.4 使用 9740、8.2 使用 9820，
+// 为 Windows 上每个工作进程一个端口留出间隔
+func defaultPHPPoolPort(version string) string {
+	major, minor := phpVersionNumbers(version)
+	return strconv.Itoa(9000 + major*100 + minor*10)
+}
+
+// phpMinorVersion 取主次版本号，如 "8.2.7" -> "8.2"
+func phpMinorVersion(version string) string {
+	parts := strings.SplitN(strings.TrimSpace(version), ".", 3)
+	if len(parts) < 2 {
+		return strings.Join(parts, "")
+	}
+	return parts[0] + "." + parts[1]
+}
+
+func phpVersionNumbers(version string) (int, int) {
+	major, minor, _ := strings.Cut(phpMinorVersion(version), ".")
+	a, _ := strconv.Atoi(major)
+	b, _ := strconv.Atoi(minor)
+	return a, b
+}
+
+// probePHP 运行 -v 检测版本和类型，只接受 php-cgi（cgi-fcgi）和 php-fpm（fpm-fcgi）
+func probePHP(path string) (version, kind string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "-v")
+	system.HideWindow(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("无法运行 %s -v: %v", filepath.Base(path), err)
+	}
+	m := phpVersionPattern.FindStringSubmatch(strings.TrimSpace(string(output)))
+	if m == nil {
+		return "", "", fmt.Errorf("无法识别 PHP 版本")
+	}
+	switch m[3] {
+	case "cgi-fcgi":
+		kind = "cgi"
+	case "fpm-fcgi":
+		kind = "fpm"
+	default:
+		return "", "", fmt.Errorf("%s 是 PHP 命令行版本 (%s)，请选择 php-cgi 或 php-fpm", filepath.Base(path), m[3])
+	}
+	return m[1] + "." + m[2], kind, nil
+}
+
+// phpCandidates 在 PATH 和常见安装目录中查找 php-cgi / php-fpm
+func phpCandidates() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, name := range []string{"php-cgi", "php-fpm"} {
+		if path, err := exec.LookPath(name); err == nil {
+			if abs, err := filepath.Abs(path); err == nil {
+				add(abs)
+			}
+		}
+	}
+
+	var patterns []string
+	if runtime.GOOS == "windows" {
+		patterns = []string{`C:\php*\php-cgi.exe`, `C:\tools\php*\php-cgi.exe`, `C:\Program Files\PHP*\php-cgi.exe`}
+	} else {
+		patterns = []string{"/usr/sbin/php-fpm*", "/usr/bin/php-cgi*", "/usr/local/sbin/php-fpm*", "/usr/local/bin/php-cgi*",
+			"/opt/homebrew/opt/php*/sbin/php-fpm", "/usr/local/opt/php*/sbin/php-fpm", "/opt/remi/php*/root/usr/sbin/php-fpm"}
+	}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		sort.Strings(matches)
+		for _, path := range matches {
+			add(path)
+		}
+	}
+	return paths
+}
+
+func loadPHPInstalls() ([]models.PHPInstall, error) {
+	rows, err := database.GetDB().Query("SELECT id, version, path, kind, created_at FROM php_installs ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	installs := []models.PHPInstall{}
+	for rows.Next() {
+		var install models.PHPInstall
+		if err := rows.Scan(&install.ID, &install.Version, &install.Path, &install.Kind, &install.CreatedAt); err != nil {
+			return nil, err
+		}
+		installs = append(installs, install)
+	}
+	return installs, rows.Err()
+}
+
+func loadPHPInstall(id int) (*models.PHPInstall, error) {
+	var install models.PHPInstall
+	err := database.GetDB().QueryRow("SELECT id, version, path, kind, created_at FROM php_installs WHERE id=?", id).
+		Scan(&install.ID, &install.Version, &install.Path, &install.Kind, &install.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &install, nil
+}
+
+const phpPoolQuery = `SELECT p.id, p.install_id, p.listen, p.workers, p.ini, COALESCE(p.auto_start, 1), p.created_at,
+	i.id, i.version, i.path, i.kind, i.created_at
+	FROM php_pools p JOIN php_installs i ON i.id = p.install_id`
+
+// loadPHPPools 读取所有进程池，按 PHP 版本从高到低排列
+func loadPHPPools(q database.Querier) ([]phpPoolConfig, error) {
+	rows, err := q.Query(phpPoolQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pools []phpPoolConfig
+	for rows.Next() {
+		var pool phpPoolConfig
+		if err := scanPHPPool(rows, &pool); err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	sort.SliceStable(pools, func(i, j int) bool {
+		ai, bi := phpVersionNumbers(pools[i].Install.Version)
+		aj, bj := phpVersionNumbers(pools[j].Install.Version)
+		if ai != aj {
+			return ai > aj
+		}
+		return bi > bj
+	})
+	return pools, rows.Err()
+}
+
+func loadPHPPool(id int) (*phpPoolConfig, error) {
+	var pool phpPoolConfig
+	if err := scanPHPPool(database.GetDB().QueryRow(phpPoolQuery+" WHERE p.id=?", id), &pool); err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func loadPHPPoolByInstall(installID int) (*phpPoolConfig, error) {
+	var pool phpPoolConfig
+	if err := scanPHPPool(database.GetDB().QueryRow(phpPoolQuery+" WHERE p.install_id=?", installID), &pool); err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func scanPHPPool(row interface{ Scan(...interface{}) error }, pool *phpPoolConfig) error {
+	return row.Scan(&pool.ID, &pool.InstallID, &pool.Listen, &pool.Workers, &pool.INI, &pool.AutoStart, &pool.CreatedAt,
+		&pool.Install.ID, &pool.Install.Version, &pool.Install.Path, &pool.Install.Kind, &pool.Install.CreatedAt)
+}
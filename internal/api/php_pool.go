@@ -0,0 +1,445 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+	"caddy-manager/internal/system"
+)
+
+// phpMaxFailures 进程连续快速退出（启动后 phpQuickExit 内）超过该次数后不再重启
+const phpMaxFailures = 5
+
+// phpQuickExit 启动后在该时间内退出视为启动失败
+const phpQuickExit = 10 * time.Second
+
+// phpStopTimeout 停止进程池时等待进程退出的时间，超时后强制结束
+const phpStopTimeout = 5 * time.Second
+
+// phpPoolConfig 进程池及其 PHP 安装
+type phpPoolConfig struct {
+	models.PHPPool
+	Install models.PHPInstall
+}
+
+// phpProcess 进程池中一个需要监护的进程：php-fpm 为主进程，php-cgi 在 Unix 上为父进程
+// （由 PHP_FCGI_CHILDREN 派生工作进程），在 Windows 上每个工作进程单独监听一个端口
+type phpProcess struct {
+	args []string
+	env  []string
+}
+
+// phpPoolRunner 运行中的进程池
+type phpPoolRunner struct {
+	stop chan struct{}
+	done chan struct{}
+
+	mu        sync.Mutex
+	cmds      map[int]*exec.Cmd // 进程序号 -> 当前进程，重启间隙为 nil
+	failed    int               // 已放弃重启的进程数
+	startedAt time.Time
+	lastError string
+}
+
+var (
+	// phpRunners 进程池 ID -> 运行中的进程池
+	phpRunners = make(map[int]*phpPoolRunner)
+	phpMutex   sync.Mutex
+)
+
+// phpPoolDir 进程池的 php-fpm 配置、pid 和套接字文件所在目录
+func phpPoolDir() string {
+	return filepath.Join(config.DataDir, "php")
+}
+
+func phpPoolLogPath(poolID int) string {
+	return filepath.Join(config.DataDir, "logs", fmt.Sprintf("php_pool_%d.log", poolID))
+}
+
+// phpPoolPorts 进程池监听的端口。Windows 的 php-cgi 不支持 PHP_FCGI_CHILDREN，
+// 每个工作进程使用一个端口，从配置的端口开始连续分配；使用套接字时返回 nil
+func phpPoolPorts(pool phpPoolConfig) []int {
+	port, err := strconv.Atoi(pool.Listen)
+	if err != nil {
+		return nil
+	}
+	n := 1
+	if pool.Install.Kind == "cgi" && runtime.GOOS == "windows" {
+		n = pool.Workers
+	}
+	ports := make([]int, n)
+	for i := range ports {
+		ports[i] = port + i
+	}
+	return ports
+}
+
+// phpPoolUpstreams 进程池的 FastCGI 地址，用于 Caddyfile 的 php_fastcgi
+func phpPoolUpstreams(pool phpPoolConfig) []string {
+	ports := phpPoolPorts(pool)
+	if ports == nil {
+		return []string{"unix/" + pool.Listen}
+	}
+	upstreams := make([]string, len(ports))
+	for i, port := range ports {
+		upstreams[i] = fmt.Sprintf("127.0.0.1:%d", port)
+	}
+	return upstreams
+}
+
+// phpPoolProcesses 按安装类型生成需要启动的进程，php.ini 覆盖通过 -d 传入
+func phpPoolProcesses(pool phpPoolConfig) ([]phpProcess, error) {
+	var ini []string
+	for _, kv := range parsePHPINI(pool.INI) {
+		ini = append(ini, "-d", kv[0]+"="+kv[1])
+	}
+
+	if pool.Install.Kind == "fpm" {
+		conf, err := writePHPFPMConfig(pool)
+		if err != nil {
+			return nil, err
+		}
+		args := []string{"-F", "-y", conf}
+		return []phpProcess{{args: append(args, ini...), env: os.Environ()}}, nil
+	}
+
+	ports := phpPoolPorts(pool)
+	if ports == nil {
+		return []phpProcess{{
+			args: append([]string{"-b", pool.Listen}, ini...),
+			env:  append(os.Environ(), fmt.Sprintf("PHP_FCGI_CHILDREN=%d", pool.Workers), "PHP_FCGI_MAX_REQUESTS=500"),
+		}}, nil
+	}
+	if runtime.GOOS == "windows" {
+		// 每个进程处理一个请求后不退出，避免频繁重启
+		var procs []phpProcess
+		for _, port := range ports {
+			procs = append(procs, phpProcess{
+				args: append([]string{"-b", fmt.Sprintf("127.0.0.1:%d", port)}, ini...),
+				env:  append(os.Environ(), "PHP_FCGI_MAX_REQUESTS=0"),
+			})
+		}
+		return procs, nil
+	}
+	return []phpProcess{{
+		args: append([]string{"-b", fmt.Sprintf("127.0.0.1:%d", ports[0])}, ini...),
+		env:  append(os.Environ(), fmt.Sprintf("PHP_FCGI_CHILDREN=%d", pool.Workers), "PHP_FCGI_MAX_REQUESTS=500"),
+	}}, nil
+}
+
+// defaultPHPUsers 以 root 运行且未设置 php_user 时工作进程使用的账户，按顺序选择第一个存在的
+var defaultPHPUsers = []string{"www-data", "nobody"}
+
+// phpFPMUser 返回 php-fpm 工作进程使用的账户和组。以 root 运行时工作进程不能使用 root，
+// 使用设置中的 php_user，未设置时使用 www-data 或 nobody；不是 root 时工作进程以当前用户运行，返回空
+func phpFPMUser() (string, string, error) {
+	if os.Geteuid() != 0 {
+		return "", "", nil
+	}
+	names := defaultPHPUsers
+	if name := strings.TrimSpace(database.GetSetting("php_user")); name != "" {
+		names = []string{name}
+	}
+	for _, name := range names {
+		u, err := user.Lookup(name)
+		if err != nil {
+			continue
+		}
+		group := u.Gid
+		if g, err := user.LookupGroupId(u.Gid); err == nil {
+			group = g.Name
+		}
+		return u.Username, group, nil
+	}
+	return "", "", fmt.Errorf("找不到运行 PHP 工作进程的账户 %s，请在设置中指定 php_user", strings.Join(names, "、"))
+}
+
+// writePHPFPMConfig 生成 php-fpm 配置：前台运行，固定数量的工作进程
+func writePHPFPMConfig(pool phpPoolConfig) (string, error) {
+	dir := phpPoolDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	username, group, err := phpFPMUser()
+	if err != nil {
+		return "", err
+	}
+	listen := pool.Listen
+	if ports := phpPoolPorts(pool); ports != nil {
+		listen = fmt.Sprintf("127.0.0.1:%d", ports[0])
+	}
+
+	var b strings.Builder
+	b.WriteString("; 由 Caddy 管理器生成，手动修改会在下次启动时被覆盖\n")
+	b.WriteString("[global]\n")
+	fmt.Fprintf(&b, "pid = %s\n", filepath.Join(dir, fmt.Sprintf("pool-%d.pid", pool.ID)))
+	fmt.Fprintf(&b, "error_log = %s\n", phpPoolLogPath(pool.ID))
+	b.WriteString("daemonize = no\n\n")
+	fmt.Fprintf(&b, "[pool-%d]\n", pool.ID)
+	if username != "" {
+		fmt.Fprintf(&b, "user = %s\n", username)
+		fmt.Fprintf(&b, "group = %s\n", group)
+	}
+	fmt.Fprintf(&b, "listen = %s\n", listen)
+	b.WriteString("listen.mode = 0660\n")
+	b.WriteString("pm = static\n")
+	fmt.Fprintf(&b, "pm.max_children = %d\n", pool.Workers)
+	b.WriteString("clear_env = no\n")
+	b.WriteString("catch_workers_output = yes\n")
+
+	path := filepath.Join(dir, fmt.Sprintf("pool-%d.conf", pool.ID))
+	return path, os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// startPHPPool 启动进程池，已在运行时先停止。任一进程启动失败时停止已启动的进程
+func startPHPPool(pool phpPoolConfig) error {
+	phpMutex.Lock()
+	defer phpMutex.Unlock()
+
+	if runner := phpRunners[pool.ID]; runner != nil {
+		runner.shutdown()
+		delete(phpRunners, pool.ID)
+	}
+
+	if _, err := os.Stat(pool.Install.Path); err != nil {
+		return fmt.Errorf("PHP 可执行文件不存在: %s", pool.Install.Path)
+	}
+	procs, err := phpPoolProcesses(pool)
+	if err != nil {
+		return err
+	}
+	if phpPoolPorts(pool) == nil {
+		// 上次异常退出留下的套接字文件会导致监听失败
+		os.Remove(pool.Listen)
+	}
+
+	os.MkdirAll(filepath.Join(config.DataDir, "logs"), 0755)
+	logFile, err := os.OpenFile(phpPoolLogPath(pool.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	runner := &phpPoolRunner{
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		cmds:      make(map[int]*exec.Cmd),
+		startedAt: time.Now(),
+	}
+	var started []*exec.Cmd
+	for i, proc := range procs {
+		cmd := newPHPCommand(pool.Install.Path, proc, logFile)
+		if err := cmd.Start(); err != nil {
+			for _, c := range started {
+				c.Process.Kill()
+				c.Wait()
+			}
+			logFile.Close()
+			if len(procs) > 1 {
+				return fmt.Errorf("PHP 进程 %d 启动失败: %w", i, err)
+			}
+			return err
+		}
+		started = append(started, cmd)
+		runner.cmds[i] = cmd
+	}
+
+	var wg sync.WaitGroup
+	for i, proc := range procs {
+		wg.Add(1)
+		go func(index int, proc phpProcess, cmd *exec.Cmd) {
+			defer wg.Done()
+			runner.supervise(pool, index, proc, cmd, logFile)
+		}(i, proc, started[i])
+	}
+	go func() {
+		wg.Wait()
+		logFile.Close()
+		close(runner.done)
+	}()
+
+	phpRunners[pool.ID] = runner
+	log.Printf("✓ PHP %s 进程池 #%d 已启动: %s", pool.Install.Version, pool.ID, strings.Join(phpPoolUpstreams(pool), " "))
+	return nil
+}
+
+func newPHPCommand(path string, proc phpProcess, logFile *os.File) *exec.Cmd {
+	cmd := exec.Command(path, proc.args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = proc.env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	system.HideWindow(cmd)
+	return cmd
+}
+
+// supervise 等待进程退出并重启，连续快速退出超过 phpMaxFailures 次后放弃
+func (r *phpPoolRunner) supervise(pool phpPoolConfig, index int, proc phpProcess, cmd *exec.Cmd, logFile *os.File) {
+	failures := 0
+	for {
+		startedAt := time.Now()
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+
+		var err error
+		select {
+		case <-r.stop:
+			terminatePHPProcess(cmd, exited)
+			return
+		case err = <-exited:
+		}
+
+		r.mu.Lock()
+		r.cmds[index] = nil
+		r.lastError = fmt.Sprintf("进程 %d 退出: %v", index, err)
+		r.mu.Unlock()
+
+		if time.Since(startedAt) < phpQuickExit {
+			failures++
+		} else {
+			failures = 0
+		}
+		if failures > phpMaxFailures {
+			log.Printf("❌ PHP %s 进程池 #%d 的进程 %d 连续启动失败，已停止重启，请查看日志 %s",
+				pool.Install.Version, pool.ID, index, phpPoolLogPath(pool.ID))
+			r.mu.Lock()
+			r.failed++
+			r.mu.Unlock()
+			return
+		}
+
+		delay := time.Duration(failures) * 2 * time.Second
+		log.Printf("⚠️  PHP %s 进程池 #%d 的进程 %d 已退出 (%v)，%v 后重启", pool.Install.Version, pool.ID, index, err, delay)
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(delay):
+		}
+
+		for {
+			cmd = newPHPCommand(pool.Install.Path, proc, logFile)
+			if err = cmd.Start(); err == nil {
+				break
+			}
+			failures++
+			r.mu.Lock()
+			r.lastError = fmt.Sprintf("进程 %d 启动失败: %v", index, err)
+			if failures > phpMaxFailures {
+				r.failed++
+			}
+			r.mu.Unlock()
+			if failures > phpMaxFailures {
+				log.Printf("❌ PHP %s 进程池 #%d 的进程 %d 启动失败: %v", pool.Install.Version, pool.ID, index, err)
+				return
+			}
+			select {
+			case <-r.stop:
+				return
+			case <-time.After(time.Duration(failures) * 2 * time.Second):
+			}
+		}
+
+		r.mu.Lock()
+		r.cmds[index] = cmd
+		r.mu.Unlock()
+	}
+}
+
+// terminatePHPProcess 先请求进程退出（php-fpm 和 php-cgi 收到 SIGTERM 后会结束工作进程），
+// 超时或不支持信号（Windows）时强制结束
+func terminatePHPProcess(cmd *exec.Cmd, exited chan error) {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		cmd.Process.Kill()
+	}
+	select {
+	case <-exited:
+	case <-time.After(phpStopTimeout):
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// shutdown 停止所有进程并等待监护协程退出，调用方需持有 phpMutex
+func (r *phpPoolRunner) shutdown() {
+	close(r.stop)
+	<-r.done
+}
+
+// status 返回进程池状态和各进程的 PID
+func (r *phpPoolRunner) status() (string, []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pids []int
+	for i := 0; i < len(r.cmds); i++ {
+		if cmd := r.cmds[i]; cmd != nil && cmd.Process != nil {
+			pids = append(pids, cmd.Process.Pid)
+		}
+	}
+	switch {
+	case r.failed == len(r.cmds):
+		return "failed", pids
+	case len(pids) > 0:
+		return "running", pids
+	}
+	return "restarting", pids
+}
+
+// stopPHPPool 停止进程池，未运行时不做任何事
+func stopPHPPool(poolID int) {
+	phpMutex.Lock()
+	defer phpMutex.Unlock()
+
+	if runner := phpRunners[poolID]; runner != nil {
+		runner.shutdown()
+		delete(phpRunners, poolID)
+		log.Printf("PHP 进程池 #%d 已停止", poolID)
+	}
+}
+
+// phpPoolRunning 进程池是否已启动（包括正在重启和已放弃重启的）
+func phpPoolRunning(poolID int) bool {
+	phpMutex.Lock()
+	defer phpMutex.Unlock()
+	return phpRunners[poolID] != nil
+}
+
+// StartPHPPools 启动所有设置为自动启动的 PHP 进程池
+func StartPHPPools() {
+	pools, err := loadPHPPools(database.GetDB())
+	if err != nil {
+		log.Printf("⚠️  读取 PHP 进程池失败: %v", err)
+		return
+	}
+	for _, pool := range pools {
+		if !pool.AutoStart {
+			continue
+		}
+		if err := startPHPPool(pool); err != nil {
+			log.Printf("❌ PHP %s 进程池 #%d 启动失败: %v", pool.Install.Version, pool.ID, err)
+		}
+	}
+}
+
+// StopPHPPools 停止所有 PHP 进程池
+func StopPHPPools() {
+	phpMutex.Lock()
+	defer phpMutex.Unlock()
+
+	for id, runner := range phpRunners {
+		runner.shutdown()
+		delete(phpRunners, id)
+	}
+}
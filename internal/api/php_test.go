@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+func testPool(version, kind, listen string, workers int) phpPoolConfig {
+	return phpPoolConfig{
+		PHPPool: models.PHPPool{ID: 1, Listen: listen, Workers: workers},
+		Install: models.PHPInstall{Version: version, Kind: kind},
+	}
+}
+
+func TestDefaultPHPPoolPort(t *testing.T) {
+	cases := map[string]string{
+		"7.4":    "9740",
+		"8.0":    "9800",
+		"8.2":    "9820",
+		"8.2.7":  "9820",
+		"8.10.1": "9900",
+		"":       "9000",
+	}
+	for version, want := range cases {
+		if got := defaultPHPPoolPort(version); got != want {
+			t.Errorf("defaultPHPPoolPort(%q) = %q; 期望 %q", version, got, want)
+		}
+	}
+}
+
+func TestPHPPoolPorts(t *testing.T) {
+	// Windows 上的 php-cgi 每个工作进程监听一个端口，其他情况共用一个端口
+	cgiPorts := []int{9820}
+	if runtime.GOOS == "windows" {
+		cgiPorts = []int{9820, 9821, 9822}
+	}
+	cases := []struct {
+		pool phpPoolConfig
+		want []int
+	}{
+		{testPool("8.2", "cgi", "9820", 3), cgiPorts},
+		{testPool("8.2", "cgi", "9820", 1), []int{9820}},
+		{testPool("8.2", "fpm", "9820", 3), []int{9820}},
+		{testPool("8.2", "fpm", "/run/php.sock", 3), nil},
+	}
+	for _, tc := range cases {
+		if got := phpPoolPorts(tc.pool); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("phpPoolPorts(%s %s x%d) = %v; 期望 %v", tc.pool.Install.Kind, tc.pool.Listen, tc.pool.Workers, got, tc.want)
+		}
+	}
+
+	if got := phpPoolUpstreams(testPool("8.2", "fpm", "/run/php.sock", 3)); !reflect.DeepEqual(got, []string{"unix//run/php.sock"}) {
+		t.Errorf("phpPoolUpstreams(socket) = %v", got)
+	}
+}
+
+func TestPHPPoolProcesses(t *testing.T) {
+	testDB(t)
+	ini := "memory_limit=256M\n; 注释\n\nupload_max_filesize = \"64M\""
+	hasINI := func(args []string) bool {
+		return strings.Contains(strings.Join(args, " "), "-d memory_limit=256M -d upload_max_filesize=64M")
+	}
+
+	pool := testPool("8.2", "cgi", "9820", 3)
+	pool.INI = ini
+	procs, err := phpPoolProcesses(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "windows" {
+		if len(procs) != 3 || procs[2].args[1] != "127.0.0.1:9822" {
+			t.Errorf("php-cgi 进程 = %+v; 期望每个端口一个", procs)
+		}
+	} else if len(procs) != 1 || procs[0].args[1] != "127.0.0.1:9820" || !hasEnv(procs[0].env, "PHP_FCGI_CHILDREN=3") {
+		t.Errorf("php-cgi 进程 = %+v; 期望一个父进程派生 3 个工作进程", procs)
+	}
+	for _, p := range procs {
+		if p.args[0] != "-b" || !hasINI(p.args) {
+			t.Errorf("php-cgi 参数 = %v", p.args)
+		}
+	}
+
+	pool = testPool("8.2", "fpm", "9820", 5)
+	pool.INI = ini
+	procs, err = phpPoolProcesses(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 1 || procs[0].args[0] != "-F" || procs[0].args[1] != "-y" || !hasINI(procs[0].args) {
+		t.Fatalf("php-fpm 进程 = %+v", procs)
+	}
+	conf, err := os.ReadFile(procs[0].args[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"listen = 127.0.0.1:9820", "pm = static", "pm.max_children = 5", "daemonize = no"} {
+		if !strings.Contains(string(conf), line+"\n") {
+			t.Errorf("php-fpm 配置缺少 %q:\n%s", line, conf)
+		}
+	}
+	for _, arg := range procs[0].args {
+		if arg == "-R" {
+			t.Errorf("php-fpm 参数 = %v; 不应允许工作进程以 root 运行", procs[0].args)
+		}
+	}
+}
+
+func TestPHPFPMUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("只有以 root 运行时才需要指定工作进程的账户")
+	}
+	testDB(t)
+
+	// 以 root 运行时工作进程使用设置的账户，不能使用 root
+	database.SetSetting("php_user", "nobody")
+	conf, err := writePHPFPMConfig(testPool("8.2", "fpm", "9820", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(conf)
+	if !strings.Contains(string(data), "user = nobody\n") || !strings.Contains(string(data), "\ngroup = ") {
+		t.Errorf("php-fpm 配置未指定工作进程账户:\n%s", data)
+	}
+
+	database.SetSetting("php_user", "no-such-user-for-php")
+	if _, err := writePHPFPMConfig(testPool("8.2", "fpm", "9820", 2)); err == nil {
+		t.Error("账户不存在时期望返回错误")
+	}
+}
+
+func hasEnv(env []string, kv string) bool {
+	for _, e := range env {
+		if e == kv {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckPHPINI(t *testing.T) {
+	list, err := checkPHPINI("PHP 8.2 进程池", "memory_limit=256M\r\n  ; 注释\n\ndate.timezone = \"Asia/Shanghai\"\nopcache.enable=0")
+	want := [][2]string{{"memory_limit", "256M"}, {"date.timezone", "Asia/Shanghai"}, {"opcache.enable", "0"}}
+	if err != nil || !reflect.DeepEqual(list, want) {
+		t.Errorf("checkPHPINI = %v, %v; 期望 %v", list, err, want)
+	}
+
+	for _, text := range []string{"memory_limit", "=256M", "memory limit=256M", "-d foo=1", "extension=x\nbad line"} {
+		var optErr *caddyfile.OptionError
+		if _, err := checkPHPINI("PHP 8.2 进程池", text); !errors.As(err, &optErr) || optErr.Option != "ini" {
+			t.Errorf("checkPHPINI(%q) = %v; 期望 OptionError", text, err)
+		}
+	}
+}
+
+func TestChoosePHPPool(t *testing.T) {
+	// loadPHPPools 按版本从高到低排列
+	pools := []phpPoolConfig{testPool("8.3", "fpm", "9830", 1), testPool("8.2", "fpm", "9820", 1), testPool("7.4", "cgi", "9740", 1)}
+	cases := map[string]string{
+		"":      "8.3",
+		"8.2":   "8.2",
+		"8.2.7": "8.2",
+		"7.4":   "7.4",
+		"8.1":   "",
+		"5.6":   "",
+	}
+	for version, want := range cases {
+		got := ""
+		if pool := choosePHPPool(pools, version); pool != nil {
+			got = pool.Install.Version
+		}
+		if got != want {
+			t.Errorf("choosePHPPool(%q) = %q; 期望 %q", version, got, want)
+		}
+	}
+	if pool := choosePHPPool(nil, ""); pool != nil {
+		t.Errorf("没有进程池时 choosePHPPool = %+v; 期望 nil", pool)
+	}
+}
+
+func TestCheckPHPPoolSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持套接字")
+	}
+	testDB(t)
+
+	pool := phpPoolConfig{PHPPool: models.PHPPool{Workers: 4}, Install: models.PHPInstall{Version: "8.2.7", Kind: "fpm"}}
+	for _, listen := range []string{"/run/php/php8.2-fpm.sock", "/tmp/php-8.2.sock"} {
+		pool.Listen = listen
+		if err := checkPHPPool(pool); err != nil {
+			t.Errorf("listen %q: %v", listen, err)
+		}
+	}
+
+	for _, listen := range []string{"php.sock", "/run/php/php 8.2.sock", "/run/php.sock\n", "/run/php\t.sock", "/run/php\x00.sock", "/run/php　.sock"} {
+		pool.Listen = listen
+		var optErr *caddyfile.OptionError
+		if err := checkPHPPool(pool); !errors.As(err, &optErr) || optErr.Option != "listen" {
+			t.Errorf("listen %q: err = %v; 期望 OptionError", listen, err)
+		}
+	}
+}
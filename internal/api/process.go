@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// stopSignals 项目的停止方式，command 表示运行自定义停止命令
+var stopSignals = []string{"SIGTERM", "SIGINT", "command"}
+
+const (
+	// defaultStopTimeout 默认等待实例退出的秒数，maxStopTimeout 为上限
+	defaultStopTimeout = 10
+	maxStopTimeout     = 600
+)
+
+// StopSettingsHandler 获取项目的停止方式
+// 参数: ?id=1
+func StopSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	s, err := loadStopSettings(id)
+	if err != nil {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// SaveStopSettingsHandler 保存项目的停止方式，下次停止或重启时生效
+// 请求体: {"project_id": 1, "signal": "SIGINT", "command": "", "timeout": 30, "kill_group": true}
+func SaveStopSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var s models.StopSettings
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkStopSettings(&s); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	result, err := database.GetDB().Exec("UPDATE projects SET stop_signal=?, stop_command=?, stop_timeout=?, kill_process_group=? WHERE id=?",
+		s.Signal, s.Command, s.Timeout, s.KillGroup, s.ProjectID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "停止设置已保存",
+		"settings": s,
+	})
+}
+
+// checkStopSettings 检查停止方式，未填写的信号和超时使用默认值
+func checkStopSettings(s *models.StopSettings) error {
+	owner := fmt.Sprintf("项目 #%d", s.ProjectID)
+	s.Signal = strings.ToUpper(strings.TrimSpace(s.Signal))
+	s.Command = strings.TrimSpace(s.Command)
+	switch s.Signal {
+	case "":
+		s.Signal = "SIGTERM"
+	case "COMMAND":
+		s.Signal = "command"
+	}
+
+	valid := false
+	for _, signal := range stopSignals {
+		valid = valid || signal == s.Signal
+	}
+	if !valid {
+		return &caddyfile.OptionError{Owner: owner, Option: "signal", Value: s.Signal, Reason: "可选值: " + strings.Join(stopSignals, ", ")}
+	}
+	if s.Signal == "command" && s.Command == "" {
+		return &caddyfile.OptionError{Owner: owner, Option: "command", Reason: "使用停止命令时需要填写命令"}
+	}
+	if s.Signal != "command" {
+		s.Command = ""
+	}
+	if s.Timeout == 0 {
+		s.Timeout = defaultStopTimeout
+	}
+	if s.Timeout < 1 || s.Timeout > maxStopTimeout {
+		return &caddyfile.OptionError{Owner: owner, Option: "timeout", Value: strconv.Itoa(s.Timeout), Reason: fmt.Sprintf("应为 1-%d 秒", maxStopTimeout)}
+	}
+	return nil
+}
+
+// loadStopSettings 读取项目的停止方式
+func loadStopSettings(id int) (models.StopSettings, error) {
+	s := models.StopSettings{ProjectID: id}
+	err := database.GetDB().QueryRow(`SELECT COALESCE(stop_signal, 'SIGTERM'), COALESCE(stop_command, ''),
+		COALESCE(stop_timeout, 10), COALESCE(kill_process_group, 1) FROM projects WHERE id=?`, id).
+		Scan(&s.Signal, &s.Command, &s.Timeout, &s.KillGroup)
+	if err != nil {
+		return s, err
+	}
+	if checkStopSettings(&s) != nil {
+		s = models.StopSettings{ProjectID: id, Signal: "SIGTERM", Timeout: defaultStopTimeout, KillGroup: s.KillGroup}
+	}
+	return s, nil
+}
+
+// stopInstances 按项目的停止方式结束所有实例并等待退出，没有运行中的实例时返回 false
+func stopInstances(id int) bool {
+	processMutex.Lock()
+	instances := projectProcesses[id]
+	delete(projectProcesses, id)
+	processMutex.Unlock()
+	if len(instances) == 0 {
+		return false
+	}
+
+	s, err := loadStopSettings(id)
+	if err != nil {
+		// 项目已删除时使用默认方式
+		s = models.StopSettings{ProjectID: id, Signal: "SIGTERM", Timeout: defaultStopTimeout, KillGroup: true}
+	}
+	var wg sync.WaitGroup
+	for _, proc := range instances {
+		wg.Add(1)
+		go func(proc *instanceProcess) {
+			defer wg.Done()
+			stopInstance(proc, s)
+		}(proc)
+	}
+	wg.Wait()
+	return true
+}
+
+// stopInstance 请求实例退出，超时未退出时强制结束；结束进程组时还会等待组内的子进程退出
+func stopInstance(proc *instanceProcess, s models.StopSettings) {
+	// 正在等待重启的实例已经退出，移出列表即取消重启
+	select {
+	case <-proc.exited:
+		return
+	default:
+	}
+	pid := proc.cmd.Process.Pid
+	deadline := time.Now().Add(time.Duration(s.Timeout) * time.Second)
+
+	var err error
+	if s.Signal == "command" {
+		err = runStopCommand(proc, s.Command, time.Until(deadline))
+	} else {
+		err = signalProcess(pid, s.Signal, s.KillGroup)
+	}
+	if err != nil {
+		log.Printf("⚠️ 无法请求实例 (PID %d) 退出，强制结束: %v", pid, err)
+		forceKill(proc, s.KillGroup)
+		return
+	}
+	select {
+	case <-proc.exited:
+	case <-time.After(time.Until(deadline)):
+		log.Printf("⚠️ 实例 (PID %d) %d 秒内未退出，强制结束", pid, s.Timeout)
+		forceKill(proc, s.KillGroup)
+		return
+	}
+
+	if !s.KillGroup {
+		return
+	}
+	// 主进程正常退出后组内可能还有子进程，等到超时后一并结束
+	for groupAlive(pid) {
+		if time.Now().After(deadline) {
+			log.Printf("⚠️ 实例 (PID %d) 的子进程未退出，强制结束进程组", pid)
+			killProcess(pid, true)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// forceKill 强制结束实例并等待退出
+func forceKill(proc *instanceProcess, group bool) {
+	killProcess(proc.cmd.Process.Pid, group)
+	proc.cmd.Process.Kill()
+	<-proc.exited
+}
+
+// runStopCommand 在项目目录中运行停止命令，环境变量与实例相同，另有 MAIN_PID 为实例的进程号
+func runStopCommand(proc *instanceProcess, command string, timeout time.Duration) error {
+	parts := strings.Fields(command)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	cmd.Dir = proc.cmd.Dir
+	cmd.Env = append(proc.cmd.Env, fmt.Sprintf("MAIN_PID=%d", proc.cmd.Process.Pid))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("停止命令失败: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/models"
+)
+
+func TestCheckStopSettings(t *testing.T) {
+	cases := []struct {
+		in, want models.StopSettings
+	}{
+		{models.StopSettings{}, models.StopSettings{Signal: "SIGTERM", Timeout: defaultStopTimeout}},
+		{models.StopSettings{Signal: " sigint ", Command: "ignored", Timeout: 30, KillGroup: true},
+			models.StopSettings{Signal: "SIGINT", Timeout: 30, KillGroup: true}},
+		{models.StopSettings{Signal: "Command", Command: "  ./stop.sh --graceful ", Timeout: maxStopTimeout},
+			models.StopSettings{Signal: "command", Command: "./stop.sh --graceful", Timeout: maxStopTimeout}},
+		{models.StopSettings{Signal: "SIGTERM", Timeout: 1}, models.StopSettings{Signal: "SIGTERM", Timeout: 1}},
+	}
+	for _, tc := range cases {
+		got := tc.in
+		if err := checkStopSettings(&got); err != nil || got != tc.want {
+			t.Errorf("checkStopSettings(%+v) = %+v, %v; 期望 %+v", tc.in, got, err, tc.want)
+		}
+	}
+
+	invalid := map[string]models.StopSettings{
+		"signal":  {Signal: "SIGKILL"},
+		"command": {Signal: "command", Command: "  "},
+		"timeout": {Timeout: maxStopTimeout + 1},
+	}
+	for option, s := range invalid {
+		var optErr *caddyfile.OptionError
+		if err := checkStopSettings(&s); !errors.As(err, &optErr) || optErr.Option != option {
+			t.Errorf("checkStopSettings(%+v) = %v; 期望 %s 的 OptionError", s, err, option)
+		}
+	}
+	negative := models.StopSettings{Timeout: -1}
+	if err := checkStopSettings(&negative); err == nil {
+		t.Error("checkStopSettings(timeout=-1) 期望返回错误")
+	}
+}
@@ -0,0 +1,61 @@
+//go:build !windows
+
+package api
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup 让实例成为新进程组的组长，停止时可以连同它启动的子进程一起结束
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcess 向进程发送 SIGTERM 或 SIGINT，group 时发送给整个进程组
+func signalProcess(pid int, signal string, group bool) error {
+	sig := syscall.SIGTERM
+	if signal == "SIGINT" {
+		sig = syscall.SIGINT
+	}
+	if group {
+		pid = -pid
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// killProcess 发送 SIGKILL 强制结束进程，group 时结束整个进程组
+func killProcess(pid int, group bool) error {
+	if group {
+		pid = -pid
+	}
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
+
+// groupAlive 检查以 pid 为组长的进程组中是否还有进程（组长退出后子进程仍在同一组中）
+func groupAlive(pid int) bool {
+	return syscall.Kill(-pid, 0) == nil
+}
+
+// killByPort 通过 lsof 查找监听端口的进程并强制结束，没有 lsof 时不做处理
+func killByPort(port int) error {
+	out, err := exec.Command("lsof", "-t", "-sTCP:LISTEN", "-iTCP:"+strconv.Itoa(port)).Output()
+	if err != nil {
+		// 没有进程监听时 lsof 也返回非零状态
+		return nil
+	}
+	for _, field := range strings.Fields(string(out)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+	return nil
+}
+
+// portUsageCommand 返回查看端口占用进程的命令
+func portUsageCommand(port int) string {
+	return fmt.Sprintf("ss -ltnp 'sport = :%d' 或 lsof -iTCP:%d -sTCP:LISTEN", port, port)
+}
@@ -0,0 +1,108 @@
+//go:build !windows
+
+package api
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"caddy-manager/internal/models"
+)
+
+// startTestInstance 在新进程组中运行 sh 脚本，像 startProject 一样在进程退出并被回收后关闭 exited
+func startTestInstance(t *testing.T, script string) *instanceProcess {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	proc := &instanceProcess{cmd: cmd, startedAt: time.Now(), exited: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(proc.exited)
+	}()
+	t.Cleanup(func() { killProcess(cmd.Process.Pid, true) })
+	// 等待 shell 设置好 trap
+	time.Sleep(200 * time.Millisecond)
+	return proc
+}
+
+// waitGroupGone 等待进程组中的进程全部退出（被 SIGKILL 的子进程可能还需要片刻才被回收）
+func waitGroupGone(pid int) bool {
+	for i := 0; i < 50; i++ {
+		if !groupAlive(pid) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func TestStopInstance(t *testing.T) {
+	const loop = "while :; do sleep 0.1; done"
+	cases := []struct {
+		name     string
+		script   string
+		settings models.StopSettings
+		min, max time.Duration // stopInstance 的耗时范围
+	}{
+		{"收到 SIGTERM 后退出", "trap 'exit 0' TERM; " + loop,
+			models.StopSettings{Signal: "SIGTERM", Timeout: 5, KillGroup: true}, 0, 2 * time.Second},
+		{"收到 SIGINT 后退出", "trap 'exit 0' INT; trap '' TERM; " + loop,
+			models.StopSettings{Signal: "SIGINT", Timeout: 5, KillGroup: true}, 0, 2 * time.Second},
+		{"忽略 SIGTERM，超时后强制结束", "trap '' TERM; " + loop,
+			models.StopSettings{Signal: "SIGTERM", Timeout: 1, KillGroup: true}, time.Second, 3 * time.Second},
+		{"忽略 SIGTERM，不结束进程组时也强制结束主进程", "trap '' TERM; " + loop,
+			models.StopSettings{Signal: "SIGTERM", Timeout: 1}, time.Second, 3 * time.Second},
+		{"主进程退出后子进程未退出，超时后结束进程组", "trap 'exit 0' TERM; (trap '' TERM; " + loop + ") & wait",
+			models.StopSettings{Signal: "SIGTERM", Timeout: 1, KillGroup: true}, time.Second, 3 * time.Second},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			proc := startTestInstance(t, tc.script)
+			start := time.Now()
+			stopInstance(proc, tc.settings)
+			elapsed := time.Since(start)
+
+			select {
+			case <-proc.exited:
+			default:
+				t.Fatal("stopInstance 返回时实例仍在运行")
+			}
+			if elapsed < tc.min || elapsed > tc.max {
+				t.Errorf("耗时 %v; 期望 %v-%v", elapsed, tc.min, tc.max)
+			}
+			if tc.settings.KillGroup && !waitGroupGone(proc.cmd.Process.Pid) {
+				t.Error("进程组中仍有进程")
+			}
+		})
+	}
+}
+
+func TestStopInstanceCommand(t *testing.T) {
+	proc := startTestInstance(t, "trap 'exit 0' USR1; trap '' TERM; while :; do sleep 0.1; done")
+	s := models.StopSettings{Signal: "command", Command: fmt.Sprintf("kill -USR1 %d", proc.cmd.Process.Pid), Timeout: 5, KillGroup: true}
+
+	start := time.Now()
+	stopInstance(proc, s)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("停止命令耗时 %v; 期望实例收到 USR1 后立即退出", elapsed)
+	}
+	if proc.cmd.ProcessState == nil || !proc.cmd.ProcessState.Success() {
+		t.Errorf("实例退出状态 = %v; 期望由停止命令正常结束", proc.cmd.ProcessState)
+	}
+}
+
+func TestStopInstanceExited(t *testing.T) {
+	// 已退出（正在等待重启）的实例直接返回
+	proc := startTestInstance(t, "exit 3")
+	<-proc.exited
+	start := time.Now()
+	stopInstance(proc, models.StopSettings{Signal: "SIGTERM", Timeout: 5, KillGroup: true})
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("耗时 %v; 期望立即返回", elapsed)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"caddy-manager/internal/system"
+)
+
+// setProcessGroup 在新的进程组中启动实例，不接收管理器控制台的 Ctrl+C
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcess Windows 没有信号，SIGTERM 和 SIGINT 都通过不带 /F 的 taskkill 请求进程退出，
+// group 时包括子进程（/T）。没有窗口的控制台程序可能拒绝，此时返回错误，由调用方强制结束
+func signalProcess(pid int, signal string, group bool) error {
+	args := []string{"/PID", strconv.Itoa(pid)}
+	if group {
+		args = append(args, "/T")
+	}
+	cmd := exec.Command("taskkill", args...)
+	system.HideWindow(cmd)
+	return cmd.Run()
+}
+
+// killProcess 强制结束进程，group 时结束整个进程树
+func killProcess(pid int, group bool) error {
+	args := []string{"/F", "/PID", strconv.Itoa(pid)}
+	if group {
+		args = append(args, "/T")
+	}
+	cmd := exec.Command("taskkill", args...)
+	system.HideWindow(cmd)
+	return cmd.Run()
+}
+
+// groupAlive Windows 按进程树而不是进程组结束，主进程退出后无法再找到子进程
+func groupAlive(pid int) bool {
+	return false
+}
+
+// killByPort 通过 netstat 查找监听端口的进程并强制结束
+func killByPort(port int) error {
+	// 使用 netstat -ano 查找监听该端口的 PID
+	cmd := exec.Command("netstat", "-ano")
+	system.HideWindow(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(out), "\n")
+	portStr := fmt.Sprintf(":%d", port)
+	var pid string
+	for _, line := range lines {
+		if strings.Contains(line, portStr) && strings.Contains(line, "LISTENING") {
+			f := strings.Fields(line)
+			if len(f) >= 5 {
+				pid = f[len(f)-1]
+				break
+			}
+		}
+	}
+	if pid == "" {
+		return nil
+	}
+	// 强制结束该 PID
+	tk := exec.Command("taskkill", "/F", "/PID", pid)
+	system.HideWindow(tk)
+	_ = tk.Run()
+	return nil
+}
+
+// portUsageCommand 返回查看端口占用进程的命令
+func portUsageCommand(port int) string {
+	return fmt.Sprintf("netstat -ano | findstr :%d", port)
+}
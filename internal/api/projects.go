@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -10,23 +11,50 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"time"
 
-	"caddy-manager/internal/caddy"
 	"caddy-manager/internal/config"
 	"caddy-manager/internal/database"
 	"caddy-manager/internal/models"
+	"caddy-manager/internal/system"
 )
 
+// maxInstances 单个项目最多运行的实例数
+const maxInstances = 16
+
+// instanceProcess 项目的一个运行实例
+type instanceProcess struct {
+	cmd       *exec.Cmd
+	startedAt time.Time
+	// exited 进程退出并被回收后关闭
+	exited chan struct{}
+	// restarting 进程已意外退出，正在等待按重启策略重新启动
+	restarting bool
+	// restarts 最近几次自动重启的时间，用于限制时间窗口内的重启次数
+	restarts []time.Time
+}
+
+// InstanceStatus 项目实例的运行状态
+type InstanceStatus struct {
+	Index     int    `json:"index"`
+	Port      int    `json:"port"`
+	Status    string `json:"status"`
+	PID       int    `json:"pid,omitempty"`
+	StartedAt string `json:"started_at,omitempty"`
+}
+
 var (
-	projectProcesses = make(map[int]*exec.Cmd)
+	// projectProcesses 项目 ID -> 实例端口 -> 进程
+	projectProcesses = make(map[int]map[int]*instanceProcess)
 	processMutex     sync.RWMutex
 )
 
 // ProjectsHandler 获取项目列表
 func ProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	db := database.GetDB()
-	rows, err := db.Query("SELECT id, name, project_type, root_dir, exec_path, port, start_command, auto_start, status, domains, ssl_enabled, description, COALESCE(use_ipv4, 1) FROM projects ORDER BY created_at DESC")
+	rows, err := db.Query(`SELECT id, name, project_type, root_dir, exec_path, port, start_command, auto_start, status, domains, ssl_enabled, description, COALESCE(use_ipv4, 1), reverse_proxy_path, COALESCE(strip_path_prefix, 0),
+		COALESCE(instances, 1), COALESCE(lb_policy, ''), COALESCE(lb_retries, 0), COALESCE(lb_try_duration, ''), COALESCE(health_uri, ''), COALESCE(health_interval, ''), COALESCE(tls_mode, ''),
+		COALESCE(ssl_email, ''), COALESCE(acme_ca, ''), COALESCE(acme_ca_url, ''), COALESCE(acme_eab_key_id, ''), COALESCE(acme_eab_hmac_key, ''), COALESCE(acme_ca_root, ''), COALESCE(dns_provider_id, 0) FROM projects ORDER BY created_at DESC`)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -36,8 +64,10 @@ func ProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	var projects []models.Project
 	for rows.Next() {
 		var p models.Project
-		var execPath, startCmd, domains, desc *string
-		if err := rows.Scan(&p.ID, &p.Name, &p.ProjectType, &p.RootDir, &execPath, &p.Port, &startCmd, &p.AutoStart, &p.Status, &domains, &p.SSLEnabled, &desc, &p.UseIPv4); err != nil {
+		var execPath, startCmd, domains, desc, proxyPath *string
+		if err := rows.Scan(&p.ID, &p.Name, &p.ProjectType, &p.RootDir, &execPath, &p.Port, &startCmd, &p.AutoStart, &p.Status, &domains, &p.SSLEnabled, &desc, &p.UseIPv4, &proxyPath, &p.StripPathPrefix,
+			&p.Instances, &p.LBPolicy, &p.LBRetries, &p.LBTryDuration, &p.HealthURI, &p.HealthInterval, &p.TLSMode,
+			&p.SSLEmail, &p.ACMECA, &p.ACMECAURL, &p.ACMEEABKeyID, &p.ACMEEABHMACKey, &p.ACMECARoot, &p.DNSProviderID); err != nil {
 			continue
 		}
 		if execPath != nil {
@@ -52,9 +82,13 @@ func ProjectsHandler(w http.ResponseWriter, r *http.Request) {
 		if desc != nil {
 			p.Description = *desc
 		}
+		if proxyPath != nil {
+			p.ReverseProxyPath = *proxyPath
+		}
+		p.ACMEEABHMACKey = maskSecret(p.ACMEEABHMACKey)
 		
 		// 更新实时状态
-		p.Status = getProjectStatus(p.ID, p.Port)
+		p.Status = getProjectStatus(&p)
 		
 		projects = append(projects, p)
 	}
@@ -75,12 +109,30 @@ func AddProjectHandler(w http.ResponseWriter, r *http.Request) {
 	if !p.UseIPv4 {
 		p.UseIPv4 = true
 	}
+	if p.Instances < 1 {
+		p.Instances = 1
+	}
 
-	db := database.GetDB()
-	result, err := db.Exec(`INSERT INTO projects 
-		(name, project_type, root_dir, exec_path, port, start_command, auto_start, status, domains, ssl_enabled, ssl_email, reverse_proxy_path, extra_headers, description, use_ipv4) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		p.Name, p.ProjectType, p.RootDir, p.ExecPath, p.Port, p.StartCommand, p.AutoStart, "stopped", p.Domains, p.SSLEnabled, p.SSLEmail, p.ReverseProxyPath, p.ExtraHeaders, p.Description, p.UseIPv4)
+	// 保存前检查域名和路径是否与其他站点、项目冲突
+	if err := checkProjectRoutes(&p); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`INSERT INTO projects 
+		(name, project_type, root_dir, exec_path, port, start_command, auto_start, status, domains, ssl_enabled, ssl_email, reverse_proxy_path, strip_path_prefix, extra_headers, description, use_ipv4,
+		instances, lb_policy, lb_retries, lb_try_duration, health_uri, health_interval, tls_mode,
+		acme_ca, acme_ca_url, acme_eab_key_id, acme_eab_hmac_key, acme_ca_root, dns_provider_id) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Name, p.ProjectType, p.RootDir, p.ExecPath, p.Port, p.StartCommand, p.AutoStart, "stopped", p.Domains, p.SSLEnabled, p.SSLEmail, p.ReverseProxyPath, p.StripPathPrefix, p.ExtraHeaders, p.Description, p.UseIPv4,
+		p.Instances, p.LBPolicy, p.LBRetries, p.LBTryDuration, p.HealthURI, p.HealthInterval, p.TLSMode,
+		p.ACMECA, p.ACMECAURL, p.ACMEEABKeyID, p.ACMEEABHMACKey, p.ACMECARoot, p.DNSProviderID)
 	
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -88,10 +140,17 @@ func AddProjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	projectID, _ := result.LastInsertId()
-	
+	p.ID = int(projectID)
+	if err := saveProjectDomains(tx, &p); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
 	// 生成 Caddyfile
-	generateCaddyfileForProjects()
-	caddy.Restart()
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
 	
 	// 如果设置了自动启动，启动项目
 	if p.AutoStart {
@@ -109,20 +168,49 @@ func UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if p.Instances < 1 {
+		p.Instances = 1
+	}
+	p.ACMEEABHMACKey = storedEABKey("projects", p.ID, p.ACMEEABHMACKey)
 
-	db := database.GetDB()
-	_, err := db.Exec(`UPDATE projects SET 
-		name=?, project_type=?, root_dir=?, exec_path=?, port=?, start_command=?, auto_start=?, domains=?, ssl_enabled=?, ssl_email=?, reverse_proxy_path=?, extra_headers=?, description=?, use_ipv4=?, updated_at=CURRENT_TIMESTAMP 
+	// 保存前检查域名和路径是否与其他站点、项目冲突
+	if err := checkProjectRoutes(&p); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`UPDATE projects SET 
+		name=?, project_type=?, root_dir=?, exec_path=?, port=?, start_command=?, auto_start=?, domains=?, ssl_enabled=?, ssl_email=?, reverse_proxy_path=?, strip_path_prefix=?, extra_headers=?, description=?, use_ipv4=?,
+		instances=?, lb_policy=?, lb_retries=?, lb_try_duration=?, health_uri=?, health_interval=?, tls_mode=?,
+		acme_ca=?, acme_ca_url=?, acme_eab_key_id=?, acme_eab_hmac_key=?, acme_ca_root=?, dns_provider_id=?, updated_at=CURRENT_TIMESTAMP 
 		WHERE id=?`,
-		p.Name, p.ProjectType, p.RootDir, p.ExecPath, p.Port, p.StartCommand, p.AutoStart, p.Domains, p.SSLEnabled, p.SSLEmail, p.ReverseProxyPath, p.ExtraHeaders, p.Description, p.UseIPv4, p.ID)
+		p.Name, p.ProjectType, p.RootDir, p.ExecPath, p.Port, p.StartCommand, p.AutoStart, p.Domains, p.SSLEnabled, p.SSLEmail, p.ReverseProxyPath, p.StripPathPrefix, p.ExtraHeaders, p.Description, p.UseIPv4,
+		p.Instances, p.LBPolicy, p.LBRetries, p.LBTryDuration, p.HealthURI, p.HealthInterval, p.TLSMode,
+		p.ACMECA, p.ACMECAURL, p.ACMEEABKeyID, p.ACMEEABHMACKey, p.ACMECARoot, p.DNSProviderID, p.ID)
 	
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+	if err := saveProjectDomains(tx, &p); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
 
-	generateCaddyfileForProjects()
-	caddy.Restart()
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -135,15 +223,23 @@ func DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
 	// 先停止项目
 	stopProject(id)
 	
-	db := database.GetDB()
-	_, err := db.Exec("DELETE FROM projects WHERE id=?", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := deleteOwner(id, []string{
+		"DELETE FROM projects WHERE id=?",
+		"DELETE FROM basic_auth_users WHERE owner_type='project' AND owner_id=?",
+		"DELETE FROM ip_access_rules WHERE owner_type='project' AND owner_id=?",
+		"DELETE FROM header_rules WHERE owner_type='project' AND owner_id=?",
+		"DELETE FROM upstream_transports WHERE owner_type='project' AND owner_id=?",
+		"DELETE FROM maintenance WHERE owner_type='project' AND owner_id=?",
+		"DELETE FROM domains WHERE owner_type='project' AND owner_id=?",
+		"DELETE FROM project_events WHERE project_id=?",
+		"DELETE FROM project_env WHERE project_id=?",
+	}); err != nil {
+		writeCaddyError(w, err)
 		return
 	}
-
-	generateCaddyfileForProjects()
-	caddy.Restart()
+	if id > 0 {
+		os.RemoveAll(errorPagesDir("project", id))
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -155,8 +251,8 @@ func StartProjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	db := database.GetDB()
 	var p models.Project
-	err := db.QueryRow("SELECT name, project_type, root_dir, exec_path, port, start_command FROM projects WHERE id=?", id).
-		Scan(&p.Name, &p.ProjectType, &p.RootDir, &p.ExecPath, &p.Port, &p.StartCommand)
+	err := db.QueryRow("SELECT name, project_type, root_dir, exec_path, port, start_command, COALESCE(instances, 1) FROM projects WHERE id=?", id).
+		Scan(&p.Name, &p.ProjectType, &p.RootDir, &p.ExecPath, &p.Port, &p.StartCommand, &p.Instances)
 
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -170,18 +266,15 @@ func StartProjectHandler(w http.ResponseWriter, r *http.Request) {
 	
 	p.ID = id
 
-	// 检查管理员权限（用于绑定端口）
-	if !checkAdminPrivileges() {
+	// 检查绑定项目端口所需的权限
+	if !system.CanBindPort(p.Port) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "权限不足",
-			"code":    "ADMIN_REQUIRED",
-			"details": []string{"需要管理员权限来启动项目"},
-			"suggestions": []string{
-				"右键点击程序图标选择'以管理员身份运行'",
-				"或在 PowerShell 中以管理员身份运行: .\\caddy-manager.exe",
-			},
+			"success":     false,
+			"error":       "权限不足",
+			"code":        "ADMIN_REQUIRED",
+			"details":     []string{fmt.Sprintf("需要%s权限来启动监听端口 %d 的项目", system.PrivilegeName, p.Port)},
+			"suggestions": system.PrivilegeSuggestions(),
 		})
 		return
 	}
@@ -199,16 +292,19 @@ func StartProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// 检查端口占用
-	if isPortInUse(p.Port) {
+	// 检查各实例的端口占用
+	for _, port := range instancePorts(&p) {
+		if !isPortInUse(port) {
+			continue
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"error":   "端口已被占用",
 			"code":    "PORT_IN_USE",
-			"details": []string{fmt.Sprintf("端口 %d 已被其他程序占用", p.Port)},
+			"details": []string{fmt.Sprintf("端口 %d 已被其他程序占用", port)},
 			"suggestions": []string{
-				fmt.Sprintf("运行诊断工具查看端口占用: netstat -ano | findstr :%d", p.Port),
+				"查看端口占用: "+portUsageCommand(port),
 				"停止占用该端口的程序",
 				"或修改项目使用其他端口",
 			},
@@ -234,13 +330,14 @@ func StartProjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 更新状态
-	db.Exec("UPDATE projects SET status='running' WHERE id=?", id)
+	setProjectStatus(id, "running")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"message": fmt.Sprintf("项目 '%s' 启动成功", p.Name),
 		"port":    p.Port,
+		"ports":   instancePorts(&p),
 	})
 }
 
@@ -258,6 +355,12 @@ func validateProjectConfig(p *models.Project) []string {
 		errors = append(errors, fmt.Sprintf("❌ 端口号无效: %d (应在 1-65535 之间)", p.Port))
 	}
 	
+	if p.Instances > maxInstances {
+		errors = append(errors, fmt.Sprintf("❌ 实例数过多: %d (最多 %d 个)", p.Instances, maxInstances))
+	} else if p.Instances > 1 && p.Port+p.Instances-1 > 65535 {
+		errors = append(errors, fmt.Sprintf("❌ 实例端口超出范围: %d-%d", p.Port, p.Port+p.Instances-1))
+	}
+	
 	// 静态站点不需要启动命令校验
 	if p.ProjectType == "static" {
 		return errors
@@ -363,8 +466,7 @@ func StopProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
-	db.Exec("UPDATE projects SET status='stopped' WHERE id=?", id)
+	setProjectStatus(id, "stopped")
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -379,8 +481,8 @@ func RestartProjectHandler(w http.ResponseWriter, r *http.Request) {
 	
 	db := database.GetDB()
 	var p models.Project
-	err := db.QueryRow("SELECT name, project_type, root_dir, exec_path, port, start_command FROM projects WHERE id=?", id).
-		Scan(&p.Name, &p.ProjectType, &p.RootDir, &p.ExecPath, &p.Port, &p.StartCommand)
+	err := db.QueryRow("SELECT name, project_type, root_dir, exec_path, port, start_command, COALESCE(instances, 1) FROM projects WHERE id=?", id).
+		Scan(&p.Name, &p.ProjectType, &p.RootDir, &p.ExecPath, &p.Port, &p.StartCommand, &p.Instances)
 	
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -406,7 +508,7 @@ func RestartProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db.Exec("UPDATE projects SET status='running' WHERE id=?", id)
+	setProjectStatus(id, "running")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -417,8 +519,9 @@ func RestartProjectHandler(w http.ResponseWriter, r *http.Request) {
 
 // GetProjectLogsHandler 获取项目日志
 func GetProjectLogsHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	logPath := filepath.Join(config.DataDir, "logs", fmt.Sprintf("project_%s.log", idStr))
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	index, _ := strconv.Atoi(r.URL.Query().Get("instance"))
+	logPath := instanceLogPath(id, index)
 	
 	content := "暂无日志"
 	if data, err := os.ReadFile(logPath); err == nil {
@@ -439,63 +542,122 @@ func GetProjectStatusHandler(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(idStr)
 	
 	db := database.GetDB()
-	var port int
-	err := db.QueryRow("SELECT port FROM projects WHERE id=?", id).Scan(&port)
+	p := models.Project{ID: id}
+	err := db.QueryRow("SELECT port, COALESCE(instances, 1) FROM projects WHERE id=?", id).Scan(&p.Port, &p.Instances)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	
-	status := getProjectStatus(id, port)
+	instances := getInstanceStatus(&p)
+	status := "stopped"
+	running := 0
+	for _, inst := range instances {
+		if inst.Status == "running" {
+			running++
+			status = "running"
+		}
+	}
 	
 	// 更新数据库中的状态
-	db.Exec("UPDATE projects SET status=? WHERE id=?", status, id)
+	setProjectStatus(id, status)
 	
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": status})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    status,
+		"running":   running,
+		"total":     len(instances),
+		"instances": instances,
+	})
 }
 
-// StopAllProjects 停止所有运行中的项目
+// StopAllProjects 按各项目的停止方式同时停止所有运行中的项目，等待全部退出
 func StopAllProjects() {
-	processMutex.Lock()
-	defer processMutex.Unlock()
-	
-	for id, cmd := range projectProcesses {
-		if cmd.Process != nil {
-			cmd.Process.Kill()
-		}
-		
-		// 更新数据库状态
-		db := database.GetDB()
-		db.Exec("UPDATE projects SET status='stopped' WHERE id=?", id)
+	processMutex.RLock()
+	var ids []int
+	for id := range projectProcesses {
+		ids = append(ids, id)
 	}
-	
-	// 清空进程映射
-	projectProcesses = make(map[int]*exec.Cmd)
+	processMutex.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			stopInstances(id)
+			setProjectStatus(id, "stopped")
+		}(id)
+	}
+	wg.Wait()
+}
+
+// instancePorts 返回项目各实例使用的端口，从 Port 开始连续分配
+func instancePorts(p *models.Project) []int {
+	n := p.Instances
+	if n < 1 {
+		n = 1
+	}
+	ports := make([]int, n)
+	for i := range ports {
+		ports[i] = p.Port + i
+	}
+	return ports
+}
+
+// instanceLogPath 实例日志路径，第一个实例沿用原有的项目日志文件
+func instanceLogPath(id, index int) string {
+	if index <= 0 {
+		return filepath.Join(config.DataDir, "logs", fmt.Sprintf("project_%d.log", id))
+	}
+	return filepath.Join(config.DataDir, "logs", fmt.Sprintf("project_%d_%d.log", id, index))
 }
 
 // 内部函数
 func startProject(id int, p *models.Project) error {
+	// 如果已经在运行，先按停止方式结束旧实例，等待端口释放
+	stopInstances(id)
+
 	processMutex.Lock()
 	defer processMutex.Unlock()
 
-	// 如果已经在运行，先停止
-	if cmd, exists := projectProcesses[id]; exists {
-		if cmd.Process != nil {
-			cmd.Process.Kill()
+	// 创建日志目录
+	os.MkdirAll(filepath.Join(config.DataDir, "logs"), 0755)
+
+	ports := instancePorts(p)
+	for index, port := range ports {
+		if err := startInstance(id, p, index, port, "启动"); err != nil {
+			// 任一实例启动失败时停止已启动的实例，避免只有部分实例在运行
+			killInstances(id)
+			if len(ports) > 1 {
+				return fmt.Errorf("实例 %d (端口 %d) 启动失败: %w", index, port, err)
+			}
+			return err
 		}
-		delete(projectProcesses, id)
 	}
 
-	// 创建日志目录
-	logDir := filepath.Join(config.DataDir, "logs")
-	os.MkdirAll(logDir, 0755)
-	
-	logPath := filepath.Join(logDir, fmt.Sprintf("project_%d.log", id))
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	return nil
+}
+
+// startInstance 启动项目的一个实例，通过环境变量 PORT 告知实例监听的端口，reason 记录到项目事件中。
+// 调用方需持有 processMutex
+func startInstance(id int, p *models.Project, index, port int, reason string) error {
+	proc, logFile, err := spawnInstance(id, p, index, port)
 	if err != nil {
 		return err
 	}
+	trackInstance(id, index, port, proc, logFile)
+	recordProjectEvent(models.ProjectEvent{ProjectID: id, Instance: index, Port: port, Event: "start", PID: proc.cmd.Process.Pid, Reason: reason})
+	return nil
+}
+
+// spawnInstance 启动实例进程但不加入列表，不需要持有 processMutex；
+// 返回的进程需交给 trackInstance 跟踪，或由 discardInstance 结束
+func spawnInstance(id int, p *models.Project, index, port int) (*instanceProcess, *os.File, error) {
+	logFile, err := os.OpenFile(instanceLogPath(id, index), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var cmd *exec.Cmd
 	
@@ -503,7 +665,7 @@ switch p.ProjectType {
 case "static":
     // 静态站点：使用 Caddy 自带 file-server 挂载目录到端口
     // 等价命令: caddy file-server --root <dir> --listen :<port> --browse
-    cmd = exec.Command(config.CaddyBin, "file-server", "--root", p.RootDir, "--listen", fmt.Sprintf(":%d", p.Port), "--browse")
+    cmd = exec.Command(config.CaddyBin, "file-server", "--root", p.RootDir, "--listen", fmt.Sprintf(":%d", port), "--browse")
 case "go":
     if p.ExecPath != "" {
         cmd = exec.Command(p.ExecPath)
@@ -534,283 +696,156 @@ case "go":
 	}
 
 if cmd == nil {
-    return fmt.Errorf("无法启动项目：未配置启动命令")
+    logFile.Close()
+    return nil, nil, fmt.Errorf("无法启动项目：未配置启动命令")
 }
 
 	cmd.Dir = p.RootDir
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
+	env, err := projectEnviron(id, p.RootDir, index, port)
+	if err != nil {
+		logFile.Close()
+		return nil, nil, err
+	}
+	cmd.Env = env
+	setProcessGroup(cmd)
 
 	if err := cmd.Start(); err != nil {
 		logFile.Close()
-		return err
+		return nil, nil, err
 	}
+	return &instanceProcess{cmd: cmd, startedAt: time.Now(), exited: make(chan struct{})}, logFile, nil
+}
+
+// trackInstance 把已启动的实例加入列表，并在后台等待其退出后按重启策略处理。调用方需持有 processMutex
+func trackInstance(id, index, port int, proc *instanceProcess, logFile *os.File) {
+	if projectProcesses[id] == nil {
+		projectProcesses[id] = make(map[int]*instanceProcess)
+	}
+	projectProcesses[id][port] = proc
 
-	projectProcesses[id] = cmd
-	
-	// 后台监控进程
 	go func() {
-		cmd.Wait()
+		proc.cmd.Wait()
 		logFile.Close()
-		processMutex.Lock()
-		delete(projectProcesses, id)
-		processMutex.Unlock()
-		
-		// 更新状态
-		db := database.GetDB()
-		db.Exec("UPDATE projects SET status='stopped' WHERE id=?", id)
+		close(proc.exited)
+		handleInstanceExit(id, index, port, proc)
 	}()
+}
 
-	return nil
+// discardInstance 结束不再需要的实例进程（如启动期间实例已被停止）并回收
+func discardInstance(proc *instanceProcess, logFile *os.File) {
+	killProcess(proc.cmd.Process.Pid, true)
+	proc.cmd.Process.Kill()
+	proc.cmd.Wait()
+	logFile.Close()
 }
 
-func stopProject(id int) error {
-    processMutex.Lock()
-    defer processMutex.Unlock()
+// loadStartProject 读取启动项目需要的设置
+func loadStartProject(id int) (*models.Project, error) {
+	p := &models.Project{ID: id}
+	err := database.GetDB().QueryRow(`SELECT name, project_type, COALESCE(root_dir, ''), COALESCE(exec_path, ''), port, COALESCE(start_command, ''),
+		COALESCE(instances, 1) FROM projects WHERE id=?`, id).
+		Scan(&p.Name, &p.ProjectType, &p.RootDir, &p.ExecPath, &p.Port, &p.StartCommand, &p.Instances)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
-    if cmd, exists := projectProcesses[id]; exists {
-        if cmd.Process != nil {
-            cmd.Process.Kill()
-        }
-        delete(projectProcesses, id)
+// killInstances 立即结束项目的所有实例及其子进程，用于启动失败时清理，调用方需持有 processMutex
+func killInstances(id int) {
+	for _, proc := range projectProcesses[id] {
+		if proc.cmd.Process != nil {
+			killProcess(proc.cmd.Process.Pid, true)
+			proc.cmd.Process.Kill()
+		}
+	}
+	delete(projectProcesses, id)
+}
+
+// stopProject 按项目的停止方式结束所有实例，等待实例和子进程退出
+func stopProject(id int) error {
+    if stopInstances(id) {
         return nil
     }
 
-    // 兜底：若未跟踪到进程（如子进程脱离等），尝试按端口终止进程（Windows）
+    // 兜底：若未跟踪到进程（如子进程脱离等），尝试按端口终止进程
     db := database.GetDB()
-    var port int
-    _ = db.QueryRow("SELECT port FROM projects WHERE id=?", id).Scan(&port)
-    if port > 0 {
-        _ = killByPort(port)
-    }
-
-    return nil
-}
-
-// killByPort 在 Windows 上通过端口查找并结束进程
-func killByPort(port int) error {
-    // 使用 netstat -ano 查找监听该端口的 PID
-    cmd := exec.Command("netstat", "-ano")
-    cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-    out, err := cmd.Output()
-    if err != nil {
-        return err
-    }
-    lines := strings.Split(string(out), "\n")
-    portStr := fmt.Sprintf(":%d", port)
-    var pid string
-    for _, line := range lines {
-        if strings.Contains(line, portStr) && strings.Contains(line, "LISTENING") {
-            f := strings.Fields(line)
-            if len(f) >= 5 {
-                pid = f[len(f)-1]
-                break
-            }
+    var p models.Project
+    _ = db.QueryRow("SELECT port, COALESCE(instances, 1) FROM projects WHERE id=?", id).Scan(&p.Port, &p.Instances)
+    if p.Port > 0 {
+        for _, port := range instancePorts(&p) {
+            _ = killByPort(port)
         }
     }
-    if pid == "" {
-        return nil
-    }
-    // 强制结束该 PID
-    tk := exec.Command("taskkill", "/F", "/PID", pid)
-    tk.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-    _ = tk.Run()
+
     return nil
 }
 
-func getProjectStatus(id int, port int) string {
-	processMutex.RLock()
-	_, exists := projectProcesses[id]
-	processMutex.RUnlock()
-
-	if exists {
-		return "running"
-	}
-	
-	// 通过端口检测（Windows）
-	if port > 0 {
-		cmd := exec.Command("netstat", "-ano")
-		cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-		output, err := cmd.Output()
-		if err == nil {
-			lines := strings.Split(string(output), "\n")
-			portStr := fmt.Sprintf(":%d", port)
-			for _, line := range lines {
-				if strings.Contains(line, portStr) && strings.Contains(line, "LISTENING") {
-					return "running"
-				}
-			}
+// getProjectStatus 任一实例在运行即视为运行中
+func getProjectStatus(p *models.Project) string {
+	for _, inst := range getInstanceStatus(p) {
+		if inst.Status == "running" {
+			return "running"
 		}
 	}
-	
 	return "stopped"
 }
 
-func checkAdminPrivileges() bool {
-	cmd := exec.Command("net", "session")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	err := cmd.Run()
-	return err == nil
-}
-
-func isPortInUse(port int) bool {
-	cmd := exec.Command("netstat", "-ano")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	output, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-	
-	lines := strings.Split(string(output), "\n")
-	portStr := fmt.Sprintf(":%d", port)
-	for _, line := range lines {
-		if strings.Contains(line, portStr) && strings.Contains(line, "LISTENING") {
-			return true
-		}
-	}
-	
-	return false
-}
-
-func generateCaddyfileForProjects() error {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT domains, port, ssl_enabled, reverse_proxy_path, extra_headers, COALESCE(use_ipv4, 1) FROM projects WHERE domains != ''")
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	var content string
-
-	// 添加注释头
-	content += "# Caddy 配置文件\n"
-	content += "# 由 Caddy 管理器自动生成\n\n"
+// getInstanceStatus 返回项目每个实例的状态，未由管理器启动的实例通过端口监听检测
+func getInstanceStatus(p *models.Project) []InstanceStatus {
+	ports := instancePorts(p)
+	instances := make([]InstanceStatus, len(ports))
 
-	hasProjects := false
-
-	for rows.Next() {
-		var domains string
-		var port int
-		var sslEnabled bool
-		var useIPv4 bool
-		var reverseProxyPath, extraHeaders *string
-
-		rows.Scan(&domains, &port, &sslEnabled, &reverseProxyPath, &extraHeaders, &useIPv4)
-
-		domainList := strings.Split(domains, "\n")
-		for _, domain := range domainList {
-			domain = strings.TrimSpace(domain)
-			if domain == "" {
-				continue
-			}
-
-			// 验证域名格式
-			if !isValidDomain(domain) {
-				continue
-			}
-
-			hasProjects = true
-			content += domain + " {\n"
-
-			// 反向代理 - 根据 use_ipv4 设置决定使用 IPv4 或 localhost
-			var proxyTarget string
-			if useIPv4 {
-				// 强制使用 IPv4 地址，避免 IPv6 连接问题
-				proxyTarget = fmt.Sprintf("127.0.0.1:%d", port)
-			} else {
-				// 使用 localhost（可能使用 IPv6）
-				proxyTarget = fmt.Sprintf("localhost:%d", port)
-			}
-			
-			content += fmt.Sprintf("    reverse_proxy %s\n", proxyTarget)
-
-			// 额外 Header
-			if extraHeaders != nil && *extraHeaders != "" {
-				headers := strings.Split(*extraHeaders, "\n")
-				for _, header := range headers {
-					if strings.TrimSpace(header) != "" {
-						content += "    header_up " + header + "\n"
-					}
-				}
+	processMutex.RLock()
+	tracked := projectProcesses[p.ID]
+	for i, port := range ports {
+		instances[i] = InstanceStatus{Index: i, Port: port, Status: "stopped"}
+		if proc, ok := tracked[port]; ok && proc.restarting {
+			instances[i].Status = "restarting"
+		} else if ok {
+			instances[i].Status = "running"
+			instances[i].StartedAt = proc.startedAt.Format("2006-01-02 15:04:05")
+			if proc.cmd.Process != nil {
+				instances[i].PID = proc.cmd.Process.Pid
 			}
-
-			content += "}\n\n"
 		}
 	}
+	processMutex.RUnlock()
 
-	// 如果没有项目，添加默认配置
-	if !hasProjects {
-		content += "# 暂无项目配置\n"
-		content += "# 通过管理界面添加项目后会自动生成配置\n\n"
-		content += ":80 {\n"
-		content += "    respond \"Caddy 正在运行\" 200\n"
-		content += "}\n"
+	// 不是由管理器启动的实例，通过端口是否有程序监听判断
+	for i := range instances {
+		if instances[i].Status == "stopped" && instances[i].Port > 0 && isPortInUse(instances[i].Port) {
+			instances[i].Status = "running"
+		}
 	}
 
-	return os.WriteFile(config.CaddyConfig, []byte(content), 0644)
+	return instances
 }
 
-// 验证域名格式
-func isValidDomain(domain string) bool {
-	// 移除端口号（如果有）
-	if idx := strings.Index(domain, ":"); idx > 0 {
-		domain = domain[:idx]
-	}
-	
-	// 检查长度
-	if len(domain) == 0 || len(domain) > 253 {
-		return false
-	}
-	
-	// 检查是否包含空格或其他非法字符
-	if strings.Contains(domain, " ") || strings.Contains(domain, "\t") {
-		return false
-	}
-	
-	// 简单的域名格式检查
-	parts := strings.Split(domain, ".")
-	
-	// 至少要有一个点（如 example.com）
-	if len(parts) < 2 {
-		// 除非是 localhost 或 IP 地址
-		if domain != "localhost" && !strings.Contains(domain, ":") {
-			// 检查是否是合法的单字符标签（用于测试）
-			if len(domain) == 0 {
-				return false
-			}
-		}
+// isPortInUse 检查端口是否已有程序监听：能连上本机端口，或无法在该端口上监听
+func isPortInUse(port int) bool {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 500*time.Millisecond)
+	if err == nil {
+		conn.Close()
+		return true
 	}
-	
-	// 检查每个部分
-	for _, part := range parts {
-		if len(part) == 0 || len(part) > 63 {
-			return false
-		}
-		
-		// 每个部分只能包含字母、数字、连字符
-		for i, c := range part {
-			if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || 
-				 (c >= '0' && c <= '9') || c == '-' || c == '_') {
-				return false
-			}
-			// 不能以连字符开头或结尾
-			if c == '-' && (i == 0 || i == len(part)-1) {
-				return false
-			}
-		}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return true
 	}
-	
-	return true
+	ln.Close()
+	return false
 }
 
-
 // AutoStartProject 自动启动项目（用于系统启动时）
 func AutoStartProject(id int) error {
 db := database.GetDB()
 var p models.Project
 
-err := db.QueryRow(`SELECT name, project_type, root_dir, exec_path, port, start_command 
-FROM projects WHERE id=?`, id).Scan(&p.Name, &p.ProjectType, &p.RootDir, &p.ExecPath, &p.Port, &p.StartCommand)
+err := db.QueryRow(`SELECT name, project_type, root_dir, exec_path, port, start_command, COALESCE(instances, 1) 
+FROM projects WHERE id=?`, id).Scan(&p.Name, &p.ProjectType, &p.RootDir, &p.ExecPath, &p.Port, &p.StartCommand, &p.Instances)
 
 if err != nil {
 return err
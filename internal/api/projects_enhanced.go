@@ -7,9 +7,9 @@ import (
 	"net/http"
 	"strings"
 	
-	"caddy-manager/internal/caddy"
 	"caddy-manager/internal/database"
 	"caddy-manager/internal/models"
+	"caddy-manager/internal/system"
 )
 
 // AddProjectHandlerV2 增强版添加项目处理器
@@ -22,6 +22,10 @@ func AddProjectHandlerV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if p.Instances < 1 {
+		p.Instances = 1
+	}
+
 	// 验证配置
 	validationErrors := validateProjectConfig(&p)
 	if len(validationErrors) > 0 {
@@ -31,11 +35,30 @@ func AddProjectHandlerV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
-	result, err := db.Exec(`INSERT INTO projects
-		(name, project_type, root_dir, exec_path, port, start_command, auto_start, status, domains, ssl_enabled, ssl_email, reverse_proxy_path, extra_headers, description)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		p.Name, p.ProjectType, p.RootDir, p.ExecPath, p.Port, p.StartCommand, p.AutoStart, "stopped", p.Domains, p.SSLEnabled, p.SSLEmail, p.ReverseProxyPath, p.ExtraHeaders, p.Description)
+	// 检查域名和路径是否与其他站点、项目冲突
+	if err := checkProjectRoutes(&p); err != nil {
+		sendJSONResponse(w, false, "域名无效或与其他站点、项目冲突", map[string]interface{}{
+			"details": err.Error(),
+		})
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		sendJSONResponse(w, false, "数据库保存失败", map[string]interface{}{
+			"details": err.Error(),
+		})
+		return
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`INSERT INTO projects
+		(name, project_type, root_dir, exec_path, port, start_command, auto_start, status, domains, ssl_enabled, ssl_email, reverse_proxy_path, strip_path_prefix, extra_headers, description,
+		instances, lb_policy, lb_retries, lb_try_duration, health_uri, health_interval, tls_mode,
+		acme_ca, acme_ca_url, acme_eab_key_id, acme_eab_hmac_key, acme_ca_root, dns_provider_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Name, p.ProjectType, p.RootDir, p.ExecPath, p.Port, p.StartCommand, p.AutoStart, "stopped", p.Domains, p.SSLEnabled, p.SSLEmail, p.ReverseProxyPath, p.StripPathPrefix, p.ExtraHeaders, p.Description,
+		p.Instances, p.LBPolicy, p.LBRetries, p.LBTryDuration, p.HealthURI, p.HealthInterval, p.TLSMode,
+		p.ACMECA, p.ACMECAURL, p.ACMEEABKeyID, p.ACMEEABHMACKey, p.ACMECARoot, p.DNSProviderID)
 
 	if err != nil {
 		sendJSONResponse(w, false, "数据库保存失败", map[string]interface{}{
@@ -46,23 +69,20 @@ func AddProjectHandlerV2(w http.ResponseWriter, r *http.Request) {
 
 	projectID, _ := result.LastInsertId()
 	p.ID = int(projectID)
-
-	// 生成 Caddyfile
-	if err := generateCaddyfileForProjects(); err != nil {
-		sendJSONResponse(w, true, "项目已创建，但 Caddy 配置可能有问题", map[string]interface{}{
-			"warning": "Caddyfile 生成失败",
+	if err := saveProjectDomains(tx, &p); err != nil {
+		sendJSONResponse(w, false, "数据库保存失败", map[string]interface{}{
 			"details": err.Error(),
 		})
 		return
 	}
 
-	// 重启 Caddy
-	if err := caddy.Restart(); err != nil {
-		sendJSONResponse(w, true, "项目已创建，但 Caddy 未能重启", map[string]interface{}{
-			"warning": "Caddy 重启失败",
+	// 生成 Caddyfile 并重新加载（校验失败时保持原有配置）
+	if err := applyTx(tx); err != nil {
+		sendJSONResponse(w, false, "Caddy 配置未能应用，项目未创建", map[string]interface{}{
+			"warning": "Caddy 配置校验或加载失败，已保留原有配置",
 			"details": err.Error(),
 			"suggestions": []string{
-				"请手动重启 Caddy 服务",
+				"检查项目的域名和 Header 配置",
 				"或查看 Caddy 日志了解详情",
 			},
 		})
@@ -72,8 +92,8 @@ func AddProjectHandlerV2(w http.ResponseWriter, r *http.Request) {
 	// SSL 检查
 	sslWarnings := []string{}
 	if p.SSLEnabled && p.Domains != "" {
-		if !checkAdminPrivileges() {
-			sslWarnings = append(sslWarnings, "⚠ 未以管理员身份运行，无法绑定 443 端口")
+		if !system.CanBindPort(443) {
+			sslWarnings = append(sslWarnings, fmt.Sprintf("⚠ 未以%s身份运行，无法绑定 443 端口", system.PrivilegeName))
 		}
 		
 		domains := strings.Split(p.Domains, "\n")
@@ -126,7 +146,7 @@ func checkDomainForSSL(domain string) []string {
 	for _, ip := range ips {
 		ipStr := ip.String()
 		if strings.HasPrefix(ipStr, "104.21.") || strings.HasPrefix(ipStr, "172.67.") || strings.HasPrefix(ipStr, "104.18.") {
-			warnings = append(warnings, fmt.Sprintf("⚠ 域名 %s 使用 Cloudflare CDN，建议:\n  1. 使用 Flexible SSL 模式\n  2. 或临时关闭 Cloudflare 代理申请证书", domain))
+			warnings = append(warnings, fmt.Sprintf("⚠ 域名 %s 使用 Cloudflare CDN，建议:\n  1. 配置 DNS 服务商，使用 DNS 验证申请证书\n  2. 或使用 Flexible SSL 模式", domain))
 			break
 		}
 	}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// RedirectsHandler 列出站点的跳转规则
+func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, _ := strconv.Atoi(r.URL.Query().Get("site_id"))
+	rules, err := loadRedirectRules(database.GetDB(), siteID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// SaveRedirectHandler 添加或修改跳转规则，保存前检查格式和跳转循环
+// 请求体: {"id": 0, "site_id": 1, "source": "/blog/*", "target": "https://blog.example.com/*", "status": 301}
+func SaveRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rule models.RedirectRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule.Source = strings.TrimSpace(rule.Source)
+	rule.Target = strings.TrimSpace(rule.Target)
+	if rule.Status == 0 {
+		rule.Status = 301
+	}
+
+	var exists int
+	if err := database.GetDB().QueryRow("SELECT COUNT(*) FROM sites WHERE id = ?", rule.SiteID).Scan(&exists); err != nil || exists == 0 {
+		http.Error(w, "站点不存在", http.StatusNotFound)
+		return
+	}
+
+	rules, err := loadRedirectRules(database.GetDB(), rule.SiteID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	candidate := []models.RedirectRule{}
+	for _, existing := range rules {
+		if existing.ID != rule.ID {
+			candidate = append(candidate, existing)
+		}
+	}
+	candidate = append(candidate, rule)
+	if err := checkSiteRedirects(rule.SiteID, candidate); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if rule.ID > 0 {
+		_, err = tx.Exec("UPDATE redirect_rules SET source=?, target=?, status=? WHERE id=? AND site_id=?",
+			rule.Source, rule.Target, rule.Status, rule.ID, rule.SiteID)
+	} else {
+		_, err = tx.Exec("INSERT INTO redirect_rules (site_id, source, target, status) VALUES (?, ?, ?, ?)",
+			rule.SiteID, rule.Source, rule.Target, rule.Status)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "跳转规则已保存",
+	})
+}
+
+// DeleteRedirectHandler 删除跳转规则
+func DeleteRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM redirect_rules WHERE id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// loadRedirectRules 读取站点的跳转规则，按添加顺序排列
+func loadRedirectRules(q database.Querier, siteID int) ([]models.RedirectRule, error) {
+	rows, err := q.Query("SELECT id, site_id, source, target, COALESCE(status, 301) FROM redirect_rules WHERE site_id = ? ORDER BY id", siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.RedirectRule{}
+	for rows.Next() {
+		var rule models.RedirectRule
+		if err := rows.Scan(&rule.ID, &rule.SiteID, &rule.Source, &rule.Target, &rule.Status); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// checkSiteRedirects 用候选的跳转规则替换站点现有规则后检查整个配置，
+// 跳转站点在规则之后的整站跳转保留，一并参与循环检测
+func checkSiteRedirects(siteID int, rules []models.RedirectRule) error {
+	db := database.GetDB()
+	current, err := siteRedirects(db, siteID)
+	if err != nil {
+		return err
+	}
+
+	cfg := &caddyfile.Config{}
+	if err := addSiteRoutes(db, cfg, 0); err != nil {
+		return err
+	}
+	if err := addProjectRoutes(db, cfg, 0); err != nil {
+		return err
+	}
+
+	owner := fmt.Sprintf("站点 #%d", siteID)
+	for i := range cfg.Sites {
+		for j := range cfg.Sites[i].Routes {
+			if route := &cfg.Sites[i].Routes[j]; route.Owner == owner && len(route.Redirects) >= len(current) {
+				route.Redirects = append(redirectsFor(rules), route.Redirects[len(current):]...)
+			}
+		}
+	}
+	return cfg.Validate()
+}
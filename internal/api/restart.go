@@ -0,0 +1,309 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// restartPolicies 进程退出后的重启策略
+var restartPolicies = []string{"never", "on-failure", "always"}
+
+const (
+	// maxRestartBackoff 重启间隔从 1 秒开始翻倍，最长等待时间
+	maxRestartBackoff = time.Minute
+	// maxProjectEvents 每个项目保留的事件数
+	maxProjectEvents = 500
+)
+
+// RestartPolicyHandler 获取项目的重启策略
+// 参数: ?id=1
+func RestartPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	policy, err := loadRestartPolicy(id)
+	if err != nil {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// SaveRestartPolicyHandler 保存项目的重启策略，对之后退出的进程生效
+// 请求体: {"project_id": 1, "policy": "on-failure", "max_retries": 5, "window": 300}
+func SaveRestartPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var policy models.RestartPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkRestartPolicy(&policy); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	result, err := database.GetDB().Exec("UPDATE projects SET restart_policy=?, restart_max_retries=?, restart_window=? WHERE id=?",
+		policy.Policy, policy.MaxRetries, policy.Window, policy.ProjectID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "项目不存在", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "重启策略已保存",
+		"policy":  policy,
+	})
+}
+
+// ProjectEventsHandler 获取项目最近的启动、退出和重启记录，最新的在前
+// 参数: ?id=1&limit=100
+func ProjectEventsHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > maxProjectEvents {
+		limit = 100
+	}
+
+	rows, err := database.GetDB().Query(`SELECT id, project_id, instance, port, event, pid, exit_code, signal, duration_ms, reason, created_at
+		FROM project_events WHERE project_id=? ORDER BY id DESC LIMIT ?`, id, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	events := []models.ProjectEvent{}
+	for rows.Next() {
+		var e models.ProjectEvent
+		if err := rows.Scan(&e.ID, &e.ProjectID, &e.Instance, &e.Port, &e.Event, &e.PID, &e.ExitCode, &e.Signal, &e.DurationMS, &e.Reason, &e.CreatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		events = append(events, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// checkRestartPolicy 检查重启策略，未填写的项使用默认值
+func checkRestartPolicy(policy *models.RestartPolicy) error {
+	owner := fmt.Sprintf("项目 #%d", policy.ProjectID)
+	policy.Policy = strings.ToLower(strings.TrimSpace(policy.Policy))
+	if policy.Policy == "" {
+		policy.Policy = "never"
+	}
+	valid := false
+	for _, p := range restartPolicies {
+		valid = valid || p == policy.Policy
+	}
+	if !valid {
+		return &caddyfile.OptionError{Owner: owner, Option: "policy", Value: policy.Policy, Reason: "可选值: " + strings.Join(restartPolicies, ", ")}
+	}
+	if policy.MaxRetries == 0 {
+		policy.MaxRetries = 5
+	}
+	if policy.MaxRetries < 1 || policy.MaxRetries > 100 {
+		return &caddyfile.OptionError{Owner: owner, Option: "max_retries", Value: strconv.Itoa(policy.MaxRetries), Reason: "应为 1-100"}
+	}
+	if policy.Window == 0 {
+		policy.Window = 300
+	}
+	if policy.Window < 10 || policy.Window > 86400 {
+		return &caddyfile.OptionError{Owner: owner, Option: "window", Value: strconv.Itoa(policy.Window), Reason: "应为 10-86400 秒"}
+	}
+	return nil
+}
+
+// loadRestartPolicy 读取项目的重启策略
+func loadRestartPolicy(id int) (models.RestartPolicy, error) {
+	policy := models.RestartPolicy{ProjectID: id}
+	err := database.GetDB().QueryRow(`SELECT COALESCE(restart_policy, 'never'), COALESCE(restart_max_retries, 5), COALESCE(restart_window, 300)
+		FROM projects WHERE id=?`, id).Scan(&policy.Policy, &policy.MaxRetries, &policy.Window)
+	if err != nil {
+		return models.RestartPolicy{ProjectID: id, Policy: "never"}, err
+	}
+	if checkRestartPolicy(&policy) != nil {
+		policy = models.RestartPolicy{ProjectID: id, Policy: "never", MaxRetries: 5, Window: 300}
+	}
+	return policy, nil
+}
+
+// recordProjectEvent 记录项目事件，只保留最近的 maxProjectEvents 条
+func recordProjectEvent(e models.ProjectEvent) {
+	db := database.GetDB()
+	if _, err := db.Exec(`INSERT INTO project_events (project_id, instance, port, event, pid, exit_code, signal, duration_ms, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, e.ProjectID, e.Instance, e.Port, e.Event, e.PID, e.ExitCode, e.Signal, e.DurationMS, e.Reason); err != nil {
+		log.Printf("⚠️ 记录项目事件失败: %v", err)
+		return
+	}
+	db.Exec(`DELETE FROM project_events WHERE project_id=? AND id <= (
+		SELECT id FROM project_events WHERE project_id=? ORDER BY id DESC LIMIT 1 OFFSET ?)`, e.ProjectID, e.ProjectID, maxProjectEvents)
+}
+
+// handleInstanceExit 实例进程退出后调用：由管理器停止的只记录事件；意外退出时按重启策略退避后重新启动，
+// 等待期间实例保留在列表中（状态为 restarting），被停止或重新启动时放弃本次重启
+func handleInstanceExit(id, index, port int, proc *instanceProcess) {
+	code, signal := exitStatus(proc.cmd.ProcessState)
+	exit := models.ProjectEvent{ProjectID: id, Instance: index, Port: port, Event: "exit", PID: proc.cmd.Process.Pid,
+		ExitCode: code, Signal: signal, DurationMS: time.Since(proc.startedAt).Milliseconds()}
+	policy, _ := loadRestartPolicy(id)
+
+	processMutex.Lock()
+	if projectProcesses[id][port] != proc {
+		// 停止时实例已从列表中移除，由停止的一方更新状态；重启后同一端口可能已是新的进程
+		processMutex.Unlock()
+		exit.Reason = "已停止"
+		recordProjectEvent(exit)
+		return
+	}
+
+	window := time.Duration(policy.Window) * time.Second
+	var recent []time.Time
+	for _, t := range proc.restarts {
+		if time.Since(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	if !shouldRestart(policy.Policy, code) {
+		exit.Reason = "进程退出，重启策略: " + policy.Policy
+		removeExitedInstance(id, port, exit)
+		return
+	}
+	if len(recent) >= policy.MaxRetries {
+		exit.Reason = fmt.Sprintf("%d 秒内已重启 %d 次，不再重启", policy.Window, len(recent))
+		removeExitedInstance(id, port, exit)
+		recordProjectEvent(models.ProjectEvent{ProjectID: id, Instance: index, Port: port, Event: "give_up", Reason: exit.Reason})
+		log.Printf("⚠️ 项目 #%d 实例 %d %s", id, index, exit.Reason)
+		return
+	}
+	proc.restarting = true
+	processMutex.Unlock()
+
+	delay := restartBackoff(len(recent))
+	exit.Reason = fmt.Sprintf("意外退出，%s 后重启", delay)
+	recordProjectEvent(exit)
+
+	for {
+		time.Sleep(delay)
+
+		// 按数据库中的最新设置重启，启动命令、环境变量的修改同样生效；
+		// 启动进程时不持有 processMutex，启动后确认实例仍在等待重启才加入列表
+		latest, err := loadStartProject(id)
+		var next *instanceProcess
+		var logFile *os.File
+		if err == nil {
+			next, logFile, err = spawnInstance(id, latest, index, port)
+		}
+
+		processMutex.Lock()
+		if projectProcesses[id][port] != proc {
+			processMutex.Unlock()
+			if next != nil {
+				discardInstance(next, logFile)
+			}
+			return
+		}
+		recent = append(recent, time.Now())
+		if err == nil {
+			next.restarts = recent
+			trackInstance(id, index, port, next, logFile)
+			processMutex.Unlock()
+			recordProjectEvent(models.ProjectEvent{ProjectID: id, Instance: index, Port: port, Event: "start", PID: next.cmd.Process.Pid,
+				Reason: fmt.Sprintf("第 %d 次自动重启", len(recent))})
+			setProjectStatus(id, "running")
+			return
+		}
+		failed := models.ProjectEvent{ProjectID: id, Instance: index, Port: port, Event: "start_failed", Reason: err.Error()}
+		if len(recent) >= policy.MaxRetries {
+			removeExitedInstance(id, port, failed)
+			recordProjectEvent(models.ProjectEvent{ProjectID: id, Instance: index, Port: port, Event: "give_up",
+				Reason: fmt.Sprintf("重启 %d 次均失败，不再重启", len(recent))})
+			return
+		}
+		processMutex.Unlock()
+		recordProjectEvent(failed)
+		delay = restartBackoff(len(recent))
+	}
+}
+
+// removeExitedInstance 从列表中移除已退出的实例并记录事件，所有实例都已退出时更新项目状态。
+// 调用方需持有 processMutex，返回前释放
+func removeExitedInstance(id, port int, e models.ProjectEvent) {
+	instances := projectProcesses[id]
+	delete(instances, port)
+	remaining := len(instances)
+	if remaining == 0 {
+		delete(projectProcesses, id)
+	}
+	processMutex.Unlock()
+
+	recordProjectEvent(e)
+	if remaining == 0 {
+		setProjectStatus(id, "stopped")
+	}
+}
+
+// shouldRestart on-failure 在非零退出码或被信号结束（code 为 nil）时重启
+func shouldRestart(policy string, code *int) bool {
+	switch policy {
+	case "always":
+		return true
+	case "on-failure":
+		return code == nil || *code != 0
+	}
+	return false
+}
+
+// restartBackoff 第 n 次（从 0 开始）重启前的等待时间：1s、2s、4s……最长 maxRestartBackoff
+func restartBackoff(n int) time.Duration {
+	if n > 6 {
+		return maxRestartBackoff
+	}
+	delay := time.Second << n
+	if delay > maxRestartBackoff {
+		delay = maxRestartBackoff
+	}
+	return delay
+}
+
+// exitStatus 返回进程的退出码；被信号结束时退出码为 nil，返回信号名称
+func exitStatus(state *os.ProcessState) (*int, string) {
+	if state == nil {
+		return nil, ""
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		names := map[syscall.Signal]string{
+			syscall.SIGHUP: "SIGHUP", syscall.SIGINT: "SIGINT", syscall.SIGQUIT: "SIGQUIT", syscall.SIGABRT: "SIGABRT",
+			syscall.SIGKILL: "SIGKILL", syscall.SIGSEGV: "SIGSEGV", syscall.SIGPIPE: "SIGPIPE", syscall.SIGTERM: "SIGTERM",
+		}
+		if name, ok := names[ws.Signal()]; ok {
+			return nil, name
+		}
+		return nil, ws.Signal().String()
+	}
+	code := state.ExitCode()
+	return &code, ""
+}
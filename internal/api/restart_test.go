@@ -0,0 +1,285 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// testDB 在临时目录中初始化数据库
+func testDB(t *testing.T) {
+	t.Helper()
+	config.DataDir = t.TempDir()
+	config.DatabasePath = filepath.Join(config.DataDir, "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+}
+
+func TestRestartBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:    time.Second,
+		1:    2 * time.Second,
+		2:    4 * time.Second,
+		3:    8 * time.Second,
+		4:    16 * time.Second,
+		5:    32 * time.Second,
+		6:    maxRestartBackoff,
+		7:    maxRestartBackoff,
+		40:   maxRestartBackoff,
+		63:   maxRestartBackoff,
+		64:   maxRestartBackoff,
+		1000: maxRestartBackoff,
+	}
+	for n, want := range cases {
+		if got := restartBackoff(n); got != want {
+			t.Errorf("restartBackoff(%d) = %v; 期望 %v", n, got, want)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	zero, one := 0, 1
+	exits := map[string]*int{"exit 0": &zero, "exit 1": &one, "signal": nil}
+	want := map[string]map[string]bool{
+		"never":      {"exit 0": false, "exit 1": false, "signal": false},
+		"on-failure": {"exit 0": false, "exit 1": true, "signal": true},
+		"always":     {"exit 0": true, "exit 1": true, "signal": true},
+		"":           {"exit 0": false, "exit 1": false, "signal": false},
+	}
+	for policy, results := range want {
+		for exit, code := range exits {
+			if got := shouldRestart(policy, code); got != results[exit] {
+				t.Errorf("shouldRestart(%q, %s) = %v; 期望 %v", policy, exit, got, results[exit])
+			}
+		}
+	}
+}
+
+func TestCheckRestartPolicy(t *testing.T) {
+	valid := map[string]struct {
+		in, want models.RestartPolicy
+	}{
+		"defaults":   {models.RestartPolicy{}, models.RestartPolicy{Policy: "never", MaxRetries: 5, Window: 300}},
+		"normalized": {models.RestartPolicy{Policy: " ON-FAILURE "}, models.RestartPolicy{Policy: "on-failure", MaxRetries: 5, Window: 300}},
+		"bounds_low": {models.RestartPolicy{Policy: "always", MaxRetries: 1, Window: 10}, models.RestartPolicy{Policy: "always", MaxRetries: 1, Window: 10}},
+		"bounds_high": {models.RestartPolicy{Policy: "always", MaxRetries: 100, Window: 86400},
+			models.RestartPolicy{Policy: "always", MaxRetries: 100, Window: 86400}},
+	}
+	for name, tc := range valid {
+		policy := tc.in
+		if err := checkRestartPolicy(&policy); err != nil || policy != tc.want {
+			t.Errorf("%s: checkRestartPolicy = %+v, %v; 期望 %+v", name, policy, err, tc.want)
+		}
+	}
+
+	invalid := map[string]struct {
+		in     models.RestartPolicy
+		option string
+	}{
+		"policy":          {models.RestartPolicy{Policy: "sometimes"}, "policy"},
+		"retries_low":     {models.RestartPolicy{MaxRetries: -1}, "max_retries"},
+		"retries_high":    {models.RestartPolicy{MaxRetries: 101}, "max_retries"},
+		"window_low":      {models.RestartPolicy{Window: 9}, "window"},
+		"window_negative": {models.RestartPolicy{Window: -300}, "window"},
+		"window_high":     {models.RestartPolicy{Window: 86401}, "window"},
+	}
+	for name, tc := range invalid {
+		policy := tc.in
+		var optErr *caddyfile.OptionError
+		if err := checkRestartPolicy(&policy); !errors.As(err, &optErr) || optErr.Option != tc.option {
+			t.Errorf("%s: checkRestartPolicy = %v; 期望 %s 的 OptionError", name, err, tc.option)
+		}
+	}
+}
+
+// startTestProject 创建运行 script 的项目并启动
+func startTestProject(t *testing.T, script, policy string, maxRetries int) int {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	result, err := database.GetDB().Exec(`INSERT INTO projects (name, project_type, root_dir, port, start_command, restart_policy, restart_max_retries, restart_window)
+		VALUES ('test', 'custom', ?, 50900, ?, ?, ?, 300)`, dir, path, policy, maxRetries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id64, _ := result.LastInsertId()
+	id := int(id64)
+	t.Cleanup(func() { stopInstances(id) })
+
+	p := &models.Project{ID: id, ProjectType: "custom", RootDir: dir, StartCommand: path, Port: 50900, Instances: 1}
+	if err := startProject(id, p); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// projectEvents 返回项目的事件，最早的在前
+func projectEvents(t *testing.T, id int) []models.ProjectEvent {
+	t.Helper()
+	rows, err := database.GetDB().Query("SELECT event, exit_code, signal, reason FROM project_events WHERE project_id=? ORDER BY id", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var events []models.ProjectEvent
+	for rows.Next() {
+		var e models.ProjectEvent
+		if err := rows.Scan(&e.Event, &e.ExitCode, &e.Signal, &e.Reason); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// waitEvents 等待项目记录 last 事件，返回全部事件
+func waitEvents(t *testing.T, id int, last string, timeout time.Duration) []models.ProjectEvent {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		events := projectEvents(t, id)
+		if len(events) > 0 && events[len(events)-1].Event == last {
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%v 内未记录 %s 事件: %+v", timeout, last, events)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func eventNames(events []models.ProjectEvent) string {
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	return strings.Join(names, ",")
+}
+
+func tracked(id int) bool {
+	processMutex.RLock()
+	defer processMutex.RUnlock()
+	return len(projectProcesses[id]) > 0
+}
+
+func TestHandleInstanceExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 脚本模拟项目进程")
+	}
+	testDB(t)
+
+	t.Run("never", func(t *testing.T) {
+		id := startTestProject(t, "exit 3", "never", 5)
+		events := waitEvents(t, id, "exit", 5*time.Second)
+		exit := events[len(events)-1]
+		if eventNames(events) != "start,exit" || exit.ExitCode == nil || *exit.ExitCode != 3 || !strings.Contains(exit.Reason, "never") {
+			t.Errorf("事件 = %+v", events)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if tracked(id) {
+			t.Error("退出的实例仍在列表中")
+		}
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		id := startTestProject(t, "kill -9 $$", "never", 5)
+		events := waitEvents(t, id, "exit", 5*time.Second)
+		if exit := events[len(events)-1]; exit.ExitCode != nil || exit.Signal != "SIGKILL" {
+			t.Errorf("退出事件 = %+v; 期望 SIGKILL", exit)
+		}
+	})
+
+	t.Run("give_up", func(t *testing.T) {
+		id := startTestProject(t, "exit 1", "on-failure", 2)
+		events := waitEvents(t, id, "give_up", 10*time.Second)
+		if got := eventNames(events); got != "start,exit,start,exit,start,exit,give_up" {
+			t.Errorf("事件 = %s", got)
+		}
+		if !strings.Contains(events[2].Reason, "第 1 次自动重启") || !strings.Contains(events[4].Reason, "第 2 次自动重启") {
+			t.Errorf("重启原因 = %q, %q", events[2].Reason, events[4].Reason)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if tracked(id) {
+			t.Error("放弃重启后实例仍在列表中")
+		}
+	})
+
+	t.Run("on_failure_clean_exit", func(t *testing.T) {
+		id := startTestProject(t, "exit 0", "on-failure", 5)
+		waitEvents(t, id, "exit", 5*time.Second)
+		time.Sleep(1500 * time.Millisecond)
+		if got := eventNames(projectEvents(t, id)); got != "start,exit" {
+			t.Errorf("正常退出后不应重启，事件 = %s", got)
+		}
+	})
+
+	t.Run("stop_cancels_restart", func(t *testing.T) {
+		id := startTestProject(t, "exit 0", "always", 5)
+		events := waitEvents(t, id, "exit", 5*time.Second)
+		if !strings.Contains(events[len(events)-1].Reason, "后重启") {
+			t.Fatalf("退出事件 = %+v", events[len(events)-1])
+		}
+		if !stopInstances(id) {
+			t.Error("等待重启的实例应在列表中")
+		}
+		time.Sleep(1500 * time.Millisecond)
+		if got := eventNames(projectEvents(t, id)); got != "start,exit" {
+			t.Errorf("停止后不应重启，事件 = %s", got)
+		}
+	})
+
+	t.Run("reloads_project", func(t *testing.T) {
+		// 自动重启时使用数据库中修改后的启动命令
+		id := startTestProject(t, "sleep 0.3; exit 1", "on-failure", 5)
+		dir := t.TempDir()
+		marker := filepath.Join(dir, "restarted")
+		path := filepath.Join(dir, "new.sh")
+		if err := os.WriteFile(path, []byte("#!/bin/sh\ntouch "+marker+"\nexec sleep 30\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.GetDB().Exec("UPDATE projects SET start_command=? WHERE id=?", path, id); err != nil {
+			t.Fatal(err)
+		}
+		waitEvents(t, id, "start", 5*time.Second)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, err := os.Stat(marker); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("重启后未运行新的启动命令，事件 = %s", eventNames(projectEvents(t, id)))
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if got := eventNames(projectEvents(t, id)); got != "start,exit,start" {
+			t.Errorf("事件 = %s", got)
+		}
+	})
+
+	t.Run("stopped_by_manager", func(t *testing.T) {
+		id := startTestProject(t, "exec sleep 30", "always", 5)
+		stopInstances(id)
+		events := waitEvents(t, id, "exit", 5*time.Second)
+		if exit := events[len(events)-1]; exit.Reason != "已停止" || exit.Signal != "SIGTERM" {
+			t.Errorf("退出事件 = %+v", exit)
+		}
+		time.Sleep(1500 * time.Millisecond)
+		if got := eventNames(projectEvents(t, id)); got != "start,exit" {
+			t.Errorf("事件 = %s", got)
+		}
+	})
+}
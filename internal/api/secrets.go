@@ -0,0 +1,224 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// envNamePattern 环境变量和密钥的名称：字母或下划线开头，只含字母、数字和下划线
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var (
+	// secretKey 加密密钥的缓存，按密钥文件路径区分
+	secretKey     []byte
+	secretKeyPath string
+	secretKeyMu   sync.Mutex
+)
+
+// SecretsHandler 列出已保存的密钥名称，不返回密钥值
+func SecretsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.GetDB().Query("SELECT name, created_at, updated_at FROM secrets ORDER BY name")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	secrets := []models.Secret{}
+	for rows.Next() {
+		var s models.Secret
+		if err := rows.Scan(&s.Name, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		secrets = append(secrets, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(secrets)
+}
+
+// SaveSecretHandler 新增或更新密钥，值加密后保存；项目环境变量中以 ${secret:NAME} 引用
+// 请求体: {"name": "DB_PASSWORD", "value": "..."}
+func SaveSecretHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if !envNamePattern.MatchString(req.Name) {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "密钥", Option: "name", Value: req.Name, Reason: "只能包含字母、数字和下划线，且不能以数字开头"})
+		return
+	}
+
+	encrypted, err := encryptSecret(req.Value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := database.GetDB().Exec(`INSERT INTO secrets (name, value) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`, req.Name, encrypted); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "密钥已保存，重启引用它的项目后生效",
+	})
+}
+
+// DeleteSecretHandler 删除密钥，仍被项目环境变量引用时拒绝
+// 参数: ?name=DB_PASSWORD
+func DeleteSecretHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	users, err := secretUsers(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(users) > 0 {
+		writeCaddyError(w, &caddyfile.OptionError{Owner: "密钥", Option: "name", Value: name, Reason: "仍被 " + strings.Join(users, "、") + " 的环境变量引用"})
+		return
+	}
+	if _, err := database.GetDB().Exec("DELETE FROM secrets WHERE name=?", name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// secretUsers 返回引用了密钥的项目
+func secretUsers(name string) ([]string, error) {
+	rows, err := database.GetDB().Query(`SELECT DISTINCT p.id, p.name FROM project_env e JOIN projects p ON p.id = e.project_id
+		WHERE instr(e.value, ?) > 0 ORDER BY p.id`, "${secret:"+name+"}")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		users = append(users, ownerLabel("project", id, name))
+	}
+	return users, rows.Err()
+}
+
+// loadSecret 读取并解密密钥，不存在时返回 sql.ErrNoRows
+func loadSecret(name string) (string, error) {
+	var encrypted string
+	if err := database.GetDB().QueryRow("SELECT value FROM secrets WHERE name=?", name).Scan(&encrypted); err != nil {
+		return "", err
+	}
+	return decryptSecret(encrypted)
+}
+
+// secretExists 检查密钥是否已保存
+func secretExists(name string) bool {
+	var n int
+	return database.GetDB().QueryRow("SELECT 1 FROM secrets WHERE name=?", name).Scan(&n) == nil
+}
+
+// encryptionKey 读取数据目录中的加密密钥，不存在时生成（仅当前用户可读）。
+// 数据库和密钥文件需要一起备份，否则已保存的密钥无法解密
+func encryptionKey() ([]byte, error) {
+	secretKeyMu.Lock()
+	defer secretKeyMu.Unlock()
+
+	path := filepath.Join(config.DataDir, "secret.key")
+	if secretKey != nil && secretKeyPath == path {
+		return secretKey, nil
+	}
+
+	key, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, key, 0600); err != nil {
+			return nil, fmt.Errorf("保存加密密钥失败: %w", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("加密密钥文件 %s 已损坏", path)
+	}
+	secretKey, secretKeyPath = key, path
+	return key, nil
+}
+
+// encryptSecret 使用 AES-256-GCM 加密，结果为 base64(nonce + 密文)
+func encryptSecret(plain string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+// decryptSecret 解密 encryptSecret 的结果
+func decryptSecret(encrypted string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("密钥数据已损坏")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("无法解密密钥，加密密钥文件可能已更换")
+	}
+	return string(plain), nil
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"caddy-manager/internal/config"
+)
+
+func TestSecretRoundTrip(t *testing.T) {
+	config.DataDir = t.TempDir()
+
+	for _, plain := range []string{"", "s3cret", "多字节 ✓ \n\x00"} {
+		encrypted, err := encryptSecret(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := decryptSecret(encrypted); err != nil || got != plain {
+			t.Errorf("decryptSecret = %q, %v; 期望 %q", got, err, plain)
+		}
+	}
+
+	a, _ := encryptSecret("same")
+	b, _ := encryptSecret("same")
+	if a == b {
+		t.Error("相同明文的加密结果应使用不同的 nonce")
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(config.DataDir, "secret.key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("密钥文件权限 = %v; 期望 0600", perm)
+		}
+	}
+}
+
+func TestSecretTamper(t *testing.T) {
+	config.DataDir = t.TempDir()
+	encrypted, err := encryptSecret("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(encrypted)
+
+	// 依次篡改 nonce、密文和认证标签
+	for _, i := range []int{0, 12, len(data) - 1} {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 0x01
+		if _, err := decryptSecret(base64.StdEncoding.EncodeToString(tampered)); err == nil {
+			t.Errorf("篡改第 %d 字节后仍能解密", i)
+		}
+	}
+	if _, err := decryptSecret(base64.StdEncoding.EncodeToString(data[:len(data)-1])); err == nil {
+		t.Error("截断后仍能解密")
+	}
+	for _, corrupt := range []string{"", "AAAA", "not base64!"} {
+		if _, err := decryptSecret(corrupt); err == nil {
+			t.Errorf("decryptSecret(%q) 期望返回错误", corrupt)
+		}
+	}
+
+	// 更换密钥文件后无法解密
+	config.DataDir = t.TempDir()
+	if _, err := decryptSecret(encrypted); err == nil {
+		t.Error("使用其他密钥文件仍能解密")
+	}
+}
+
+func TestEncryptionKeyCorrupt(t *testing.T) {
+	config.DataDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(config.DataDir, "secret.key"), []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encryptSecret("s3cret"); err == nil {
+		t.Error("密钥文件长度错误时期望返回错误")
+	}
+}
@@ -1,13 +1,18 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"os"
+	"os/user"
+	"strings"
 
 	"caddy-manager/internal/auth"
 	"caddy-manager/internal/caddy"
+	"caddy-manager/internal/caddyfile"
 	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
 )
 
 // GetSettingsHandler 获取设置
@@ -22,6 +27,18 @@ func GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		"security_path": securityPath,
 		"www_root":      wwwRoot,
 	}
+	for _, key := range acmeSettingKeys {
+		settings[key] = database.GetSetting(key)
+	}
+	for _, key := range proxySettingKeys {
+		settings[key] = database.GetSetting(key)
+	}
+	settings["maintenance_bypass"] = database.GetSetting("maintenance_bypass")
+	settings["php_user"] = database.GetSetting("php_user")
+	// EAB HMAC Key 不回传明文
+	if settings["acme_eab_hmac_key"] != "" {
+		settings["acme_eab_hmac_key"] = secretMask
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(settings)
@@ -57,9 +74,184 @@ func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		os.MkdirAll(wwwRoot, 0755)
 	}
 	
+	// 更新以 root 运行时 php-fpm 工作进程使用的账户，进程池重启后生效
+	if phpUser, ok := req["php_user"]; ok {
+		phpUser = strings.TrimSpace(phpUser)
+		if phpUser != "" {
+			if _, err := user.Lookup(phpUser); err != nil {
+				writeCaddyError(w, &caddyfile.OptionError{Owner: "全局设置", Option: "php_user", Value: phpUser, Reason: "系统中不存在该账户"})
+				return
+			}
+		}
+		if err := database.SetSetting("php_user", phpUser); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	
+	// 全局 ACME 设置、可信代理设置和维护模式额外放行的地址在同一事务中保存，
+	// 生成的配置通过校验后才提交
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	changed := false
+	for _, update := range []func(*sql.Tx, map[string]string) (bool, error){updateACMESettings, updateProxySettings, updateMaintenanceBypass} {
+		updated, err := update(tx, req)
+		if err != nil {
+			writeCaddyError(w, err)
+			return
+		}
+		changed = changed || updated
+	}
+	if changed {
+		if err := applyTx(tx); err != nil {
+			writeCaddyError(w, err)
+			return
+		}
+	}
+	
 	w.WriteHeader(http.StatusOK)
 }
 
+// acmeSettingKeys 全局 ACME 设置项
+var acmeSettingKeys = []string{"acme_email", "acme_ca", "acme_ca_url", "acme_eab_key_id", "acme_eab_hmac_key", "acme_ca_root"}
+
+// secretMask 返回给前端的密钥占位符，提交时原样返回表示不修改
+const secretMask = "********"
+
+// storedEABKey 提交的 EAB HMAC Key 为占位符时，返回数据库中已保存的值
+func storedEABKey(table string, id int, value string) string {
+	if value != secretMask {
+		return value
+	}
+	var stored string
+	database.GetDB().QueryRow("SELECT COALESCE(acme_eab_hmac_key, '') FROM "+table+" WHERE id = ?", id).Scan(&stored)
+	return stored
+}
+
+// maskSecret 列表中不回传密钥明文
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// updateACMESettings 校验请求中包含的 ACME 设置并写入事务，返回设置是否有变化
+func updateACMESettings(tx *sql.Tx, req map[string]string) (bool, error) {
+	current := make(map[string]string)
+	changed := false
+	for _, key := range acmeSettingKeys {
+		current[key] = database.GetSettingFrom(tx, key)
+		value, ok := req[key]
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value != current[key] && !(key == "acme_eab_hmac_key" && value == secretMask) {
+			current[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	settings := models.ACMESettings{
+		ACMECA:         current["acme_ca"],
+		ACMECAURL:      current["acme_ca_url"],
+		ACMEEABKeyID:   current["acme_eab_key_id"],
+		ACMEEABHMACKey: current["acme_eab_hmac_key"],
+		ACMECARoot:     current["acme_ca_root"],
+	}
+	if err := checkACME("全局 ACME 设置", current["acme_email"], settings); err != nil {
+		return false, err
+	}
+
+	for _, key := range acmeSettingKeys {
+		if err := database.SetSettingTo(tx, key, current[key]); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// proxySettingKeys 可信代理设置项：trusted_proxies 为 IP/CIDR 列表（可包含 cloudflare 预设），
+// client_ip_headers 为读取真实客户端地址的请求头
+var proxySettingKeys = []string{"trusted_proxies", "client_ip_headers"}
+
+// updateProxySettings 校验请求中包含的可信代理设置并写入事务，返回设置是否有变化
+func updateProxySettings(tx *sql.Tx, req map[string]string) (bool, error) {
+	current := make(map[string]string)
+	changed := false
+	for _, key := range proxySettingKeys {
+		current[key] = database.GetSettingFrom(tx, key)
+		value, ok := req[key]
+		if !ok {
+			continue
+		}
+		value = strings.Join(strings.Fields(strings.ReplaceAll(value, ",", " ")), "\n")
+		if value != current[key] {
+			current[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	cfg := &caddyfile.Config{Global: caddyfile.Global{
+		TrustedProxies:  trustedProxies(current["trusted_proxies"]),
+		ClientIPHeaders: strings.Fields(current["client_ip_headers"]),
+	}}
+	if err := cfg.Validate(); err != nil {
+		return false, err
+	}
+
+	for _, key := range proxySettingKeys {
+		if err := database.SetSettingTo(tx, key, current[key]); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// updateMaintenanceBypass 校验维护模式额外放行的地址并写入事务，返回设置是否有变化
+func updateMaintenanceBypass(tx *sql.Tx, req map[string]string) (bool, error) {
+	value, ok := req["maintenance_bypass"]
+	if !ok {
+		return false, nil
+	}
+	list := strings.Fields(strings.ReplaceAll(value, ",", " "))
+	if err := caddyfile.ValidateIPRanges("全局设置", "maintenance_bypass", list); err != nil {
+		return false, err
+	}
+	value = strings.Join(list, "\n")
+	if value == database.GetSettingFrom(tx, "maintenance_bypass") {
+		return false, nil
+	}
+	if err := database.SetSettingTo(tx, "maintenance_bypass", value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// trustedProxies 解析可信代理列表，cloudflare 展开为 Cloudflare 的回源地址段
+func trustedProxies(value string) []string {
+	var list []string
+	for _, item := range strings.Fields(value) {
+		if strings.EqualFold(item, "cloudflare") {
+			list = append(list, caddyfile.CloudflareIPRanges...)
+		} else {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 // ChangePasswordHandler 修改密码
 func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
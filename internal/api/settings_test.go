@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/database"
+)
+
+func updateSettings(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	UpdateSettingsHandler(rec, httptest.NewRequest(http.MethodPost, "/api/settings", strings.NewReader(body)))
+	return rec
+}
+
+func TestUpdateACMESettings(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+
+	if rec := updateSettings(t, `{"acme_email": " admin@example.com "}`); rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body)
+	}
+	if got := database.GetSetting("acme_email"); got != "admin@example.com" {
+		t.Errorf("acme_email = %q; 期望去掉首尾空白", got)
+	}
+	if data, _ := os.ReadFile(config.CaddyConfig); !strings.Contains(string(data), "admin@example.com") {
+		t.Fatalf("配置未包含 ACME 邮箱: %s", data)
+	}
+
+	// 再次提交相同的表单（含首尾空白）不重新生成配置
+	os.Remove(config.CaddyConfig)
+	if rec := updateSettings(t, `{"acme_email": " admin@example.com ", "acme_ca": "", "acme_eab_hmac_key": " ********"}`); rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(config.CaddyConfig); !os.IsNotExist(err) {
+		t.Errorf("设置未变化时不应重新生成配置: %v", err)
+	}
+}
+
+func TestUpdateSettingsRejected(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+
+	// caddy validate 拒绝生成的配置时所有设置都不保存，之后的修改仍可以正常应用
+	rec := updateSettings(t, `{"acme_email": "admin@example.com", "acme_ca": "custom", "acme_ca_url": "https://reject.example.com/directory", "maintenance_bypass": "10.0.0.1"}`)
+	if rec.Code == http.StatusOK || !strings.Contains(rec.Body.String(), "rejected") {
+		t.Fatalf("状态码 = %d: %s; 期望 caddy validate 失败", rec.Code, rec.Body)
+	}
+	for _, key := range []string{"acme_email", "acme_ca_url", "maintenance_bypass"} {
+		if got := database.GetSetting(key); got != "" {
+			t.Errorf("%s = %q; 期望未保存", key, got)
+		}
+	}
+	if rec := updateSettings(t, `{"acme_email": "admin@example.com"}`); rec.Code != http.StatusOK {
+		t.Errorf("状态码 = %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestUpdateProxySettings(t *testing.T) {
+	testDB(t)
+	stubCaddy(t)
+
+	if rec := updateSettings(t, `{"trusted_proxies": " 10.0.0.0/8, cloudflare ", "client_ip_headers": "X-Real-IP "}`); rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body)
+	}
+	if got := database.GetSetting("trusted_proxies"); got != "10.0.0.0/8\ncloudflare" {
+		t.Errorf("trusted_proxies = %q", got)
+	}
+	if got := database.GetSetting("client_ip_headers"); got != "X-Real-IP" {
+		t.Errorf("client_ip_headers = %q", got)
+	}
+
+	// 以不同的分隔符和空白再次提交相同的设置不重新生成配置
+	os.Remove(config.CaddyConfig)
+	if rec := updateSettings(t, `{"trusted_proxies": "10.0.0.0/8 cloudflare", "client_ip_headers": " X-Real-IP"}`); rec.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(config.CaddyConfig); !os.IsNotExist(err) {
+		t.Errorf("设置未变化时不应重新生成配置: %v", err)
+	}
+}
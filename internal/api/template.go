@@ -509,6 +509,8 @@ const IndexTemplate = `<!DOCTYPE html>
                     <option value="static">静态站点</option>
                     <option value="proxy">反向代理</option>
                     <option value="php">PHP站点</option>
+                    <option value="spa">单页应用</option>
+                    <option value="redirect">整站跳转</option>
                 </select>
             </div>
             <div class="form-group" id="target-group">
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/caddyfile"
+	"caddy-manager/internal/database"
+	"caddy-manager/internal/models"
+)
+
+// UpstreamTransportHandler 获取站点或项目的上游传输设置，未设置时返回空设置
+// 参数: ?owner_type=site|project&owner_id=1
+func UpstreamTransportHandler(w http.ResponseWriter, r *http.Request) {
+	ownerType := r.URL.Query().Get("owner_type")
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	t, err := loadUpstreamTransport(database.GetDB(), ownerType, ownerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		t = &models.UpstreamTransport{OwnerType: ownerType, OwnerID: ownerID}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// SaveUpstreamTransportHandler 保存站点或项目的上游传输设置，只适用于反向代理
+// 请求体: {"owner_type": "project", "owner_id": 1, "host": "10.0.0.5", "scheme": "https", "tls_server_name": "api.internal",
+// "tls_skip_verify": false, "dial_timeout": "5s", "read_timeout": "2m", "write_timeout": "", "flush_interval": "-1",
+// "stream_timeout": "", "stream_close_delay": "5m", "host_header": "upstream"}
+func SaveUpstreamTransportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var t models.UpstreamTransport
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, field := range []*string{&t.Host, &t.Scheme, &t.TLSServerName, &t.DialTimeout, &t.ReadTimeout, &t.WriteTimeout,
+		&t.FlushInterval, &t.StreamTimeout, &t.StreamCloseDelay, &t.HostHeader} {
+		*field = strings.TrimSpace(*field)
+	}
+	t.Scheme = strings.ToLower(t.Scheme)
+
+	targets, err := upstreamTargets(t.OwnerType, t.OwnerID, t.Host)
+	if err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	proxy := &caddyfile.ReverseProxy{}
+	if err := applyUpstreamTransport(proxy, targets, &t); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+	if err := checkOwnerRoutes("上游传输设置", t.OwnerType, t.OwnerID, func(route *caddyfile.Route) {
+		if route.Proxy == nil {
+			return
+		}
+		p := *route.Proxy
+		p.Upstreams, p.Transport, p.HostHeader = proxy.Upstreams, proxy.Transport, proxy.HostHeader
+		p.FlushInterval, p.StreamTimeout, p.StreamCloseDelay = proxy.FlushInterval, proxy.StreamTimeout, proxy.StreamCloseDelay
+		route.Proxy = &p
+	}); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO upstream_transports (owner_type, owner_id, host, scheme, tls_server_name, tls_skip_verify,
+		dial_timeout, read_timeout, write_timeout, flush_interval, stream_timeout, stream_close_delay, host_header)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(owner_type, owner_id) DO UPDATE SET host = excluded.host, scheme = excluded.scheme, tls_server_name = excluded.tls_server_name,
+		tls_skip_verify = excluded.tls_skip_verify, dial_timeout = excluded.dial_timeout, read_timeout = excluded.read_timeout,
+		write_timeout = excluded.write_timeout, flush_interval = excluded.flush_interval, stream_timeout = excluded.stream_timeout,
+		stream_close_delay = excluded.stream_close_delay, host_header = excluded.host_header, updated_at = CURRENT_TIMESTAMP`,
+		t.OwnerType, t.OwnerID, t.Host, t.Scheme, t.TLSServerName, t.TLSSkipVerify, t.DialTimeout, t.ReadTimeout, t.WriteTimeout,
+		t.FlushInterval, t.StreamTimeout, t.StreamCloseDelay, t.HostHeader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"message":   "上游传输设置已保存",
+		"upstreams": proxy.Upstreams,
+	})
+}
+
+// DeleteUpstreamTransportHandler 清除上游传输设置，恢复为本机明文 HTTP
+// 参数: ?owner_type=site&owner_id=1
+func DeleteUpstreamTransportHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := strconv.Atoi(r.URL.Query().Get("owner_id"))
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM upstream_transports WHERE owner_type=? AND owner_id=?",
+		r.URL.Query().Get("owner_type"), ownerID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := applyTx(tx); err != nil {
+		writeCaddyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// upstreamTargets 返回站点或项目的上游地址：站点为反向代理目标，项目为各实例的端口，
+// host 为项目的上游主机，为空表示本机
+func upstreamTargets(ownerType string, ownerID int, host string) ([]string, error) {
+	db := database.GetDB()
+	switch ownerType {
+	case "site":
+		if host != "" {
+			return nil, &caddyfile.OptionError{Owner: fmt.Sprintf("站点 #%d", ownerID), Option: "host", Value: host, Reason: "站点的上游主机在反向代理目标中设置"}
+		}
+		var siteType, target string
+		if err := db.QueryRow("SELECT type, target FROM sites WHERE id=?", ownerID).Scan(&siteType, &target); err != nil {
+			return nil, fmt.Errorf("站点 #%d 不存在", ownerID)
+		}
+		if siteType != "proxy" {
+			return nil, &caddyfile.OptionError{Owner: fmt.Sprintf("站点 #%d", ownerID), Option: "transport", Value: siteType, Reason: "上游传输设置只适用于反向代理站点"}
+		}
+		return []string{target}, nil
+	case "project":
+		var p models.Project
+		if err := db.QueryRow("SELECT id, port, COALESCE(instances, 1), COALESCE(use_ipv4, 1) FROM projects WHERE id=?", ownerID).
+			Scan(&p.ID, &p.Port, &p.Instances, &p.UseIPv4); err != nil {
+			return nil, fmt.Errorf("项目 #%d 不存在", ownerID)
+		}
+		return projectUpstreams(&p, host)
+	}
+	return nil, &caddyfile.OptionError{Owner: "上游传输设置", Option: "owner_type", Value: ownerType, Reason: "可选值: site, project"}
+}
+
+// projectUpstreams 项目每个实例一个上游；本机根据 use_ipv4 设置决定使用 IPv4 或 localhost（可能解析为 IPv6）
+func projectUpstreams(p *models.Project, host string) ([]string, error) {
+	if host == "" {
+		host = "localhost"
+		if p.UseIPv4 {
+			host = "127.0.0.1"
+		}
+	} else if strings.ContainsAny(host, ":/ \t") && net.ParseIP(host) == nil {
+		return nil, &caddyfile.OptionError{Owner: fmt.Sprintf("项目 #%d", p.ID), Option: "host", Value: host, Reason: "应为主机名或 IP，不含协议和端口"}
+	}
+
+	var upstreams []string
+	for _, port := range instancePorts(p) {
+		upstreams = append(upstreams, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+	return upstreams, nil
+}
+
+// applyUpstreamTransport 设置反向代理的上游地址和传输选项。没有传输设置时上游地址原样使用；
+// 否则拆出地址中的协议（如站点目标 https://api.example.com），与设置的协议合并为 transport 块
+func applyUpstreamTransport(proxy *caddyfile.ReverseProxy, targets []string, t *models.UpstreamTransport) error {
+	if t == nil {
+		proxy.Upstreams = targets
+		return nil
+	}
+	owner := fmt.Sprintf("站点 #%d", t.OwnerID)
+	if t.OwnerType == "project" {
+		owner = fmt.Sprintf("项目 #%d", t.OwnerID)
+	}
+	valid := t.Scheme == ""
+	for _, s := range caddyfile.UpstreamSchemes {
+		valid = valid || s == t.Scheme
+	}
+	if !valid {
+		return &caddyfile.OptionError{Owner: owner, Option: "scheme", Value: t.Scheme, Reason: "可选值: " + strings.Join(caddyfile.UpstreamSchemes, ", ")}
+	}
+
+	scheme := t.Scheme
+	proxy.Upstreams = nil
+	for _, target := range targets {
+		s, address, err := caddyfile.SplitUpstream(target)
+		if err != nil {
+			return err
+		}
+		if s != "http" && scheme != "" && scheme != s {
+			return &caddyfile.OptionError{Owner: owner, Option: "scheme", Value: t.Scheme, Reason: fmt.Sprintf("与上游地址 %s 的协议不一致", target)}
+		}
+		if s != "http" {
+			scheme = s
+		}
+		proxy.Upstreams = append(proxy.Upstreams, address)
+	}
+
+	proxy.Transport = &caddyfile.Transport{
+		TLS:                   scheme == "https",
+		TLSServerName:         t.TLSServerName,
+		TLSInsecureSkipVerify: t.TLSSkipVerify,
+		H2C:                   scheme == "h2c",
+		DialTimeout:           t.DialTimeout,
+		ReadTimeout:           t.ReadTimeout,
+		WriteTimeout:          t.WriteTimeout,
+	}
+	proxy.FlushInterval, proxy.StreamTimeout, proxy.StreamCloseDelay = t.FlushInterval, t.StreamTimeout, t.StreamCloseDelay
+	proxy.HostHeader = t.HostHeader
+	if t.HostHeader == "upstream" {
+		proxy.HostHeader = caddyfile.UpstreamHostPlaceholder
+	}
+	return nil
+}
+
+// loadUpstreamTransport 读取上游传输设置，未设置时返回 nil
+func loadUpstreamTransport(q database.Querier, ownerType string, ownerID int) (*models.UpstreamTransport, error) {
+	var t models.UpstreamTransport
+	err := q.QueryRow(`SELECT owner_type, owner_id, host, scheme, tls_server_name, COALESCE(tls_skip_verify, 0),
+		dial_timeout, read_timeout, write_timeout, flush_interval, stream_timeout, stream_close_delay, host_header
+		FROM upstream_transports WHERE owner_type = ? AND owner_id = ?`, ownerType, ownerID).
+		Scan(&t.OwnerType, &t.OwnerID, &t.Host, &t.Scheme, &t.TLSServerName, &t.TLSSkipVerify,
+			&t.DialTimeout, &t.ReadTimeout, &t.WriteTimeout, &t.FlushInterval, &t.StreamTimeout, &t.StreamCloseDelay, &t.HostHeader)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,88 @@
+package caddy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/system"
+)
+
+// ErrAdminUnreachable 无法连接 Caddy 管理 API（Caddy 未运行或管理端口被关闭）
+var ErrAdminUnreachable = errors.New("Caddy 管理 API 不可达")
+
+// AdminError Caddy 管理 API 返回的结构化错误
+type AdminError struct {
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"error"`
+}
+
+func (e *AdminError) Error() string {
+	return fmt.Sprintf("Caddy 管理 API 返回错误 (%d): %s", e.StatusCode, e.Message)
+}
+
+// AdaptError caddy adapt 转换 Caddyfile 失败
+type AdaptError struct {
+	Output string `json:"output"`
+}
+
+func (e *AdaptError) Error() string {
+	return "Caddyfile 转换失败: " + e.Output
+}
+
+var adminClient = &http.Client{Timeout: 30 * time.Second}
+
+// Adapt 使用 caddy adapt 将 Caddyfile 转换为 JSON 配置
+func Adapt(path string) ([]byte, error) {
+	cmd := exec.Command(config.CaddyBin, "adapt", "--config", path, "--adapter", "caddyfile")
+	cmd.Dir = config.CaddyDir
+	cmd.Env = commandEnv(currentEnv())
+	system.HideWindow(cmd)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, &AdaptError{Output: msg}
+	}
+
+	return output, nil
+}
+
+// Load 通过管理 API 的 /load 接口加载 JSON 配置，Caddy 会平滑切换，不会中断现有连接
+func Load(cfg []byte) error {
+	req, err := http.NewRequest(http.MethodPost, "http://"+config.CaddyAdminAddr+"/load", bytes.NewReader(cfg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := adminClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAdminUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	adminErr := &AdminError{StatusCode: resp.StatusCode}
+	if json.Unmarshal(body, adminErr) != nil || adminErr.Message == "" {
+		adminErr.Message = strings.TrimSpace(string(body))
+	}
+	return adminErr
+}
@@ -0,0 +1,110 @@
+package caddy
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"caddy-manager/internal/config"
+)
+
+// stubCaddy 用 shell 脚本代替 caddy 可执行文件，配置目录指向临时目录
+func stubCaddy(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 脚本模拟 caddy")
+	}
+	config.CaddyDir = t.TempDir()
+	config.CaddyConfig = filepath.Join(config.CaddyDir, "Caddyfile")
+	config.CaddyBin = filepath.Join(config.CaddyDir, "caddy")
+	if err := os.WriteFile(config.CaddyBin, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// adminServer 启动模拟的管理 API 并让 Load 使用它
+func adminServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	config.CaddyAdminAddr = strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestLoad(t *testing.T) {
+	var got struct{ method, path, contentType, body string }
+	adminServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got.method, got.path, got.contentType, got.body = r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(body)
+		switch {
+		case strings.Contains(got.body, "bad"):
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"loading config: unknown directive"}`)
+		case strings.Contains(got.body, "crash"):
+			http.Error(w, "internal failure", http.StatusInternalServerError)
+		}
+	})
+
+	if err := Load([]byte(`{"apps":{}}`)); err != nil {
+		t.Fatalf("Load = %v", err)
+	}
+	if got.method != http.MethodPost || got.path != "/load" || got.contentType != "application/json" || got.body != `{"apps":{}}` {
+		t.Errorf("请求 = %+v", got)
+	}
+
+	cases := map[string]AdminError{
+		`{"bad":true}`:   {StatusCode: 400, Message: "loading config: unknown directive"},
+		`{"crash":true}`: {StatusCode: 500, Message: "internal failure"},
+	}
+	for body, want := range cases {
+		var adminErr *AdminError
+		if err := Load([]byte(body)); !errors.As(err, &adminErr) || *adminErr != want {
+			t.Errorf("Load(%s) = %v; 期望 %+v", body, err, want)
+		}
+	}
+}
+
+func TestLoadUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.CaddyAdminAddr = ln.Addr().String()
+	ln.Close()
+
+	err = Load([]byte(`{}`))
+	var adminErr *AdminError
+	if !errors.Is(err, ErrAdminUnreachable) || errors.As(err, &adminErr) {
+		t.Errorf("Load = %v; 期望 ErrAdminUnreachable", err)
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	stubCaddy(t, `case "$3" in
+*fail*) echo "Error: adapting config: unrecognized directive: foo" >&2; exit 1 ;;
+*silent*) exit 2 ;;
+esac
+echo '{"apps":{}}'`)
+
+	out, err := Adapt("ok")
+	if err != nil || strings.TrimSpace(string(out)) != `{"apps":{}}` {
+		t.Errorf("Adapt = %q, %v", out, err)
+	}
+
+	cases := map[string]string{
+		"fail":   "Error: adapting config: unrecognized directive: foo",
+		"silent": "exit status 2",
+	}
+	for path, want := range cases {
+		var adaptErr *AdaptError
+		if _, err := Adapt(path); !errors.As(err, &adaptErr) || adaptErr.Output != want {
+			t.Errorf("Adapt(%s) = %v; 期望输出 %q", path, err, want)
+		}
+	}
+}
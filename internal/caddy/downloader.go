@@ -1,7 +1,9 @@
 package caddy
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"log"
@@ -9,20 +11,20 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
-	"syscall"
 	"time"
 
 	"caddy-manager/internal/config"
+	"caddy-manager/internal/system"
 )
 
 const (
-	// 使用官方最新版本下载地址
-	caddyDownloadURL = "https://github.com/caddyserver/caddy/releases/download/v2.10.2/caddy_2.10.2_windows_amd64.zip"
+	// 使用官方最新版本
+	caddyVersion     = "2.10.2"
+	caddyReleaseBase = "https://github.com/caddyserver/caddy/releases/download/v" + caddyVersion + "/"
 )
 
-var caddyCmd *exec.Cmd
-
 // CheckAndDownload 检查并下载 Caddy
 func CheckAndDownload() error {
 	// 检查 Caddy 是否已存在
@@ -31,19 +33,29 @@ func CheckAndDownload() error {
 		return nil
 	}
 
+	asset, err := releaseAsset(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
 	fmt.Println("🔍 未检测到 Caddy，开始自动下载...")
 	
 	// 创建临时目录
-	tempFile := filepath.Join(os.TempDir(), "caddy.zip")
+	tempFile := filepath.Join(os.TempDir(), asset)
 	defer os.Remove(tempFile)
 
 	// 下载 Caddy
-	if err := downloadFile(tempFile, caddyDownloadURL); err != nil {
+	if err := downloadFile(tempFile, caddyReleaseBase+asset); err != nil {
 		return fmt.Errorf("下载失败: %v", err)
 	}
 
 	// 解压
-	if err := unzip(tempFile, config.CaddyDir); err != nil {
+	if strings.HasSuffix(asset, ".zip") {
+		err = unzip(tempFile, config.CaddyDir)
+	} else {
+		err = untarGz(tempFile, config.CaddyDir)
+	}
+	if err != nil {
 		return fmt.Errorf("解压失败: %v", err)
 	}
 
@@ -73,7 +85,7 @@ func AutoStart() {
 	respond "Caddy 正在运行" 200
 }
 `
-		if err := os.WriteFile(config.CaddyConfig, []byte(defaultConfig), 0644); err != nil {
+		if err := writeConfigFile(config.CaddyConfig, []byte(defaultConfig)); err != nil {
 			log.Printf("创建配置文件失败: %v", err)
 			return
 		}
@@ -85,88 +97,6 @@ func AutoStart() {
 	}
 }
 
-// Start 启动 Caddy
-func Start() error {
-	// 先停止已有进程
-	Stop()
-	
-	// 检查 Caddy 可执行文件是否存在
-	if _, err := os.Stat(config.CaddyBin); err != nil {
-		return fmt.Errorf("Caddy 未安装: %v", err)
-	}
-	
-	// 创建日志文件
-	logFile, err := os.OpenFile(config.CaddyLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return err
-	}
-	
-	caddyCmd = exec.Command(config.CaddyBin, "run", "--config", config.CaddyConfig, "--adapter", "caddyfile")
-	caddyCmd.Dir = config.CaddyDir
-	caddyCmd.Stdout = logFile
-	caddyCmd.Stderr = logFile
-	
-	if err := caddyCmd.Start(); err != nil {
-		logFile.Close()
-		return err
-	}
-	
-	log.Println("✅ Caddy 已启动")
-	return nil
-}
-
-// Stop 停止 Caddy
-func Stop() {
-	exec.Command("taskkill", "/F", "/IM", "caddy.exe").Run()
-	if caddyCmd != nil && caddyCmd.Process != nil {
-		caddyCmd.Process.Kill()
-	}
-}
-
-// Restart 重启 Caddy
-func Restart() error {
-	log.Println("🔄 重启 Caddy...")
-	Stop()
-	time.Sleep(1 * time.Second)
-	return Start()
-}
-
-// Reload 重新加载配置（优雅重启）
-func Reload() error {
-	log.Println("🔄 重新加载 Caddy 配置...")
-	
-	// 检查 Caddy 是否在运行
-	if !IsRunning() {
-		return fmt.Errorf("Caddy 未运行")
-	}
-	
-	// 使用 caddy reload 命令
-	cmd := exec.Command(config.CaddyBin, "reload", "--config", config.CaddyConfig, "--adapter", "caddyfile")
-	cmd.Dir = config.CaddyDir
-	
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("重新加载失败: %v\n输出: %s", err, string(output))
-	}
-	
-	log.Println("✅ Caddy 配置已重新加载")
-	return nil
-}
-
-// IsRunning 检查 Caddy 是否运行
-func IsRunning() bool {
-	cmd := exec.Command("tasklist", "/FI", "IMAGENAME eq caddy.exe", "/FO", "CSV", "/NH")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	output, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-	
-	// 检查输出中是否包含 caddy.exe
-	outputStr := string(output)
-	return strings.Contains(outputStr, "caddy.exe")
-}
-
 // GetLogs 获取 Caddy 日志
 func GetLogs(lines int) (string, error) {
 	data, err := os.ReadFile(config.CaddyLogFile)
@@ -245,8 +175,8 @@ func unzip(src, dest string) error {
 	defer r.Close()
 
 	for _, f := range r.File {
-		// 只解压 caddy.exe
-		if !strings.Contains(f.Name, "caddy.exe") {
+		// 只解压 Caddy 可执行文件
+		if filepath.Base(f.Name) != config.CaddyBinName() {
 			continue
 		}
 
@@ -284,6 +214,83 @@ func unzip(src, dest string) error {
 	return nil
 }
 
+func untarGz(src, dest string) error {
+	fmt.Println("📦 正在解压...")
+
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// 只解压 Caddy 可执行文件
+		if header.Typeflag != tar.TypeReg || filepath.Base(header.Name) != config.CaddyBinName() {
+			continue
+		}
+
+		if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+			return err
+		}
+
+		fpath := filepath.Join(dest, config.CaddyBinName())
+		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(outFile, tr)
+		outFile.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseAsset 根据操作系统和架构选择官方发布包文件名
+// 例如 caddy_2.10.2_windows_amd64.zip、caddy_2.10.2_linux_arm64.tar.gz
+func releaseAsset(goos, goarch string) (string, error) {
+	osName := goos
+	ext := "tar.gz"
+	switch goos {
+	case "windows":
+		ext = "zip"
+	case "darwin":
+		osName = "mac"
+	case "linux", "freebsd":
+	default:
+		return "", fmt.Errorf("不支持自动下载的操作系统: %s", goos)
+	}
+
+	archName := goarch
+	switch goarch {
+	case "amd64", "arm64", "s390x", "ppc64le", "riscv64":
+	case "arm":
+		archName = "armv7"
+	default:
+		return "", fmt.Errorf("不支持自动下载的架构: %s/%s", goos, goarch)
+	}
+
+	return fmt.Sprintf("caddy_%s_%s_%s.%s", caddyVersion, osName, archName, ext), nil
+}
+
 // GetVersion 获取 Caddy 版本
 func GetVersion() string {
 	// 检查 Caddy 是否存在
@@ -292,7 +299,7 @@ func GetVersion() string {
 	}
 	
 	cmd := exec.Command(config.CaddyBin, "version")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	system.HideWindow(cmd)
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -0,0 +1,84 @@
+package caddy
+
+import (
+	"os"
+	"sort"
+	"sync"
+)
+
+var (
+	envMu sync.Mutex
+	// configEnv 当前配置需要的环境变量（如 DNS 服务商凭据），配置中只写 {env.NAME} 占位符
+	configEnv map[string]string
+	// startedEnv 正在运行的 Caddy 启动时传入的环境变量，{env.NAME} 在运行时从进程环境中读取，
+	// 与 configEnv 不同时需要重启 Caddy 才能生效
+	startedEnv map[string]string
+)
+
+// SetEnv 设置配置需要的环境变量，在之后启动、校验和加载配置的 Caddy 进程中生效
+func SetEnv(env map[string]string) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	configEnv = copyEnv(env)
+}
+
+// currentEnv 返回当前配置需要的环境变量
+func currentEnv() map[string]string {
+	envMu.Lock()
+	defer envMu.Unlock()
+	return copyEnv(configEnv)
+}
+
+// envChanged 检查正在运行的 Caddy 是否缺少当前配置需要的环境变量
+func envChanged() bool {
+	envMu.Lock()
+	defer envMu.Unlock()
+	if len(configEnv) != len(startedEnv) {
+		return true
+	}
+	for name, value := range configEnv {
+		if v, ok := startedEnv[name]; !ok || v != value {
+			return true
+		}
+	}
+	return false
+}
+
+// markStarted 记录 Caddy 启动时使用的环境变量
+func markStarted(env map[string]string) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	startedEnv = copyEnv(env)
+}
+
+// commandEnv 在管理器自身的环境变量之后追加 env，同名变量以 env 为准
+func commandEnv(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := os.Environ()
+	for _, name := range names {
+		result = append(result, name+"="+env[name])
+	}
+	return result
+}
+
+func copyEnv(env map[string]string) map[string]string {
+	result := make(map[string]string, len(env))
+	for name, value := range env {
+		result[name] = value
+	}
+	return result
+}
+
+// writeConfigFile 写入配置文件，配置中可能包含 EAB 密钥等敏感信息，只允许当前用户读写；
+// 已存在的文件同样收紧权限
+func writeConfigFile(path string, content []byte) error {
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
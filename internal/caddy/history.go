@@ -0,0 +1,275 @@
+package caddy
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/system"
+)
+
+// historyLimit 保留的可用配置历史数量
+const historyLimit = 20
+
+var applyMu sync.Mutex
+
+// ValidationError 候选配置未通过 caddy validate 校验
+type ValidationError struct {
+	Output string `json:"output"`
+}
+
+func (e *ValidationError) Error() string {
+	return "Caddy 配置校验失败: " + e.Output
+}
+
+// ReloadError 新配置已通过校验但加载失败，RolledBack 表示是否已恢复到上一次可用的配置
+type ReloadError struct {
+	Err        error `json:"-"`
+	RolledBack bool  `json:"rolled_back"`
+}
+
+func (e *ReloadError) Error() string {
+	if e.RolledBack {
+		return fmt.Sprintf("%v（已自动恢复上一次可用的配置）", e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *ReloadError) Unwrap() error {
+	return e.Err
+}
+
+// HistoryEntry 一份历史可用配置
+type HistoryEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Validate 使用 caddy validate 校验 Caddyfile，传入当前配置需要的环境变量
+func Validate(path string) error {
+	return validate(path, currentEnv())
+}
+
+// validate 使用 caddy validate 校验 Caddyfile，env 为配置需要的环境变量
+func validate(path string, env map[string]string) error {
+	cmd := exec.Command(config.CaddyBin, "validate", "--config", path, "--adapter", "caddyfile")
+	cmd.Dir = config.CaddyDir
+	cmd.Env = commandEnv(env)
+	system.HideWindow(cmd)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return &ValidationError{Output: strings.TrimSpace(string(output))}
+	}
+	return nil
+}
+
+// ValidateConfig 把候选配置写入临时文件并校验，不改动正式配置；Caddy 尚未安装时不校验。
+// env 为配置中 {env.NAME} 占位符需要的环境变量
+func ValidateConfig(content []byte, env map[string]string) error {
+	if _, err := os.Stat(config.CaddyBin); err != nil {
+		return nil
+	}
+
+	// 与正式配置放在同一目录，import 等相对路径按相同方式解析
+	f, err := os.CreateTemp(filepath.Dir(config.CaddyConfig), "Caddyfile-*.candidate")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return validate(f.Name(), env)
+}
+
+// ApplyConfig 校验并应用新的 Caddyfile：
+// 候选配置先写入临时文件并校验，未通过时保持现有配置不变；
+// 通过后替换正式配置并重新加载，加载失败时自动恢复最近一次可用的配置；
+// 加载成功的配置会存入历史记录。
+// env 为配置中 {env.NAME} 占位符需要的环境变量，变化时重启 Caddy 而不是通过管理 API 加载。
+func ApplyConfig(content []byte, env map[string]string) error {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	// Caddy 尚未安装时无法校验，只写入配置文件
+	if _, err := os.Stat(config.CaddyBin); err != nil {
+		log.Printf("⚠️  Caddy 未安装，跳过配置校验")
+		SetEnv(env)
+		return writeConfigFile(config.CaddyConfig, content)
+	}
+
+	if err := ValidateConfig(content, env); err != nil {
+		return err
+	}
+	return apply(content, env)
+}
+
+// ApplyValidated 应用已通过 ValidateConfig 校验的配置，加载失败时同样恢复最近一次可用的配置
+func ApplyValidated(content []byte, env map[string]string) error {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	if _, err := os.Stat(config.CaddyBin); err != nil {
+		SetEnv(env)
+		return writeConfigFile(config.CaddyConfig, content)
+	}
+	return apply(content, env)
+}
+
+// apply 替换正式配置并重新加载，调用方需持有 applyMu
+func apply(content []byte, env map[string]string) error {
+	previous, _ := os.ReadFile(config.CaddyConfig)
+	previousEnv := currentEnv()
+	if err := writeConfigFile(config.CaddyConfig, content); err != nil {
+		return err
+	}
+	SetEnv(env)
+
+	if err := Reload(); err != nil {
+		SetEnv(previousEnv)
+		return &ReloadError{Err: err, RolledBack: rollback(previous)}
+	}
+
+	if err := saveHistory(content); err != nil {
+		log.Printf("保存配置历史失败: %v", err)
+	}
+	return nil
+}
+
+// WriteConfig 校验并写入新的 Caddyfile，但不重新加载，配置在 Caddy 下次启动或重新加载时生效。
+// 用于命令行导入等不负责运行 Caddy 的场合，避免启动一个随命令退出而无人管理的 Caddy 进程
+func WriteConfig(content []byte, env map[string]string) error {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	if err := ValidateConfig(content, env); err != nil {
+		return err
+	}
+	if err := writeConfigFile(config.CaddyConfig, content); err != nil {
+		return err
+	}
+	SetEnv(env)
+	return nil
+}
+
+// rollback 恢复最近一次可用的配置；没有历史记录时恢复替换前的配置
+func rollback(previous []byte) bool {
+	good, err := lastGoodConfig()
+	if err != nil || good == nil {
+		good = previous
+	}
+	if good == nil {
+		return false
+	}
+
+	log.Println("⏪ 新配置加载失败，恢复上一次可用的配置...")
+	if err := writeConfigFile(config.CaddyConfig, good); err != nil {
+		log.Printf("恢复配置失败: %v", err)
+		return false
+	}
+	if err := Reload(); err != nil {
+		log.Printf("恢复的配置加载失败: %v", err)
+		return false
+	}
+	return true
+}
+
+// ListHistory 列出历史可用配置，最新的在前
+func ListHistory() ([]HistoryEntry, error) {
+	entries, err := os.ReadDir(historyDir())
+	if os.IsNotExist(err) {
+		return []HistoryEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	history := []HistoryEntry{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || !strings.HasPrefix(entry.Name(), "Caddyfile-") {
+			continue
+		}
+		history = append(history, HistoryEntry{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			SavedAt: info.ModTime(),
+		})
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Name > history[j].Name
+	})
+	return history, nil
+}
+
+// RestoreHistory 将指定的历史配置重新应用为当前配置
+func RestoreHistory(name string) error {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, "Caddyfile-") {
+		return fmt.Errorf("无效的历史配置: %s", name)
+	}
+
+	content, err := os.ReadFile(filepath.Join(historyDir(), name))
+	if err != nil {
+		return err
+	}
+	return ApplyConfig(content, currentEnv())
+}
+
+func historyDir() string {
+	return filepath.Join(config.CaddyDir, "history")
+}
+
+func lastGoodConfig() ([]byte, error) {
+	history, err := ListHistory()
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(historyDir(), history[0].Name))
+}
+
+// saveHistory 保存一份可用配置，内容与最近一份相同时不重复保存，超出数量上限时删除最旧的记录
+func saveHistory(content []byte) error {
+	if last, err := lastGoodConfig(); err == nil && bytes.Equal(last, content) {
+		return nil
+	}
+
+	dir := historyDir()
+	// 历史配置与正式配置一样只允许当前用户访问，旧版本创建的目录同样收紧权限
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
+	}
+
+	name := "Caddyfile-" + time.Now().Format("20060102-150405.000")
+	if err := writeConfigFile(filepath.Join(dir, name), content); err != nil {
+		return err
+	}
+
+	history, err := ListHistory()
+	if err != nil {
+		return err
+	}
+	if len(history) > historyLimit {
+		for _, old := range history[historyLimit:] {
+			os.Remove(filepath.Join(dir, old.Name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"caddy-manager/internal/config"
+)
+
+// historyStub 模拟的 caddy：内容含 INVALID 时校验失败，含 BROKEN 时管理 API 拒绝加载
+func historyStub(t *testing.T) {
+	t.Helper()
+	stubCaddy(t, `case "$1" in
+validate) if grep -q INVALID "$3"; then echo "Error: unrecognized directive: INVALID"; exit 1; fi ;;
+adapt) if grep -q BROKEN "$3"; then echo '{"broken":true}'; else echo '{}'; fi ;;
+esac`)
+	adminServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "broken") {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"loading new config: listen tcp :443: bind: address already in use"}`)
+		}
+	})
+}
+
+func readConfig(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(config.CaddyConfig)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func historyNames(t *testing.T) []string {
+	t.Helper()
+	history, err := ListHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, h := range history {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+func TestApplyConfig(t *testing.T) {
+	historyStub(t)
+
+	if err := ApplyConfig([]byte("good1"), nil); err != nil {
+		t.Fatalf("ApplyConfig = %v", err)
+	}
+	if got := readConfig(t); got != "good1" || len(historyNames(t)) != 1 {
+		t.Fatalf("配置 = %q, 历史 = %v", got, historyNames(t))
+	}
+
+	var validationErr *ValidationError
+	if err := ApplyConfig([]byte("INVALID"), nil); !errors.As(err, &validationErr) || !strings.Contains(validationErr.Output, "unrecognized directive") {
+		t.Errorf("校验失败: err = %v", err)
+	}
+	if got := readConfig(t); got != "good1" {
+		t.Errorf("校验失败后配置 = %q; 期望保持 good1", got)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(config.CaddyDir, "*.candidate")); len(matches) != 0 {
+		t.Errorf("未删除候选配置临时文件: %v", matches)
+	}
+
+	var reloadErr *ReloadError
+	var adminErr *AdminError
+	err := ApplyConfig([]byte("BROKEN"), nil)
+	if !errors.As(err, &reloadErr) || !reloadErr.RolledBack || !errors.As(err, &adminErr) || adminErr.StatusCode != 400 {
+		t.Errorf("加载失败: err = %v", err)
+	}
+	if got := readConfig(t); got != "good1" {
+		t.Errorf("加载失败后配置 = %q; 期望恢复为 good1", got)
+	}
+	if names := historyNames(t); len(names) != 1 {
+		t.Errorf("加载失败的配置不应存入历史: %v", names)
+	}
+}
+
+func TestApplyConfigRollbackWithoutHistory(t *testing.T) {
+	historyStub(t)
+
+	// 没有历史记录时恢复替换前的配置
+	if err := os.WriteFile(config.CaddyConfig, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var reloadErr *ReloadError
+	if err := ApplyConfig([]byte("BROKEN"), nil); !errors.As(err, &reloadErr) || !reloadErr.RolledBack {
+		t.Errorf("err = %v; 期望已恢复", err)
+	}
+	if got := readConfig(t); got != "previous" {
+		t.Errorf("配置 = %q; 期望 previous", got)
+	}
+
+	// 也没有替换前的配置时无法恢复
+	os.Remove(config.CaddyConfig)
+	if err := ApplyConfig([]byte("BROKEN"), nil); !errors.As(err, &reloadErr) || reloadErr.RolledBack {
+		t.Errorf("err = %v; 期望未恢复", err)
+	}
+}
+
+func TestApplyConfigWithoutCaddy(t *testing.T) {
+	historyStub(t)
+	os.Remove(config.CaddyBin)
+
+	if err := ApplyConfig([]byte("INVALID"), nil); err != nil {
+		t.Fatalf("ApplyConfig = %v", err)
+	}
+	if got := readConfig(t); got != "INVALID" || len(historyNames(t)) != 0 {
+		t.Errorf("配置 = %q, 历史 = %v", got, historyNames(t))
+	}
+}
+
+func TestApplyConfigEnv(t *testing.T) {
+	stubCaddy(t, `case "$1" in
+validate) if grep -q dns "$3" && [ "$CADDY_DNS_1_API_TOKEN" != "cf-token" ]; then echo "Error: API token is required"; exit 1; fi ;;
+adapt) echo '{}' ;;
+esac`)
+	adminServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	config.CaddyPIDFile = filepath.Join(config.CaddyDir, "caddy.pid")
+	config.CaddyLogFile = filepath.Join(config.CaddyDir, "caddy.log")
+	t.Cleanup(func() {
+		Stop()
+		SetEnv(nil)
+		markStarted(nil)
+	})
+
+	content := []byte("dns cloudflare {env.CADDY_DNS_1_API_TOKEN}")
+	var validationErr *ValidationError
+	if err := ApplyConfig(content, nil); !errors.As(err, &validationErr) {
+		t.Fatalf("缺少环境变量时期望校验失败，实际 %v", err)
+	}
+
+	// 环境变量变化后重启 Caddy，启动时传入新的环境变量
+	env := map[string]string{"CADDY_DNS_1_API_TOKEN": "cf-token"}
+	if err := ApplyConfig(content, env); err != nil {
+		t.Fatalf("ApplyConfig = %v", err)
+	}
+	if envChanged() {
+		t.Error("Caddy 未使用新的环境变量重启")
+	}
+
+	// 配置和历史记录只允许当前用户访问
+	if info, err := os.Stat(config.CaddyConfig); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Caddyfile 权限 = %v, %v; 期望 0600", info.Mode().Perm(), err)
+	}
+	if info, err := os.Stat(historyDir()); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("历史目录权限 = %v, %v; 期望 0700", info.Mode().Perm(), err)
+	}
+}
+
+func TestSaveHistory(t *testing.T) {
+	historyStub(t)
+
+	for i := 0; i < historyLimit+5; i++ {
+		if err := saveHistory([]byte(fmt.Sprintf("config %d", i))); err != nil {
+			t.Fatal(err)
+		}
+		// 历史文件名精确到毫秒
+		time.Sleep(2 * time.Millisecond)
+	}
+	// 与最近一份相同的内容不重复保存
+	if err := saveHistory([]byte(fmt.Sprintf("config %d", historyLimit+4))); err != nil {
+		t.Fatal(err)
+	}
+
+	names := historyNames(t)
+	if len(names) != historyLimit {
+		t.Fatalf("保留 %d 份历史; 期望 %d", len(names), historyLimit)
+	}
+	newest, _ := os.ReadFile(filepath.Join(historyDir(), names[0]))
+	oldest, _ := os.ReadFile(filepath.Join(historyDir(), names[len(names)-1]))
+	if string(newest) != fmt.Sprintf("config %d", historyLimit+4) || string(oldest) != "config 5" {
+		t.Errorf("最新 = %q, 最旧 = %q", newest, oldest)
+	}
+}
+
+func TestRestoreHistory(t *testing.T) {
+	historyStub(t)
+	for _, content := range []string{"first", "second"} {
+		if err := ApplyConfig([]byte(content), nil); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	names := historyNames(t)
+
+	if err := RestoreHistory(names[1]); err != nil {
+		t.Fatalf("RestoreHistory = %v", err)
+	}
+	if got := readConfig(t); got != "first" {
+		t.Errorf("恢复后配置 = %q; 期望 first", got)
+	}
+
+	// 历史目录之外的文件不能恢复
+	os.WriteFile(filepath.Join(config.CaddyDir, "Caddyfile-outside"), []byte("outside"), 0644)
+	for _, name := range []string{"", "Caddyfile", "../Caddyfile-outside", "Caddyfile-/../../Caddyfile-outside", "secret.key", "Caddyfile-missing"} {
+		if err := RestoreHistory(name); err == nil {
+			t.Errorf("RestoreHistory(%q) 期望返回错误", name)
+		}
+	}
+	if got := readConfig(t); got != "first" {
+		t.Errorf("配置 = %q; 期望保持 first", got)
+	}
+}
@@ -0,0 +1,95 @@
+package caddy
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/system"
+)
+
+// MissingModuleError 当前 Caddy 未包含所需模块（如 DNS 服务商插件）
+type MissingModuleError struct {
+	Module      string `json:"module"`
+	DownloadURL string `json:"download_url"`
+}
+
+func (e *MissingModuleError) Error() string {
+	return fmt.Sprintf("当前 Caddy 未包含模块 %s，请下载包含该插件的版本: %s", e.Module, e.DownloadURL)
+}
+
+// 模块列表按可执行文件的修改时间缓存，替换 Caddy 后自动失效
+var (
+	modulesMu      sync.Mutex
+	modulesCache   map[string]bool
+	modulesModTime time.Time
+)
+
+// ListModules 返回 Caddy 已编译的模块（caddy list-modules）
+func ListModules() (map[string]bool, error) {
+	info, err := os.Stat(config.CaddyBin)
+	if err != nil {
+		return nil, err
+	}
+
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if modulesCache != nil && info.ModTime().Equal(modulesModTime) {
+		return modulesCache, nil
+	}
+
+	cmd := exec.Command(config.CaddyBin, "list-modules")
+	system.HideWindow(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("caddy list-modules 执行失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	modules := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		// 模块名形如 dns.providers.cloudflare，忽略空行和统计信息
+		if len(fields) > 0 && strings.Contains(fields[0], ".") {
+			modules[fields[0]] = true
+		}
+	}
+
+	modulesCache, modulesModTime = modules, info.ModTime()
+	return modules, nil
+}
+
+// RequireModule 检查 Caddy 是否包含模块，pkg 为插件的 Go 包路径，用于生成下载地址。
+// Caddy 尚未安装时不做检查
+func RequireModule(module, pkg string) error {
+	if _, err := os.Stat(config.CaddyBin); err != nil {
+		return nil
+	}
+	modules, err := ListModules()
+	if err != nil {
+		return err
+	}
+	if modules[module] {
+		return nil
+	}
+	return &MissingModuleError{Module: module, DownloadURL: CustomBuildURL(pkg)}
+}
+
+// CustomBuildURL 返回 Caddy 官方构建服务中包含指定插件的下载地址
+func CustomBuildURL(packages ...string) string {
+	q := url.Values{}
+	q.Set("os", runtime.GOOS)
+	q.Set("arch", runtime.GOARCH)
+	for _, pkg := range packages {
+		q.Add("p", pkg)
+	}
+	return "https://caddyserver.com/api/download?" + q.Encode()
+}
@@ -0,0 +1,193 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"caddy-manager/internal/config"
+	"caddy-manager/internal/system"
+)
+
+// stopTimeout 优雅停止 Caddy 的最长等待时间，超时后强制结束
+const stopTimeout = 10 * time.Second
+
+var (
+	caddyCmd  *exec.Cmd
+	caddyDone chan struct{}
+	caddyMu   sync.Mutex
+)
+
+// Start 启动 Caddy
+func Start() error {
+	// 先停止已有进程
+	Stop()
+
+	caddyMu.Lock()
+	defer caddyMu.Unlock()
+
+	// 检查 Caddy 可执行文件是否存在
+	if _, err := os.Stat(config.CaddyBin); err != nil {
+		return fmt.Errorf("Caddy 未安装: %v", err)
+	}
+
+	// 创建日志文件
+	logFile, err := os.OpenFile(config.CaddyLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	env := currentEnv()
+	cmd := exec.Command(config.CaddyBin, "run", "--config", config.CaddyConfig, "--adapter", "caddyfile", "--pidfile", config.CaddyPIDFile)
+	cmd.Dir = config.CaddyDir
+	cmd.Env = commandEnv(env)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	system.HideWindow(cmd)
+
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return err
+	}
+
+	// 后台等待进程退出，及时回收子进程，避免僵尸进程干扰存活检测
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		logFile.Close()
+		close(done)
+	}()
+
+	caddyCmd = cmd
+	caddyDone = done
+	markStarted(env)
+
+	log.Printf("✅ Caddy 已启动 (PID %d)", cmd.Process.Pid)
+	return nil
+}
+
+// Stop 停止 Caddy：先发送终止信号，超时后强制结束
+func Stop() {
+	caddyMu.Lock()
+	defer caddyMu.Unlock()
+
+	pid, done := runningPID()
+	if pid == 0 {
+		caddyCmd = nil
+		caddyDone = nil
+		return
+	}
+
+	// 无法发送终止信号时（如 Windows 控制台进程不响应普通 taskkill）直接强制结束
+	if err := terminateProcess(pid); err != nil || !waitExit(pid, done, stopTimeout) {
+		if err == nil {
+			log.Printf("⚠️  Caddy 未在 %v 内退出，强制结束 (PID %d)", stopTimeout, pid)
+		}
+		if err := killProcess(pid); err != nil {
+			log.Printf("强制结束 Caddy 失败 (PID %d): %v", pid, err)
+		}
+		waitExit(pid, done, 2*time.Second)
+	}
+
+	os.Remove(config.CaddyPIDFile)
+	caddyCmd = nil
+	caddyDone = nil
+}
+
+// Restart 重启 Caddy
+func Restart() error {
+	log.Println("🔄 重启 Caddy...")
+	Stop()
+	time.Sleep(1 * time.Second)
+	return Start()
+}
+
+// Reload 重新加载配置（零停机）：
+// 先用 caddy adapt 将 Caddyfile 转换为 JSON，再提交到管理 API 的 /load 接口。
+// 只有在管理 API 不可达（如 Caddy 未运行）或配置需要的环境变量变化时才执行完整重启。
+func Reload() error {
+	if envChanged() {
+		log.Println("🔄 DNS 凭据等环境变量已变化，重启 Caddy 使其生效")
+		return Restart()
+	}
+
+	log.Println("🔄 重新加载 Caddy 配置...")
+
+	cfg, err := Adapt(config.CaddyConfig)
+	if err != nil {
+		return err
+	}
+
+	if err := Load(cfg); err != nil {
+		if errors.Is(err, ErrAdminUnreachable) {
+			log.Printf("⚠️  %v，改为完整重启", err)
+			return Restart()
+		}
+		return err
+	}
+
+	log.Println("✅ Caddy 配置已重新加载")
+	return nil
+}
+
+// IsRunning 检查 Caddy 是否运行
+func IsRunning() bool {
+	caddyMu.Lock()
+	defer caddyMu.Unlock()
+
+	pid, _ := runningPID()
+	return pid != 0
+}
+
+// runningPID 返回正在运行的 Caddy 进程 PID，未运行时返回 0。
+// 优先使用本进程启动的 caddyCmd；否则读取 PID 文件，
+// 以便接管管理器重启前已经在运行的 Caddy。调用方需持有 caddyMu。
+func runningPID() (int, chan struct{}) {
+	if caddyCmd != nil && caddyCmd.Process != nil {
+		select {
+		case <-caddyDone:
+		default:
+			return caddyCmd.Process.Pid, caddyDone
+		}
+	}
+
+	data, err := os.ReadFile(config.CaddyPIDFile)
+	if err != nil {
+		return 0, nil
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, nil
+	}
+	if !processAlive(pid) {
+		return 0, nil
+	}
+	return pid, nil
+}
+
+// waitExit 等待进程退出，done 不为空时直接等待 cmd.Wait 完成
+func waitExit(pid int, done chan struct{}, timeout time.Duration) bool {
+	if done != nil {
+		select {
+		case <-done:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return !processAlive(pid)
+}
@@ -0,0 +1,53 @@
+//go:build !windows
+
+package caddy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// processAlive 通过 signal 0 检测进程是否存在，并借助 /proc 排除僵尸进程
+// 以及 PID 被其他程序复用的情况
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+
+	// 非 Linux 系统没有 /proc，只能依赖 signal 0 的结果
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return !os.IsNotExist(err) || !procMounted()
+	}
+
+	// /proc/<pid>/stat 格式: pid (comm) state ...
+	content := string(stat)
+	idx := strings.LastIndex(content, ")")
+	if idx < 0 {
+		return true
+	}
+	fields := strings.Fields(content[idx+1:])
+	if len(fields) > 0 && (fields[0] == "Z" || fields[0] == "X") {
+		return false
+	}
+
+	comm := content[strings.Index(content, "(")+1 : idx]
+	return strings.Contains(comm, "caddy")
+}
+
+// terminateProcess 发送 SIGTERM，让 Caddy 完成正在处理的请求后退出
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// killProcess 发送 SIGKILL 强制结束进程
+func killProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
+
+func procMounted() bool {
+	_, err := os.Stat("/proc/self/stat")
+	return err == nil
+}
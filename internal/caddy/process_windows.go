@@ -0,0 +1,38 @@
+package caddy
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"caddy-manager/internal/system"
+)
+
+// processAlive 通过 tasklist 按 PID 检测 Caddy 进程是否存在
+func processAlive(pid int) bool {
+	cmd := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH")
+	system.HideWindow(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+
+	// 输出格式: "caddy.exe","1234","Console","1","12,345 K"
+	outputStr := strings.ToLower(string(output))
+	return strings.Contains(outputStr, "caddy") && strings.Contains(outputStr, "\""+strconv.Itoa(pid)+"\"")
+}
+
+// terminateProcess 请求进程退出（不带 /F 的 taskkill）
+func terminateProcess(pid int) error {
+	cmd := exec.Command("taskkill", "/PID", strconv.Itoa(pid))
+	system.HideWindow(cmd)
+	return cmd.Run()
+}
+
+// killProcess 强制结束进程
+func killProcess(pid int) error {
+	cmd := exec.Command("taskkill", "/F", "/PID", strconv.Itoa(pid))
+	system.HideWindow(cmd)
+	return cmd.Run()
+}
@@ -0,0 +1,69 @@
+package caddyfile
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ACMEDirectories 内置的 ACME 目录地址
+var ACMEDirectories = map[string]string{
+	"production": "https://acme-v02.api.letsencrypt.org/directory",
+	"staging":    "https://acme-staging-v02.api.letsencrypt.org/directory",
+	"zerossl":    "https://acme.zerossl.com/v2/DV90",
+}
+
+// ACME 证书申请设置，全局选项和站点 tls 选项共用
+type ACME struct {
+	Email string
+	// CA ACME 目录地址，为空时使用 Caddy 默认（Let's Encrypt 和 ZeroSSL）
+	CA string
+	// CARoot 私有 CA 的根证书文件，用于信任 ACME 服务器
+	CARoot    string
+	EABKeyID  string
+	EABMACKey string
+	// DNS 使用 DNS-01 验证，仅用于站点 tls 选项
+	DNS *DNSChallenge
+}
+
+// Empty 是否没有任何设置
+func (a ACME) Empty() bool {
+	return a == ACME{}
+}
+
+// ACMEDirectory 将 CA 选项（production、staging、zerossl 或 custom）解析为目录地址
+func ACMEDirectory(ca, customURL string) (string, error) {
+	switch ca {
+	case "":
+		return "", nil
+	case "custom":
+		u, err := url.Parse(strings.TrimSpace(customURL))
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return "", &OptionError{Owner: "ACME", Option: "acme_ca_url", Value: customURL, Reason: "应为 ACME 目录的完整地址，如 https://localhost:14000/dir"}
+		}
+		return u.String(), nil
+	}
+
+	if dir, ok := ACMEDirectories[ca]; ok {
+		return dir, nil
+	}
+	return "", &OptionError{Owner: "ACME", Option: "acme_ca", Value: ca, Reason: "可选值: production, staging, zerossl, custom"}
+}
+
+// validate 检查 ACME 设置，owner 用于错误提示
+func (a ACME) validate(owner string) error {
+	if a.Email != "" && (!strings.Contains(a.Email, "@") || strings.ContainsAny(a.Email, " \t\"{}")) {
+		return &OptionError{Owner: owner, Option: "email", Value: a.Email, Reason: "邮箱格式不正确"}
+	}
+	if (a.EABKeyID == "") != (a.EABMACKey == "") {
+		return &OptionError{Owner: owner, Option: "acme_eab", Value: a.EABKeyID, Reason: "EAB 需要同时填写 Key ID 和 HMAC Key"}
+	}
+	if a.CA == ACMEDirectories["zerossl"] && a.EABKeyID == "" {
+		return &OptionError{Owner: owner, Option: "acme_eab", Reason: "使用 ZeroSSL 时需要填写 EAB 凭据"}
+	}
+	for _, v := range []string{a.CA, a.EABKeyID, a.EABMACKey} {
+		if strings.ContainsAny(v, " \t\r\n\"{}") {
+			return &OptionError{Owner: owner, Option: "acme", Value: v, Reason: "不能包含空白、引号或花括号"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package caddyfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BasicAuth 路由或路由下某个路径的 HTTP 基本认证
+type BasicAuth struct {
+	// Path 受保护的路径（完整的请求路径，如 "/admin"），为空表示整个路由
+	Path  string
+	Users []BasicAuthUser
+}
+
+// BasicAuthUser 基本认证用户，Hash 为 bcrypt 哈希
+type BasicAuthUser struct {
+	Username string
+	Hash     string
+}
+
+// AuthRule 渲染用的基本认证规则，Matcher 为空表示保护整个路由
+type AuthRule struct {
+	Matcher string
+	Paths   []string
+	Users   []BasicAuthUser
+}
+
+// AuthRules 返回路由的基本认证规则。规则渲染在路由的 handle 块内，
+// 去掉路径前缀（handle_path）时匹配路径也相应去掉前缀
+func (r Route) AuthRules() []AuthRule {
+	var rules []AuthRule
+	for i, auth := range r.BasicAuth {
+		rule := AuthRule{Users: auth.Users}
+		if paths := r.scope(auth.Path); paths != nil {
+			rule.Matcher = fmt.Sprintf("basic_auth_%d", i)
+			rule.Paths = paths
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// scope 将完整请求路径转换为路由 handle 块内的路径匹配；路径为空或等于路由路径时返回 nil，表示整个路由。
+// 命名匹配器按规则序号命名，由路径生成名称时 /a-b 与 /a_b 会得到相同的名称
+func (r Route) scope(path string) []string {
+	path, _ = NormalizePath(path)
+	if path == "" || path == r.Path {
+		return nil
+	}
+	if r.StripPrefix {
+		path = strings.TrimPrefix(path, r.Path)
+	}
+	return []string{path, path + "/*"}
+}
+
+// checkScopes 检查路径都在路由范围内且互不重叠，option 和 hint 用于错误提示
+func (r Route) checkScopes(option, hint string, list []string) error {
+	var seen []string
+	for _, raw := range list {
+		path, err := NormalizePath(raw)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			path = r.Path
+		}
+		if r.Path != "" && path != r.Path && !strings.HasPrefix(path, r.Path+"/") {
+			return &OptionError{Owner: r.Owner, Option: option, Value: raw, Reason: "路径必须在 " + r.Path + " 之下"}
+		}
+		for _, p := range seen {
+			if p == "" || path == "" || pathsOverlap(p, path) {
+				return &OptionError{Owner: r.Owner, Option: option, Value: raw, Reason: hint}
+			}
+		}
+		seen = append(seen, path)
+	}
+	return nil
+}
+
+// validateBasicAuth 检查认证路径在路由范围内且互不重叠，用户名和哈希格式正确
+func (r Route) validateBasicAuth() error {
+	var paths []string
+	for _, auth := range r.BasicAuth {
+		paths = append(paths, auth.Path)
+	}
+	if err := r.checkScopes("basic_auth", "与已有的认证路径重叠，请把用户加到同一路径下", paths); err != nil {
+		return err
+	}
+
+	for _, auth := range r.BasicAuth {
+		if len(auth.Users) == 0 {
+			return &OptionError{Owner: r.Owner, Option: "basic_auth", Value: auth.Path, Reason: "至少需要一个用户"}
+		}
+		for _, user := range auth.Users {
+			if err := validateAuthUser(r.Owner, user); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateAuthUser 用户名不能包含空白和花括号，密码必须是 bcrypt 哈希
+func validateAuthUser(owner string, user BasicAuthUser) error {
+	if user.Username == "" || strings.ContainsAny(user.Username, " \t\r\n\"{}#:") {
+		return &OptionError{Owner: owner, Option: "username", Value: user.Username, Reason: "不能为空，不能包含空白、冒号、引号或花括号"}
+	}
+	if !strings.HasPrefix(user.Hash, "$2") || strings.ContainsAny(user.Hash, " \t\r\n") {
+		return &OptionError{Owner: owner, Option: "password", Value: user.Username, Reason: "密码哈希必须是 bcrypt 格式"}
+	}
+	return nil
+}
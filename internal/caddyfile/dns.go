@@ -0,0 +1,150 @@
+package caddyfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DNSProviderSpec DNS 服务商插件及其凭据字段
+type DNSProviderSpec struct {
+	Name string `json:"name"`
+	// Module 需要编译进 Caddy 的模块，如 dns.providers.cloudflare
+	Module string `json:"module"`
+	// Package 插件的 Go 包路径，用于生成包含该插件的 Caddy 下载地址
+	Package string `json:"package"`
+	// Fields 凭据字段，Optional 中的字段可以留空
+	Fields   []string `json:"fields"`
+	Optional []string `json:"optional,omitempty"`
+	// Public 服务器地址、密钥名称等非敏感字段，直接写入配置；其余字段通过环境变量传给 Caddy
+	Public []string `json:"public,omitempty"`
+	// Inline 为 true 时唯一的凭据直接写在 dns 指令后，否则写成子块
+	Inline bool `json:"-"`
+}
+
+// DNSProviders 支持的 DNS 服务商
+var DNSProviders = map[string]DNSProviderSpec{
+	"cloudflare": {
+		Name:    "Cloudflare",
+		Module:  "dns.providers.cloudflare",
+		Package: "github.com/caddy-dns/cloudflare",
+		Fields:  []string{"api_token"},
+		Inline:  true,
+	},
+	"alidns": {
+		Name:    "阿里云 DNS",
+		Module:  "dns.providers.alidns",
+		Package: "github.com/caddy-dns/alidns",
+		Fields:  []string{"access_key_id", "access_key_secret"},
+		Public:  []string{"access_key_id"},
+	},
+	"dnspod": {
+		Name:    "DNSPod",
+		Module:  "dns.providers.dnspod",
+		Package: "github.com/caddy-dns/dnspod",
+		Fields:  []string{"auth_token"},
+		Inline:  true,
+	},
+	"rfc2136": {
+		Name:     "RFC2136 (BIND/Knot/PowerDNS 等)",
+		Module:   "dns.providers.rfc2136",
+		Package:  "github.com/caddy-dns/rfc2136",
+		Fields:   []string{"server", "key_name", "key_alg", "key"},
+		Optional: []string{"key_alg"},
+		Public:   []string{"server", "key_name", "key_alg"},
+	},
+}
+
+// DNSOption dns 子块中的一项
+type DNSOption struct {
+	Name  string
+	Value string
+}
+
+// DNSChallenge tls 选项中的 DNS-01 验证设置
+type DNSChallenge struct {
+	Provider string
+	Args     []string
+	Options  []DNSOption
+	// Env 敏感凭据对应的环境变量，配置中只写 {env.NAME} 占位符，避免密钥出现在 Caddyfile 和历史配置中
+	Env map[string]string
+}
+
+// NewDNSChallenge 按服务商的写法生成 DNS 验证设置，缺少必填凭据时返回错误。
+// 敏感凭据写成 {env.<envPrefix>_<字段名>} 占位符，实际值放在 Env 中
+func NewDNSChallenge(provider, envPrefix string, credentials map[string]string) (*DNSChallenge, error) {
+	spec, ok := DNSProviders[provider]
+	if !ok {
+		names := make([]string, 0, len(DNSProviders))
+		for name := range DNSProviders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, &OptionError{Owner: "DNS 服务商", Option: "provider", Value: provider, Reason: "可选值: " + strings.Join(names, ", ")}
+	}
+
+	dns := &DNSChallenge{Provider: provider, Env: make(map[string]string)}
+	for _, field := range spec.Fields {
+		value := strings.TrimSpace(credentials[field])
+		if value == "" {
+			if contains(spec.Optional, field) {
+				continue
+			}
+			return nil, &OptionError{Owner: spec.Name, Option: field, Reason: "不能为空"}
+		}
+		if strings.ContainsAny(value, "\r\n{}") {
+			return nil, &OptionError{Owner: spec.Name, Option: field, Reason: "不能包含换行或花括号"}
+		}
+		if !contains(spec.Public, field) {
+			name := envPrefix + "_" + strings.ToUpper(field)
+			dns.Env[name] = value
+			value = "{env." + name + "}"
+		}
+		if spec.Inline {
+			dns.Args = append(dns.Args, value)
+		} else {
+			dns.Options = append(dns.Options, DNSOption{Name: field, Value: value})
+		}
+	}
+	return dns, nil
+}
+
+// DNSEnvPrefix 已保存的 DNS 服务商凭据使用的环境变量前缀，不同服务商的凭据互不覆盖
+func DNSEnvPrefix(id int) string {
+	return fmt.Sprintf("CADDY_DNS_%d", id)
+}
+
+// Env 返回配置中 DNS 验证凭据需要的环境变量，启动、校验和加载配置的 Caddy 进程都需要这些变量
+func (c *Config) Env() map[string]string {
+	env := make(map[string]string)
+	add := func(acme ACME) {
+		if acme.DNS != nil {
+			for name, value := range acme.DNS.Env {
+				env[name] = value
+			}
+		}
+	}
+	add(c.Global.ACME)
+	for _, site := range c.Sites {
+		for _, route := range site.Routes {
+			if route.TLS != nil {
+				add(route.TLS.ACME)
+			}
+		}
+	}
+	return env
+}
+
+// coversWildcard 通配符证书只能通过 DNS 验证申请，或使用内部 CA、上传的证书
+func (t *TLS) coversWildcard() bool {
+	if t == nil {
+		return false
+	}
+	switch t.Mode {
+	case "internal", "custom", "off":
+		return true
+	case "acme":
+		return t.ACME.DNS != nil
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package caddyfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrorPages 自定义错误页面：出错时依次在 Root 目录中查找 Files，找到时返回该页面，
+// 状态码保持不变；都不存在时使用 Caddy 默认的错误响应
+type ErrorPages struct {
+	Root string
+	// Files 依次尝试的文件，可使用 {err.status_code}，为空时为 /{err.status_code}.html
+	Files []string
+}
+
+// ErrorPageRule 渲染用的错误页面规则，Paths 为空表示站点中除 Exclude 以外的所有路径
+type ErrorPageRule struct {
+	Matcher string
+	Paths   []string
+	// Exclude 同一站点中其他路由的路径，这些路由的错误不使用站点的错误页面
+	Exclude []string
+	Root    string
+	Files   []string
+
+	path string
+}
+
+// ErrorPageRules 返回站点的错误页面规则，带路径的路由在前（路径长的优先），不带路径的路由最后。
+// 不带路径的规则排除带路径的路由，同一域名下按路径转发的项目（包括其"正在启动"页面）不会落到站点的错误页面。
+// handle_errors 中的请求路径为原始路径，不受 handle_path 影响
+func (s Site) ErrorPageRules() []ErrorPageRule {
+	var exclude []string
+	for _, route := range s.Routes {
+		if path, _ := NormalizePath(route.Path); path != "" {
+			exclude = append(exclude, path, path+"/*")
+		}
+	}
+
+	var rules []ErrorPageRule
+	for _, route := range s.Routes {
+		if route.ErrorPages == nil {
+			continue
+		}
+		path, _ := NormalizePath(route.Path)
+		rule := ErrorPageRule{Matcher: "error_pages", Root: route.ErrorPages.Root, Files: route.ErrorPages.Files, path: path}
+		if len(rule.Files) == 0 {
+			rule.Files = []string{"/{err.status_code}.html"}
+		}
+		if path != "" {
+			rule.Paths = []string{path, path + "/*"}
+		} else {
+			rule.Exclude = exclude
+		}
+		rules = append(rules, rule)
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].path) > len(rules[j].path) })
+	// 带路径的规则按序号命名，由路径生成名称时 /a-b 与 /a_b 会得到相同的名称
+	for i := range rules {
+		if rules[i].path != "" {
+			rules[i].Matcher = fmt.Sprintf("error_pages_%d", i)
+		}
+	}
+	return rules
+}
+
+// validate 检查错误页面目录和文件
+func (e *ErrorPages) validate(owner string) error {
+	if e.Root == "" {
+		return &OptionError{Owner: owner, Option: "error_pages", Value: e.Root, Reason: "缺少错误页面目录"}
+	}
+	for _, file := range e.Files {
+		if !strings.HasPrefix(file, "/") || strings.ContainsAny(file, " \t\r\n\"#") {
+			return &OptionError{Owner: owner, Option: "error_pages", Value: file, Reason: "应为以 / 开头的文件路径，不能包含空白或引号"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package caddyfile
+
+import (
+	"sort"
+	"strings"
+)
+
+// HeaderDirections 请求头规则的方向：request 为发往上游（或 PHP、静态文件处理）的请求头，
+// response 为返回给客户端的响应头
+var HeaderDirections = []string{"request", "response"}
+
+// HeaderOperations set 覆盖同名头，add 追加一个值，delete 删除
+var HeaderOperations = []string{"set", "add", "delete"}
+
+// Header 一条请求头或响应头规则
+type Header struct {
+	Direction string `json:"direction"`
+	Operation string `json:"operation"`
+	Name      string `json:"name"`
+	Value     string `json:"value,omitempty"`
+}
+
+// line 渲染为 Caddy header / header_up / request_header 的参数：
+// set 为 "Name value"，add 为 "+Name value"，delete 为 "-Name"
+func (h Header) line() string {
+	switch h.Operation {
+	case "add":
+		return "+" + h.Name + " " + quote(h.Value)
+	case "delete":
+		return "-" + h.Name
+	}
+	return h.Name + " " + quote(h.Value)
+}
+
+// RequestHeaders 返回请求方向的规则，反向代理渲染为 header_up，其他处理方式渲染为 request_header
+func (r Route) RequestHeaders() []string {
+	return r.headerLines("request")
+}
+
+// ResponseHeaders 返回响应方向的规则，渲染为 header 块
+func (r Route) ResponseHeaders() []string {
+	return r.headerLines("response")
+}
+
+func (r Route) headerLines(direction string) []string {
+	var lines []string
+	for _, h := range r.Headers {
+		if h.Direction == direction {
+			lines = append(lines, h.line())
+		}
+	}
+	return lines
+}
+
+// validateHeaders 检查方向、操作、名称和取值
+func (r Route) validateHeaders() error {
+	for _, h := range r.Headers {
+		if !contains(HeaderDirections, h.Direction) {
+			return &OptionError{Owner: r.Owner, Option: "direction", Value: h.Direction, Reason: "可选值: " + strings.Join(HeaderDirections, ", ")}
+		}
+		if !contains(HeaderOperations, h.Operation) {
+			return &OptionError{Owner: r.Owner, Option: "operation", Value: h.Operation, Reason: "可选值: " + strings.Join(HeaderOperations, ", ")}
+		}
+		if h.Name == "" || strings.Trim(h.Name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+			return &OptionError{Owner: r.Owner, Option: "header", Value: h.Name, Reason: "名称只能包含字母、数字、- 和 _"}
+		}
+		if h.Operation == "delete" {
+			if h.Value != "" {
+				return &OptionError{Owner: r.Owner, Option: "header", Value: h.Name, Reason: "删除操作不需要取值"}
+			}
+			continue
+		}
+		if h.Value == "" || strings.ContainsAny(h.Value, "\r\n") {
+			return &OptionError{Owner: r.Owner, Option: "header", Value: h.Name, Reason: "取值不能为空，不能包含换行"}
+		}
+	}
+	return nil
+}
+
+// HeaderPreset 一键添加的一组响应头
+type HeaderPreset struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Headers     []Header `json:"headers"`
+	// StaticOnly 只适用于静态文件和单页应用站点
+	StaticOnly bool `json:"static_only"`
+}
+
+// HeaderPresets 响应头预设。安全头不包含 Content-Security-Policy，CSP 需要按站点实际引用的资源单独配置
+var HeaderPresets = map[string]HeaderPreset{
+	"security": {
+		Name:        "安全响应头",
+		Description: "HSTS、禁止 MIME 嗅探、禁止被其他站点嵌入、限制 Referer，并隐藏 Server 头",
+		Headers: []Header{
+			{Direction: "response", Operation: "set", Name: "Strict-Transport-Security", Value: "max-age=31536000; includeSubDomains"},
+			{Direction: "response", Operation: "set", Name: "X-Content-Type-Options", Value: "nosniff"},
+			{Direction: "response", Operation: "set", Name: "X-Frame-Options", Value: "SAMEORIGIN"},
+			{Direction: "response", Operation: "set", Name: "Referrer-Policy", Value: "strict-origin-when-cross-origin"},
+			{Direction: "response", Operation: "set", Name: "Permissions-Policy", Value: "camera=(), microphone=(), geolocation=()"},
+			{Direction: "response", Operation: "delete", Name: "Server"},
+		},
+	},
+	"cors": {
+		Name:        "跨域访问 (CORS)",
+		Description: "允许任意来源跨域访问，需要携带 Cookie 时请将来源改为具体域名",
+		Headers: []Header{
+			{Direction: "response", Operation: "set", Name: "Access-Control-Allow-Origin", Value: "*"},
+			{Direction: "response", Operation: "set", Name: "Access-Control-Allow-Methods", Value: "GET, POST, PUT, PATCH, DELETE, OPTIONS"},
+			{Direction: "response", Operation: "set", Name: "Access-Control-Allow-Headers", Value: "Content-Type, Authorization"},
+			{Direction: "response", Operation: "set", Name: "Access-Control-Max-Age", Value: "86400"},
+		},
+	},
+	"cache": {
+		Name:        "静态资源缓存",
+		Description: "允许浏览器和 CDN 缓存一天，适用于不常变化的静态站点和单页应用",
+		Headers: []Header{
+			{Direction: "response", Operation: "set", Name: "Cache-Control", Value: "public, max-age=86400"},
+		},
+		StaticOnly: true,
+	},
+}
+
+// HeaderPresetKeys 预设的键，按字母顺序
+func HeaderPresetKeys() []string {
+	keys := make([]string, 0, len(HeaderPresets))
+	for key := range HeaderPresets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,170 @@
+package caddyfile
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// HostError 主机名格式无效
+type HostError struct {
+	Host   string `json:"host"`
+	Reason string `json:"reason"`
+}
+
+func (e *HostError) Error() string {
+	return fmt.Sprintf("域名 %q 无效: %s", e.Host, e.Reason)
+}
+
+// NormalizeHost 把用户输入的主机名转换为 Caddyfile 使用的形式：去掉末尾的点并转为小写，
+// 中文等国际化域名转换为 punycode（xn--），IPv6 地址加上方括号。支持的形式：
+//
+//	example.com  *.example.com  例子.中国  localhost  192.168.1.10  [::1]  ::1  example.com:8443
+//
+// 通配符只能出现在最左侧且独占一级；不能包含协议和路径
+func NormalizeHost(input string) (string, error) {
+	host := strings.TrimSpace(input)
+	if host == "" {
+		return "", &HostError{Host: input, Reason: "不能为空"}
+	}
+	if strings.Contains(host, "://") || strings.ContainsAny(host, "/?#@ \t\"{}") {
+		return "", &HostError{Host: input, Reason: "只填写主机名，不含协议、路径和空格"}
+	}
+
+	// 端口：[v6]:port、host:port；多个冒号且没有方括号时视为 IPv6 地址
+	port, hasPort := "", false
+	switch {
+	case strings.HasPrefix(host, "["):
+		end := strings.Index(host, "]")
+		if end < 0 {
+			return "", &HostError{Host: input, Reason: "IPv6 地址缺少 ]"}
+		}
+		if rest := host[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return "", &HostError{Host: input, Reason: "] 之后只能是 :端口"}
+			}
+			port, hasPort = rest[1:], true
+		}
+		host = host[1:end]
+		if ip := net.ParseIP(host); ip == nil || ip.To4() != nil {
+			return "", &HostError{Host: input, Reason: "方括号中应为 IPv6 地址"}
+		}
+	case strings.Count(host, ":") == 1:
+		host, port, hasPort = strings.Cut(host, ":")
+	}
+	if hasPort {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || port != strconv.Itoa(n) {
+			return "", &HostError{Host: input, Reason: "端口应为 1-65535"}
+		}
+		port = ":" + port
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() != nil {
+			return ip.String() + port, nil
+		}
+		return "[" + ip.String() + "]" + port, nil
+	}
+	if strings.Contains(host, ":") {
+		return "", &HostError{Host: input, Reason: "IPv6 地址无效"}
+	}
+
+	// 中文输入法下常见的全角句点也作为分隔符
+	host = strings.NewReplacer("。", ".", "．", ".", "｡", ".").Replace(host)
+	host = strings.TrimSuffix(host, ".")
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i, label := range labels {
+		if label == "*" {
+			if i > 0 {
+				return "", &HostError{Host: input, Reason: "通配符只能用于最左侧一级，如 *.example.com"}
+			}
+			if len(labels) < 3 {
+				return "", &HostError{Host: input, Reason: "通配符至少要覆盖二级域名，如 *.example.com"}
+			}
+			continue
+		}
+		if strings.Contains(label, "*") {
+			return "", &HostError{Host: input, Reason: "通配符只能单独作为一级，如 *.example.com"}
+		}
+		ascii, err := toASCIILabel(label)
+		if err != nil {
+			return "", &HostError{Host: input, Reason: err.Error()}
+		}
+		labels[i] = ascii
+	}
+	host = strings.Join(labels, ".")
+	if len(host) > 253 {
+		return "", &HostError{Host: input, Reason: "域名总长度不能超过 253 个字符"}
+	}
+	return host + port, nil
+}
+
+// DisplayHost 把 punycode 主机名还原为便于阅读的形式，如 xn--fsqu00a.xn--fiqs8s -> 例子.中国；无法解码的部分原样返回
+func DisplayHost(host string) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if strings.HasPrefix(label, "xn--") {
+			if decoded, err := idna.Lookup.ToUnicode(label); err == nil {
+				labels[i] = decoded
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// toASCIILabel 检查单级标签：字母、数字、连字符（兼容下划线），1-63 个字符，不以连字符开头或结尾；
+// 含非 ASCII 字符时按 IDNA 映射（如全角字母转为半角）后编码为 xn-- 形式，表情符号等非文字字符不能用于域名
+func toASCIILabel(label string) (string, error) {
+	if label == "" {
+		return "", fmt.Errorf("不能包含空的一级（连续的点或以点开头）")
+	}
+	if !utf8.ValidString(label) {
+		return "", fmt.Errorf("包含无效字符")
+	}
+	if !isASCII(label) || strings.HasPrefix(label, "xn--") {
+		decoded, err := idna.Lookup.ToUnicode(label)
+		if err != nil {
+			return "", fmt.Errorf("%s 不是有效的国际化域名", label)
+		}
+		for _, r := range decoded {
+			if r != '-' && !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) {
+				return "", fmt.Errorf("包含不能用于域名的字符 %q", r)
+			}
+		}
+		encoded, err := idna.Lookup.ToASCII(decoded)
+		if err != nil {
+			return "", fmt.Errorf("%s 不是有效的国际化域名", label)
+		}
+		// xn-- 标签必须是非 ASCII 文字的规范编码
+		if strings.HasPrefix(label, "xn--") && encoded != label {
+			return "", fmt.Errorf("%s 不是有效的 punycode", label)
+		}
+		label = encoded
+	}
+	for _, r := range label {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return "", fmt.Errorf("包含无效字符 %q", r)
+		}
+	}
+	if len(label) > 63 {
+		return "", fmt.Errorf("每一级不能超过 63 个字符")
+	}
+	if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return "", fmt.Errorf("每一级不能以连字符开头或结尾")
+	}
+	return label, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
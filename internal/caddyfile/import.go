@@ -0,0 +1,312 @@
+package caddyfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ImportedSite 从现有配置中识别出的一个站点
+type ImportedSite struct {
+	Host       string `json:"host"`
+	Type       string `json:"type"`   // proxy / static / php
+	Target     string `json:"target"` // 代理上游或站点根目录
+	PHPAddress string `json:"php_address,omitempty"`
+	SSLEnabled bool   `json:"ssl_enabled"`
+	TLSMode    string `json:"tls_mode,omitempty"`
+	Line       int    `json:"line,omitempty"`
+}
+
+// Unmapped 无法映射为站点或项目的配置，导入后需要手动处理
+type Unmapped struct {
+	Block     string `json:"block"`
+	Directive string `json:"directive"`
+	Line      int    `json:"line,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// ImportResult 导入解析结果
+type ImportResult struct {
+	Sites    []ImportedSite `json:"sites"`
+	Unmapped []Unmapped     `json:"unmapped"`
+}
+
+// Import 解析现有的 Caddyfile 或 caddy adapt 输出的 JSON，
+// 将 reverse_proxy、file_server、root、php_fastcgi 映射为站点，其余配置记录在 Unmapped 中
+func Import(data []byte) (*ImportResult, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return importJSON(trimmed)
+	}
+
+	blocks, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Sites: []ImportedSite{}, Unmapped: []Unmapped{}}
+	for _, block := range blocks {
+		if len(block.Keys) == 0 {
+			for _, d := range block.Directives {
+				result.unmapped("全局选项", d.Name, d.Line, "全局选项由管理器生成")
+			}
+			continue
+		}
+		if strings.HasPrefix(block.Keys[0], "(") {
+			result.unmapped(block.Keys[0], "", block.Line, "不支持导入片段（snippet）")
+			continue
+		}
+		result.importBlock(block)
+	}
+
+	return result, nil
+}
+
+func (r *ImportResult) unmapped(block, directive string, line int, reason string) {
+	r.Unmapped = append(r.Unmapped, Unmapped{Block: block, Directive: directive, Line: line, Reason: reason})
+}
+
+// importBlock 将一个站点块映射为站点，每个地址生成一个站点
+func (r *ImportResult) importBlock(block ServerBlock) {
+	name := strings.Join(block.Keys, ", ")
+	site := ImportedSite{Line: block.Line}
+
+	var root, proxy, php string
+	var fileServer bool
+	for _, d := range block.Directives {
+		args := d.Args
+		// 只接受全匹配（无匹配器或 *），带路径或命名匹配器的指令无法映射；
+		// 单参数的 root 是省略了匹配器的写法
+		if len(args) > 0 && !(d.Name == "root" && len(args) == 1) && (args[0] == "*" || strings.HasPrefix(args[0], "/") || strings.HasPrefix(args[0], "@")) {
+			if args[0] != "*" {
+				r.unmapped(name, d.Name+" "+args[0], d.Line, "不支持带匹配器的指令")
+				continue
+			}
+			args = args[1:]
+		}
+
+		switch d.Name {
+		case "root":
+			if len(args) != 1 {
+				r.unmapped(name, d.Name, d.Line, "参数无法识别")
+				continue
+			}
+			root = args[0]
+		case "reverse_proxy":
+			upstreams := args
+			for _, sub := range d.Block {
+				if sub.Name == "to" {
+					upstreams = append(upstreams, sub.Args...)
+				} else {
+					r.unmapped(name, "reverse_proxy > "+sub.Name, sub.Line, "不支持的反向代理子指令")
+				}
+			}
+			if len(upstreams) == 0 || proxy != "" {
+				r.unmapped(name, d.Name, d.Line, "缺少上游地址或存在多个 reverse_proxy")
+				continue
+			}
+			proxy = strings.Join(upstreams, " ")
+		case "file_server":
+			fileServer = true
+			if len(args) > 0 || len(d.Block) > 0 {
+				r.unmapped(name, "file_server "+strings.Join(args, " "), d.Line, "file_server 的参数和子指令不会保留")
+			}
+		case "tls":
+			if len(args) == 1 && args[0] == "internal" && len(d.Block) == 0 {
+				site.TLSMode = "internal"
+				continue
+			}
+			r.unmapped(name, "tls "+strings.Join(args, " "), d.Line, "仅支持 tls internal，自有证书请在证书管理中上传")
+		case "php_fastcgi":
+			if len(args) == 0 {
+				r.unmapped(name, d.Name, d.Line, "缺少 PHP FastCGI 地址")
+				continue
+			}
+			php = args[0]
+			if len(d.Block) > 0 {
+				r.unmapped(name, "php_fastcgi { ... }", d.Line, "php_fastcgi 的子指令不会保留")
+			}
+		default:
+			r.unmapped(name, d.Name, d.Line, "不支持的指令")
+		}
+	}
+
+	switch {
+	case php != "":
+		site.Type, site.Target, site.PHPAddress = "php", root, php
+		if proxy != "" {
+			r.unmapped(name, "reverse_proxy", block.Line, "站点已按 PHP 导入，反向代理被忽略")
+		}
+	case proxy != "":
+		site.Type, site.Target = "proxy", proxy
+		if fileServer {
+			r.unmapped(name, "file_server", block.Line, "站点已按反向代理导入，file_server 被忽略")
+		}
+	case fileServer:
+		site.Type, site.Target = "static", root
+	default:
+		r.unmapped(name, "", block.Line, "站点没有可识别的处理方式")
+		return
+	}
+	if site.Target == "" {
+		r.unmapped(name, "root", block.Line, "缺少站点根目录")
+		return
+	}
+
+	for _, key := range block.Keys {
+		imported := site
+		imported.Host, imported.SSLEnabled = splitAddress(key)
+		r.Sites = append(r.Sites, imported)
+	}
+}
+
+// splitAddress 去掉站点地址中的协议前缀，http:// 表示不启用 HTTPS
+func splitAddress(addr string) (string, bool) {
+	if strings.HasPrefix(addr, "http://") {
+		return strings.TrimPrefix(addr, "http://"), false
+	}
+	return strings.TrimPrefix(addr, "https://"), true
+}
+
+// adaptedConfig caddy adapt 输出的 JSON 中与站点相关的部分
+type adaptedConfig struct {
+	Apps struct {
+		HTTP struct {
+			Servers map[string]struct {
+				Routes []adaptedRoute `json:"routes"`
+			} `json:"servers"`
+		} `json:"http"`
+	} `json:"apps"`
+}
+
+type adaptedRoute struct {
+	Match []struct {
+		Host []string `json:"host"`
+		Path []string `json:"path"`
+	} `json:"match"`
+	Handle []adaptedHandler `json:"handle"`
+}
+
+type adaptedHandler struct {
+	Handler   string         `json:"handler"`
+	Routes    []adaptedRoute `json:"routes"`
+	Root      string         `json:"root"`
+	Upstreams []struct {
+		Dial string `json:"dial"`
+	} `json:"upstreams"`
+	Transport *struct {
+		Protocol string `json:"protocol"`
+	} `json:"transport"`
+}
+
+// importJSON 解析 caddy adapt 输出的 JSON 配置
+func importJSON(data []byte) (*ImportResult, error) {
+	var cfg adaptedConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("JSON 配置解析失败: %v", err)
+	}
+
+	result := &ImportResult{Sites: []ImportedSite{}, Unmapped: []Unmapped{}}
+	for serverName, server := range cfg.Apps.HTTP.Servers {
+		for _, route := range server.Routes {
+			var hosts []string
+			for _, m := range route.Match {
+				hosts = append(hosts, m.Host...)
+				if len(m.Path) > 0 {
+					result.unmapped(serverName, "path "+strings.Join(m.Path, " "), 0, "不支持带路径匹配的路由")
+				}
+			}
+			if len(hosts) == 0 {
+				result.unmapped(serverName, "route", 0, "路由没有匹配的主机名")
+				continue
+			}
+
+			site := ImportedSite{SSLEnabled: true}
+			name := strings.Join(hosts, ", ")
+			var root string
+			var phpHelpers []string
+			result.collectHandlers(name, route.Handle, &site, &root, &phpHelpers)
+			if root != "" && (site.Type == "static" || site.Type == "php") {
+				site.Target = root
+			}
+
+			// php_fastcgi 展开后会生成 rewrite 和目录重定向，随 PHP 站点一起导入
+			if site.Type != "php" {
+				for _, handler := range phpHelpers {
+					result.unmapped(name, handler, 0, "不支持的处理器")
+				}
+			}
+
+			if site.Type == "" || site.Target == "" {
+				result.unmapped(name, "", 0, "站点没有可识别的处理方式")
+				continue
+			}
+			for _, host := range hosts {
+				imported := site
+				imported.Host = host
+				result.Sites = append(result.Sites, imported)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// collectHandlers 递归遍历 subroute，识别反向代理、静态文件和 PHP；
+// root 可能与 file_server 位于不同的子路由中，因此由调用方汇总
+func (r *ImportResult) collectHandlers(name string, handlers []adaptedHandler, site *ImportedSite, root *string, phpHelpers *[]string) {
+	for _, h := range handlers {
+		switch h.Handler {
+		case "subroute":
+			for _, route := range h.Routes {
+				r.collectHandlers(name, route.Handle, site, root, phpHelpers)
+			}
+		case "vars":
+			if h.Root != "" {
+				*root = h.Root
+			}
+		case "file_server":
+			if site.Type == "" {
+				site.Type = "static"
+			}
+			if h.Root != "" {
+				*root = h.Root
+			}
+		case "reverse_proxy":
+			var dials []string
+			for _, u := range h.Upstreams {
+				dials = append(dials, u.Dial)
+			}
+			if h.Transport != nil && h.Transport.Protocol == "fastcgi" {
+				site.Type = "php"
+				site.PHPAddress = strings.Join(dials, " ")
+			} else {
+				site.Type = "proxy"
+				site.Target = strings.Join(dials, " ")
+			}
+		case "rewrite", "static_response":
+			*phpHelpers = append(*phpHelpers, h.Handler)
+		default:
+			r.unmapped(name, h.Handler, 0, "不支持的处理器")
+		}
+	}
+}
+
+// LocalPort 判断上游是否为本机端口，返回端口号
+func LocalPort(upstream string) (int, bool) {
+	upstream = strings.TrimPrefix(strings.TrimPrefix(upstream, "http://"), "h2c://")
+	host, portStr, err := net.SplitHostPort(upstream)
+	if err != nil {
+		return 0, false
+	}
+	if host != "localhost" && host != "127.0.0.1" && host != "::1" && host != "" {
+		return 0, false
+	}
+	var port int
+	if _, err := fmt.Sscanf(portStr, "%d", &port); err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
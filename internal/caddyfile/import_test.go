@@ -0,0 +1,91 @@
+package caddyfile
+
+import (
+	"testing"
+)
+
+func TestImportCaddyfile(t *testing.T) {
+	input := `# Caddy 配置文件
+{
+	email admin@example.com
+}
+
+https://app.example.com, www.example.com {
+    reverse_proxy * localhost:6481	
+}
+
+static.example.com {
+	root /var/www/static
+	file_server
+	encode gzip
+}
+
+http://php.example.com {
+	root * "C:\www\php site"
+	php_fastcgi 127.0.0.1:9000
+}
+
+api.example.com {
+	reverse_proxy /v1/* 10.0.0.2:8080
+}
+`
+	result, err := Import([]byte(input))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	want := []ImportedSite{
+		{Host: "app.example.com", Type: "proxy", Target: "localhost:6481", SSLEnabled: true, Line: 6},
+		{Host: "www.example.com", Type: "proxy", Target: "localhost:6481", SSLEnabled: true, Line: 6},
+		{Host: "static.example.com", Type: "static", Target: "/var/www/static", SSLEnabled: true, Line: 10},
+		{Host: "php.example.com", Type: "php", Target: `C:\www\php site`, PHPAddress: "127.0.0.1:9000", SSLEnabled: false, Line: 16},
+	}
+	if len(result.Sites) != len(want) {
+		t.Fatalf("期望 %d 个站点，实际 %+v", len(want), result.Sites)
+	}
+	for i := range want {
+		if result.Sites[i] != want[i] {
+			t.Errorf("站点 %d: 期望 %+v，实际 %+v", i, want[i], result.Sites[i])
+		}
+	}
+
+	// 全局 email、encode 以及带路径匹配器的 reverse_proxy 都应当被报告
+	unmapped := make(map[string]bool)
+	for _, u := range result.Unmapped {
+		unmapped[u.Directive] = true
+	}
+	for _, directive := range []string{"email", "encode", "reverse_proxy /v1/*"} {
+		if !unmapped[directive] {
+			t.Errorf("未报告无法映射的指令 %q: %+v", directive, result.Unmapped)
+		}
+	}
+}
+
+func TestImportAdaptedJSON(t *testing.T) {
+	input := `{"apps":{"http":{"servers":{"srv0":{"routes":[
+		{"match":[{"host":["app.example.com"]}],"handle":[{"handler":"subroute","routes":[
+			{"handle":[{"handler":"reverse_proxy","upstreams":[{"dial":"localhost:6481"}]}]}
+		]}]},
+		{"match":[{"host":["php.example.com"]}],"handle":[{"handler":"subroute","routes":[
+			{"handle":[{"handler":"vars","root":"/var/www/php"}]},
+			{"handle":[{"handler":"static_response"}]},
+			{"handle":[{"handler":"rewrite"}]},
+			{"handle":[{"handler":"reverse_proxy","transport":{"protocol":"fastcgi"},"upstreams":[{"dial":"127.0.0.1:9000"}]}]},
+			{"handle":[{"handler":"headers"}]}
+		]}]}
+	]}}}}}`
+
+	result, err := Import([]byte(input))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(result.Sites) != 2 {
+		t.Fatalf("期望 2 个站点，实际 %+v", result.Sites)
+	}
+	if s := result.Sites[1]; s.Type != "php" || s.Target != "/var/www/php" || s.PHPAddress != "127.0.0.1:9000" {
+		t.Errorf("PHP 站点解析错误: %+v", s)
+	}
+	if len(result.Unmapped) != 1 || result.Unmapped[0].Directive != "headers" {
+		t.Errorf("期望只报告 headers 处理器，实际 %+v", result.Unmapped)
+	}
+}
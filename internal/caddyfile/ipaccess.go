@@ -0,0 +1,112 @@
+package caddyfile
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// PrivateRanges Caddy 内置的私有地址简写，匹配 IPv4/IPv6 私有、回环和链路本地地址
+const PrivateRanges = "private_ranges"
+
+// CloudflareIPRanges Cloudflare 回源使用的地址段（https://www.cloudflare.com/ips/），
+// 作为可信代理的预设
+var CloudflareIPRanges = []string{
+	"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+	"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+	"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+	"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+	"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+	"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+}
+
+// IPAccess 路由整体或其下路径的 IP 访问规则，先检查拒绝列表，再检查允许列表
+type IPAccess struct {
+	// Path 限制的路径（完整的请求路径，如 "/admin"），为空表示整个路由
+	Path string
+	// Allow 允许访问的 IP 或 CIDR，为空表示不限制；不在列表中的客户端被拒绝
+	Allow []string
+	// Deny 拒绝访问的 IP 或 CIDR
+	Deny []string
+	// Status 被拒绝时的响应码，Body 为响应内容
+	Status int
+	Body   string
+}
+
+// IPRule 渲染用的 IP 访问规则，一条 IPAccess 的拒绝列表和允许列表各对应一条
+type IPRule struct {
+	Matcher string
+	// Paths 为空表示整个路由
+	Paths []string
+	// Not 为 true 时匹配不在 Ranges 中的客户端（允许列表）
+	Not    bool
+	Ranges []string
+	Status int
+	Body   string
+}
+
+// IPRules 返回路由的 IP 访问规则。使用 client_ip 匹配器：配置了可信代理时按
+// client_ip_headers 中的真实客户端地址匹配，否则与 remote_ip 相同
+func (r Route) IPRules() []IPRule {
+	var rules []IPRule
+	for i, access := range r.IPAccess {
+		paths := r.scope(access.Path)
+		if len(access.Deny) > 0 {
+			rules = append(rules, IPRule{Matcher: fmt.Sprintf("ip_deny_%d", i), Paths: paths, Ranges: access.Deny, Status: access.Status, Body: access.Body})
+		}
+		if len(access.Allow) > 0 {
+			rules = append(rules, IPRule{Matcher: fmt.Sprintf("ip_allow_%d", i), Paths: paths, Not: true, Ranges: access.Allow, Status: access.Status, Body: access.Body})
+		}
+	}
+	return rules
+}
+
+// validateIPAccess 检查规则路径在路由范围内且互不重叠，地址格式和响应码正确
+func (r Route) validateIPAccess() error {
+	var paths []string
+	for _, access := range r.IPAccess {
+		paths = append(paths, access.Path)
+	}
+	if err := r.checkScopes("ip_access", "与已有的 IP 规则路径重叠，请合并到同一条规则中", paths); err != nil {
+		return err
+	}
+
+	for _, access := range r.IPAccess {
+		if len(access.Allow) == 0 && len(access.Deny) == 0 {
+			return &OptionError{Owner: r.Owner, Option: "ip_access", Value: access.Path, Reason: "允许列表和拒绝列表不能都为空"}
+		}
+		for _, list := range [][]string{access.Allow, access.Deny} {
+			if err := ValidateIPRanges(r.Owner, "ip_access", list); err != nil {
+				return err
+			}
+		}
+		if access.Status < 400 || access.Status > 599 {
+			return &OptionError{Owner: r.Owner, Option: "status", Value: fmt.Sprint(access.Status), Reason: "应为 400-599 之间的响应码"}
+		}
+	}
+	return nil
+}
+
+// ValidateIPRanges 每项必须是 IP、CIDR 或 private_ranges，owner 和 option 用于错误提示
+func ValidateIPRanges(owner, option string, list []string) error {
+	for _, item := range list {
+		if item == PrivateRanges || net.ParseIP(item) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(item); err == nil {
+			continue
+		}
+		return &OptionError{Owner: owner, Option: option, Value: item, Reason: "应为 IP 地址、CIDR（如 10.0.0.0/8）或 private_ranges"}
+	}
+	return nil
+}
+
+// validateClientIPHeaders 请求头名称只能包含字母、数字和 -
+func validateClientIPHeaders(headers []string) error {
+	for _, h := range headers {
+		if h == "" || strings.Trim(h, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") != "" {
+			return &OptionError{Owner: "全局设置", Option: "client_ip_headers", Value: h, Reason: "请求头名称只能包含字母、数字和 -"}
+		}
+	}
+	return nil
+}
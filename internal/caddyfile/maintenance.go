@@ -0,0 +1,28 @@
+package caddyfile
+
+import "strings"
+
+// Maintenance 维护模式：除 Bypass 中的地址外，所有请求返回 503 和维护页面
+type Maintenance struct {
+	// Root 维护页面所在目录，File 为其中的页面文件名
+	Root string
+	File string
+	// RetryAfter Retry-After 响应头，秒数或 HTTP 日期
+	RetryAfter string
+	// Bypass 仍可访问真实服务的 IP 或 CIDR，如管理器所在服务器的地址
+	Bypass []string
+}
+
+// validate 检查页面位置、Retry-After 和放行地址
+func (m *Maintenance) validate(owner string) error {
+	if m.Root == "" {
+		return &OptionError{Owner: owner, Option: "maintenance", Value: m.Root, Reason: "缺少维护页面目录"}
+	}
+	if m.File == "" || strings.ContainsAny(m.File, "/\\ \t\"{}#") {
+		return &OptionError{Owner: owner, Option: "maintenance", Value: m.File, Reason: "维护页面文件名不能包含路径分隔符、空白或花括号"}
+	}
+	if m.RetryAfter == "" || strings.ContainsAny(m.RetryAfter, "\r\n\"{}") {
+		return &OptionError{Owner: owner, Option: "retry_after", Value: m.RetryAfter, Reason: "应为秒数或 HTTP 日期"}
+	}
+	return ValidateIPRanges(owner, "maintenance_bypass", m.Bypass)
+}
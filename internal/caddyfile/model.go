@@ -0,0 +1,470 @@
+package caddyfile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config 完整 Caddyfile 的中间模型，由 sites、projects 表和全局设置构建
+type Config struct {
+	Global Global
+	Sites  []Site
+}
+
+// Global 全局选项块
+type Global struct {
+	// Admin 管理 API 监听地址，需与管理器使用的地址一致
+	Admin string
+	// ACME 全局证书申请设置（email、acme_ca、acme_ca_root、acme_eab）
+	ACME ACME
+	// TrustedProxies 可信代理的 IP 或 CIDR（如 CDN 回源地址），来自这些地址的请求
+	// 按 ClientIPHeaders 取真实客户端地址，IP 访问规则据此匹配
+	TrustedProxies []string
+	// ClientIPHeaders 读取真实客户端地址的请求头，为空时 Caddy 使用 X-Forwarded-For
+	ClientIPHeaders []string
+}
+
+// Site 一个站点块，同一主机名的所有路由合并在同一个站点块中
+type Site struct {
+	Hosts  []string
+	Routes []Route
+}
+
+// Route 站点中的一条路由及其处理方式
+type Route struct {
+	// Owner 路由来源，如 "站点 #1" 或 "项目 #2 blog"，用于注释和错误提示
+	Owner string
+	// Path 路径前缀，如 "/api"；为空表示匹配其余所有请求
+	Path string
+	// StripPrefix 转发前去掉路径前缀（handle_path）
+	StripPrefix bool
+
+	// TLS 路由所属站点的 HTTPS 设置，nil 表示由 Caddy 自动申请证书
+	TLS *TLS
+	// Redirects 站点级的跳转规则，在其他处理之前执行；只有跳转规则的路由用于整站跳转
+	Redirects []Redirect
+	// BasicAuth 路由整体或其下路径的 HTTP 基本认证
+	BasicAuth []BasicAuth
+	// IPAccess 路由整体或其下路径的 IP 允许/拒绝规则，在基本认证之前检查
+	IPAccess []IPAccess
+	// Headers 请求头和响应头规则，按顺序执行
+	Headers []Header
+	// Maintenance 不为 nil 时路由处于维护模式，在 IP 访问规则之后、其他处理之前返回维护页面
+	Maintenance *Maintenance
+	// ErrorPages 路由出错（如上游无法连接）时返回的自定义页面，渲染在站点的 handle_errors 块中
+	ErrorPages *ErrorPages
+
+	Proxy   *ReverseProxy
+	Static  *FileServer
+	SPA     *SPA
+	PHP     *PHPFastCGI
+	Respond *Respond
+}
+
+// TLS 站点的 HTTPS 设置
+type TLS struct {
+	// Mode acme（站点单独的 ACME 设置）、internal（Caddy 内部 CA）、custom（上传的证书）或 off（仅 HTTP）
+	Mode     string
+	CertFile string
+	KeyFile  string
+	// ACME Mode 为 acme 时的证书申请设置
+	ACME ACME
+	// AllowHTTP 同时提供 HTTP 访问，不自动跳转到 HTTPS；Mode 为空表示自动申请证书
+	AllowHTTP bool
+}
+
+// describe 用于比较和提示同一站点的 HTTPS 设置，nil 表示自动
+func (t *TLS) describe() string {
+	if t == nil {
+		return "auto"
+	}
+	if t.AllowHTTP {
+		return (&TLS{Mode: t.Mode, ACME: t.ACME}).describe() + " +http"
+	}
+	if t.Mode == "" {
+		return "auto"
+	}
+	if t.Mode == "acme" {
+		desc := "acme " + strings.TrimSpace(t.ACME.Email+" "+t.ACME.CA)
+		if t.ACME.DNS != nil {
+			desc += " dns " + t.ACME.DNS.Provider
+		}
+		return desc
+	}
+	return t.Mode
+}
+
+// ReverseProxy 反向代理
+type ReverseProxy struct {
+	Upstreams []string
+	// HeaderUp 发往上游的请求头，每项形如 "X-Real-IP {remote_host}"
+	HeaderUp []string
+
+	// LBPolicy 负载均衡策略，为空时使用 Caddy 默认的 random
+	LBPolicy string
+	// LBRetries 请求失败后换一个上游重试的次数
+	LBRetries int
+	// LBTryDuration 选择可用上游的最长时间，如 "5s"
+	LBTryDuration string
+	// HealthURI 主动健康检查的路径，为空表示不启用
+	HealthURI string
+	// HealthInterval 主动健康检查间隔，如 "10s"
+	HealthInterval string
+
+	// FlushInterval 响应写给客户端的刷新间隔，-1 表示立即刷新（SSE）
+	FlushInterval string
+	// StreamTimeout WebSocket 等长连接的最长时间，StreamCloseDelay 为重新加载配置后
+	// 保持旧的长连接的时间，避免每次保存设置都断开所有 WebSocket
+	StreamTimeout    string
+	StreamCloseDelay string
+	// HostHeader 发往上游的 Host 头，如 "{upstream_hostport}"，为空时保留客户端请求的 Host
+	HostHeader string
+	// Transport 连接上游的传输设置，nil 表示明文 HTTP/1.1
+	Transport *Transport
+}
+
+// HasTransport 是否需要渲染 transport 块
+func (p *ReverseProxy) HasTransport() bool {
+	return !p.Transport.Empty()
+}
+
+// LBPolicies 支持的负载均衡策略
+var LBPolicies = []string{"random", "round_robin", "least_conn", "first", "ip_hash", "client_ip_hash", "uri_hash", "cookie"}
+
+// validate 检查负载均衡和健康检查参数
+func (p *ReverseProxy) validate(owner string) error {
+	if p.LBPolicy != "" && !contains(LBPolicies, p.LBPolicy) {
+		return &OptionError{Owner: owner, Option: "lb_policy", Value: p.LBPolicy, Reason: "可选值: " + strings.Join(LBPolicies, ", ")}
+	}
+	if p.LBRetries < 0 {
+		return &OptionError{Owner: owner, Option: "lb_retries", Value: fmt.Sprint(p.LBRetries), Reason: "不能为负数"}
+	}
+	for option, value := range map[string]string{"lb_try_duration": p.LBTryDuration, "health_interval": p.HealthInterval} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return &OptionError{Owner: owner, Option: option, Value: value, Reason: "应为时长，如 5s、1m"}
+		}
+	}
+	if p.HealthURI != "" && (!strings.HasPrefix(p.HealthURI, "/") || strings.ContainsAny(p.HealthURI, " \t\"{}")) {
+		return &OptionError{Owner: owner, Option: "health_uri", Value: p.HealthURI, Reason: "应为以 / 开头的路径"}
+	}
+	if p.HealthInterval != "" && p.HealthURI == "" {
+		return &OptionError{Owner: owner, Option: "health_interval", Value: p.HealthInterval, Reason: "需要同时设置 health_uri，否则 Caddy 不进行主动健康检查"}
+	}
+	if p.HostHeader != "" && p.HostHeader != UpstreamHostPlaceholder && strings.Trim(p.HostHeader, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-:[]") != "" {
+		return &OptionError{Owner: owner, Option: "host_header", Value: p.HostHeader, Reason: "应为主机名或 " + UpstreamHostPlaceholder}
+	}
+	if err := p.validateStreaming(owner); err != nil {
+		return err
+	}
+	if p.HasTransport() {
+		for _, upstream := range p.Upstreams {
+			if strings.Contains(upstream, "://") {
+				return &OptionError{Owner: owner, Option: "upstream", Value: upstream, Reason: "设置了传输选项时上游地址不能包含协议"}
+			}
+		}
+		return p.Transport.validate(owner)
+	}
+	return nil
+}
+
+// FileServer 静态文件服务
+type FileServer struct {
+	Root string
+}
+
+// SPA 单页应用：请求的文件不存在时返回 index.html，由前端路由处理
+type SPA struct {
+	Root string
+	// Precompressed 浏览器支持时优先返回构建时生成的 .zst / .br / .gz 文件
+	Precompressed bool
+	// CacheHashedAssets 文件名带内容哈希的资源（如 app.3f2a1b9c.js、index-BXQ4f2kS.css）长期缓存
+	CacheHashedAssets bool
+}
+
+// HashedAssetPattern 匹配文件名中带内容哈希的构建产物：webpack 等使用十六进制哈希（app.3f2a1b9c.js），
+// Vite 在 /assets/ 下使用 8 位 base64url 哈希（index-BXQ4f2kS.css）。不带哈希的文件不会被长期缓存
+const HashedAssetPattern = `^/assets/.+-[A-Za-z0-9_-]{8}\.(` + hashedAssetExts + `)$|[.-][0-9a-f]{8,}\.(` + hashedAssetExts + `)$`
+
+const hashedAssetExts = "js|mjs|css|woff2?|ttf|otf|eot|svg|png|jpe?g|gif|webp|avif|ico|wasm"
+
+// PHPFastCGI PHP 站点
+type PHPFastCGI struct {
+	Root string
+	// Upstreams FastCGI 地址，如 "127.0.0.1:9000" 或 Unix 套接字 "unix//run/php/php8.2-fpm.sock"，
+	// 多个地址时 Caddy 在它们之间分配请求
+	Upstreams []string
+}
+
+// Respond 固定响应
+type Respond struct {
+	Body   string
+	Status int
+}
+
+// Routed 站点中是否有按路径划分的路由，有则每条路由渲染为独立的 handle 块
+func (s Site) Routed() bool {
+	for _, route := range s.Routes {
+		if route.Path != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// TLS 返回站点的 HTTPS 设置，同一站点的路由设置一致（见 Validate）
+func (s Site) TLS() *TLS {
+	for _, route := range s.Routes {
+		if route.TLS != nil {
+			return route.TLS
+		}
+	}
+	return nil
+}
+
+// Addresses 站点地址，关闭 HTTPS 时加上 http:// 前缀；
+// 允许 HTTP 访问时同时列出 http:// 地址，Caddy 不会为显式的 HTTP 地址添加跳转
+func (s Site) Addresses() []string {
+	tls := s.TLS()
+	if tls == nil || (tls.Mode != "off" && !tls.AllowHTTP) {
+		return s.Hosts
+	}
+	var addrs []string
+	if tls.Mode != "off" {
+		addrs = append(addrs, s.Hosts...)
+	}
+	for _, host := range s.Hosts {
+		if strings.Contains(host, "://") || strings.HasPrefix(host, ":") {
+			if tls.Mode == "off" {
+				addrs = append(addrs, host)
+			}
+		} else {
+			addrs = append(addrs, "http://"+host)
+		}
+	}
+	return addrs
+}
+
+// DuplicateHostError 同一主机名被多个站点或项目使用
+type DuplicateHostError struct {
+	Host   string   `json:"host"`
+	Owners []string `json:"owners"`
+}
+
+func (e *DuplicateHostError) Error() string {
+	return fmt.Sprintf("域名 %s 重复: 同时被 %s 使用", e.Host, strings.Join(e.Owners, "、"))
+}
+
+// PathConflictError 同一主机名下的路径相同或相互包含
+type PathConflictError struct {
+	Host   string   `json:"host"`
+	Paths  []string `json:"paths"`
+	Owners []string `json:"owners"`
+}
+
+func (e *PathConflictError) Error() string {
+	if e.Paths[0] == e.Paths[1] {
+		return fmt.Sprintf("域名 %s 的路径 %s 重复: 同时被 %s 使用", e.Host, e.Paths[0], strings.Join(e.Owners, "、"))
+	}
+	return fmt.Sprintf("域名 %s 的路径 %s 与 %s 重叠: 分别被 %s 使用", e.Host, e.Paths[0], e.Paths[1], strings.Join(e.Owners, "、"))
+}
+
+// OptionError 路由的某个选项取值不正确
+type OptionError struct {
+	Owner  string `json:"owner"`
+	Option string `json:"option"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("%s 的 %s 无效 (%q): %s", e.Owner, e.Option, e.Value, e.Reason)
+}
+
+// PathError 路径格式不正确
+type PathError struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("路径 %q 无效: %s", e.Path, e.Reason)
+}
+
+// NormalizePath 规范化路由路径：补全开头的 "/"，去掉结尾的 "/" 和 "*"；
+// "/" 和空字符串表示匹配所有请求，返回空字符串
+func NormalizePath(path string) (string, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimRight(p, "*")
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	for _, ch := range p {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case strings.ContainsRune("/-_.~%", ch):
+		default:
+			return "", &PathError{Path: path, Reason: "只能包含字母、数字和 / - _ . ~ %"}
+		}
+	}
+	if strings.Contains(p, "//") {
+		return "", &PathError{Path: path, Reason: "不能包含连续的 /"}
+	}
+	return p, nil
+}
+
+// Validate 检查模型是否可以渲染：同一主机名只能有一个不带路径的路由，
+// 带路径的路由之间不能相同或相互包含，HTTPS 设置必须一致，跳转规则不能形成循环
+func (c *Config) Validate() error {
+	if err := c.Global.ACME.validate("全局 ACME 设置"); err != nil {
+		return err
+	}
+	if err := ValidateIPRanges("全局设置", "trusted_proxies", c.Global.TrustedProxies); err != nil {
+		return err
+	}
+	if err := validateClientIPHeaders(c.Global.ClientIPHeaders); err != nil {
+		return err
+	}
+
+	type hostRoute struct {
+		path  string
+		owner string
+		tls   string
+	}
+
+	routes := make(map[string][]hostRoute)
+	redirects := make(map[string][]hostRedirect)
+	var order []string
+	for _, site := range c.Sites {
+		for _, host := range site.Hosts {
+			key := normalizeHost(host)
+			if _, ok := routes[key]; !ok {
+				order = append(order, key)
+			}
+			for _, route := range site.Routes {
+				path, err := NormalizePath(route.Path)
+				if err != nil {
+					return err
+				}
+				if route.Proxy != nil {
+					if err := route.Proxy.validate(route.Owner); err != nil {
+						return err
+					}
+				}
+				if route.SPA != nil && route.SPA.Root == "" {
+					return &OptionError{Owner: route.Owner, Option: "root", Reason: "缺少单页应用的网站目录"}
+				}
+				if route.PHP != nil && len(route.PHP.Upstreams) == 0 {
+					return &OptionError{Owner: route.Owner, Option: "php_fastcgi", Reason: "缺少 PHP FastCGI 地址"}
+				}
+				if route.TLS != nil && route.TLS.Mode == "acme" {
+					if err := route.TLS.ACME.validate(route.Owner); err != nil {
+						return err
+					}
+				}
+				if err := route.validateBasicAuth(); err != nil {
+					return err
+				}
+				if err := route.validateIPAccess(); err != nil {
+					return err
+				}
+				if err := route.validateHeaders(); err != nil {
+					return err
+				}
+				if route.Maintenance != nil {
+					if err := route.Maintenance.validate(route.Owner); err != nil {
+						return err
+					}
+				}
+				if route.ErrorPages != nil {
+					if err := route.ErrorPages.validate(route.Owner); err != nil {
+						return err
+					}
+				}
+				for _, r := range route.Redirects {
+					if err := r.validate(route.Owner); err != nil {
+						return err
+					}
+					redirects[key] = append(redirects[key], hostRedirect{owner: route.Owner, Redirect: r})
+				}
+				if strings.HasPrefix(key, "*.") && !route.TLS.coversWildcard() {
+					return &OptionError{Owner: route.Owner, Option: "tls", Value: key, Reason: "通配符域名需要配置 DNS 验证，或使用内部 CA、上传的证书"}
+				}
+				routes[key] = append(routes[key], hostRoute{path: path, owner: route.Owner, tls: route.TLS.describe()})
+			}
+		}
+	}
+
+	for _, host := range order {
+		var catchAll []string
+		for _, r := range routes[host] {
+			if r.path == "" {
+				catchAll = append(catchAll, r.owner)
+			}
+		}
+		if len(catchAll) > 1 {
+			return &DuplicateHostError{Host: host, Owners: catchAll}
+		}
+
+		for i, a := range routes[host] {
+			if i > 0 && a.tls != routes[host][0].tls {
+				first := routes[host][0]
+				return &OptionError{Owner: a.owner, Option: "tls", Value: a.tls,
+					Reason: fmt.Sprintf("与同域名 %s 的 %s 的 HTTPS 设置 (%s) 不一致", host, first.owner, first.tls)}
+			}
+			if a.path == "" {
+				continue
+			}
+			for _, b := range routes[host][:i] {
+				if b.path != "" && pathsOverlap(a.path, b.path) {
+					return &PathConflictError{
+						Host:   host,
+						Paths:  []string{b.path, a.path},
+						Owners: []string{b.owner, a.owner},
+					}
+				}
+			}
+		}
+	}
+	return checkRedirectLoops(redirects)
+}
+
+// pathsOverlap 判断两个路径前缀是否相同或一个包含另一个（按路径段比较）
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// SiteFor 返回主机名对应的站点块，不存在时追加一个新站点块
+func (c *Config) SiteFor(host string) *Site {
+	key := normalizeHost(host)
+	for i := range c.Sites {
+		for _, h := range c.Sites[i].Hosts {
+			if normalizeHost(h) == key {
+				return &c.Sites[i]
+			}
+		}
+	}
+	c.Sites = append(c.Sites, Site{Hosts: []string{host}})
+	return &c.Sites[len(c.Sites)-1]
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
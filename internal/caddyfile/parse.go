@@ -0,0 +1,239 @@
+package caddyfile
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ServerBlock 解析得到的一个顶层块；Keys 为空表示全局选项块
+type ServerBlock struct {
+	Keys       []string
+	Directives []Directive
+	Line       int
+}
+
+// Directive 一条指令及其子块
+type Directive struct {
+	Name  string
+	Args  []string
+	Block []Directive
+	Line  int
+}
+
+type token struct {
+	text    string
+	line    int
+	quoted  bool
+	newline bool // 行结束标记
+}
+
+// Parse 将 Caddyfile 文本解析为顶层块列表，只做语法层面的解析，不展开 import 和片段
+func Parse(data []byte) ([]ServerBlock, error) {
+	tokens, err := lex(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	var blocks []ServerBlock
+	for {
+		line := p.nextLine()
+		if line == nil {
+			break
+		}
+
+		// 全局选项块
+		if len(blocks) == 0 && len(line) == 1 && isOpenBrace(line[0]) {
+			directives, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, ServerBlock{Directives: directives, Line: line[0].line})
+			continue
+		}
+
+		// 站点地址可能以逗号结尾并延续到下一行
+		for strings.HasSuffix(line[len(line)-1].text, ",") && !isOpenBrace(line[len(line)-1]) {
+			next := p.nextLine()
+			if next == nil {
+				break
+			}
+			line = append(line, next...)
+		}
+
+		block := ServerBlock{Line: line[0].line}
+		hasBrace := isOpenBrace(line[len(line)-1])
+		if hasBrace {
+			line = line[:len(line)-1]
+		}
+		for _, tok := range line {
+			for _, key := range strings.Split(tok.text, ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					block.Keys = append(block.Keys, key)
+				}
+			}
+		}
+
+		if hasBrace {
+			directives, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			block.Directives = directives
+		} else {
+			// 不带花括号的单站点写法：文件其余部分都属于该站点
+			directives, err := p.parseDirectives(false)
+			if err != nil {
+				return nil, err
+			}
+			block.Directives = directives
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// nextLine 返回下一行的所有 token，跳过空行；没有更多内容时返回 nil
+func (p *parser) nextLine() []token {
+	var line []token
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		p.pos++
+		if tok.newline {
+			if len(line) > 0 {
+				return line
+			}
+			continue
+		}
+		line = append(line, tok)
+	}
+	return line
+}
+
+// parseBlock 解析 "{" 之后直到对应 "}" 的指令
+func (p *parser) parseBlock() ([]Directive, error) {
+	directives, err := p.parseDirectives(true)
+	if err != nil {
+		return nil, err
+	}
+	if directives == nil {
+		directives = []Directive{}
+	}
+	return directives, nil
+}
+
+func (p *parser) parseDirectives(inBlock bool) ([]Directive, error) {
+	var directives []Directive
+	for {
+		line := p.nextLine()
+		if line == nil {
+			if inBlock {
+				return nil, fmt.Errorf("缺少匹配的 '}'")
+			}
+			return directives, nil
+		}
+
+		if len(line) == 1 && isCloseBrace(line[0]) {
+			if !inBlock {
+				return nil, fmt.Errorf("第 %d 行: 多余的 '}'", line[0].line)
+			}
+			return directives, nil
+		}
+
+		d := Directive{Name: line[0].text, Line: line[0].line}
+		args := line[1:]
+		if len(args) > 0 && isOpenBrace(args[len(args)-1]) {
+			args = args[:len(args)-1]
+			block, err := p.parseBlock()
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行 %s: %v", d.Line, d.Name, err)
+			}
+			d.Block = block
+		}
+		for _, arg := range args {
+			d.Args = append(d.Args, arg.text)
+		}
+		directives = append(directives, d)
+	}
+}
+
+func isOpenBrace(t token) bool {
+	return !t.quoted && t.text == "{"
+}
+
+func isCloseBrace(t token) bool {
+	return !t.quoted && t.text == "}"
+}
+
+// lex 将 Caddyfile 拆分为 token，支持注释、双引号、反引号和行尾续行符
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	line := 1
+
+	for i := 0; i < len(runes); {
+		ch := runes[i]
+		switch {
+		case ch == '\n':
+			tokens = append(tokens, token{newline: true, line: line})
+			line++
+			i++
+		case ch == '\\' && i+1 < len(runes) && runes[i+1] == '\n':
+			// 续行
+			line++
+			i += 2
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case ch == '"' || ch == '`':
+			start := line
+			var val []rune
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if ch == '"' && c == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\n') {
+					val = append(val, runes[i+1])
+					if runes[i+1] == '\n' {
+						line++
+					}
+					i += 2
+					continue
+				}
+				if c == ch {
+					closed = true
+					i++
+					break
+				}
+				if c == '\n' {
+					line++
+				}
+				val = append(val, c)
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("第 %d 行: 引号未闭合", start)
+			}
+			tokens = append(tokens, token{text: string(val), line: start, quoted: true})
+		default:
+			var val []rune
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				val = append(val, runes[i])
+				i++
+			}
+			tokens = append(tokens, token{text: string(val), line: line})
+		}
+	}
+
+	return tokens, nil
+}
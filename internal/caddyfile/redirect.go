@@ -0,0 +1,228 @@
+package caddyfile
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedirectStatuses 支持的跳转状态码
+var RedirectStatuses = []int{301, 302, 307, 308}
+
+// Redirect 一条跳转规则
+type Redirect struct {
+	// From 源路径，如 "/old"；以 * 结尾时捕获其余部分，可在 To 中用 * 引用；为空表示整个站点
+	From string
+	// To 目标路径或完整地址，可以使用 Caddy 占位符，如 https://example.com{uri}
+	To string
+	// Status 301、302、307 或 308
+	Status int
+}
+
+// RedirectRule 渲染用的跳转规则，捕获通配符时使用 path_regexp 命名匹配器
+type RedirectRule struct {
+	Owner string
+	// Matcher 命名匹配器名称，Regexp 为其正则表达式
+	Matcher string
+	Regexp  string
+	// Path 不捕获通配符时的路径匹配，为空表示整个站点
+	Path   string
+	To     string
+	Status int
+
+	from string
+}
+
+// RedirectLoopError 跳转规则形成循环
+type RedirectLoopError struct {
+	Owner string   `json:"owner"`
+	Chain []string `json:"chain"`
+}
+
+func (e *RedirectLoopError) Error() string {
+	return fmt.Sprintf("%s 的跳转规则形成循环: %s", e.Owner, strings.Join(e.Chain, " → "))
+}
+
+// RedirectRules 返回站点所有路由的跳转规则，按源路径从长到短排列，整站跳转排在最后
+func (s Site) RedirectRules() []RedirectRule {
+	var rules []RedirectRule
+	matchers := 0
+	for _, route := range s.Routes {
+		for _, r := range route.Redirects {
+			rule := RedirectRule{Owner: route.Owner, Path: r.From, To: r.To, Status: r.Status, from: strings.TrimSuffix(r.From, "*")}
+			if prefix, ok := wildcardPrefix(r.From); ok && strings.Contains(r.To, "*") {
+				matchers++
+				rule.Matcher = fmt.Sprintf("redirect_%d", matchers)
+				rule.Regexp = "^" + regexp.QuoteMeta(prefix) + "(.*)$"
+				rule.Path = ""
+				rule.To = strings.Replace(r.To, "*", "{re."+rule.Matcher+".1}", 1)
+			} else if rule.Path == "" && strings.HasPrefix(r.To, "/") {
+				// 只有一个以 / 开头的参数时会被当作路径匹配器，整站跳转到路径需要写明 *
+				rule.Path = "*"
+			}
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].from) > len(rules[j].from) })
+	return rules
+}
+
+// wildcardPrefix 源路径以 * 结尾时返回 * 之前的部分
+func wildcardPrefix(from string) (string, bool) {
+	if strings.HasSuffix(from, "*") {
+		return strings.TrimSuffix(from, "*"), true
+	}
+	return "", false
+}
+
+// validate 检查跳转规则的源路径、目标和状态码
+func (r Redirect) validate(owner string) error {
+	if !containsInt(RedirectStatuses, r.Status) {
+		return &OptionError{Owner: owner, Option: "status", Value: fmt.Sprint(r.Status), Reason: "可选值: 301, 302, 307, 308"}
+	}
+
+	if r.From != "" {
+		if !strings.HasPrefix(r.From, "/") || strings.ContainsAny(r.From, " \t\r\n\"{}#") {
+			return &OptionError{Owner: owner, Option: "source", Value: r.From, Reason: "应为以 / 开头的路径，不能包含空白、引号或花括号"}
+		}
+		if i := strings.Index(r.From, "*"); i >= 0 && i != len(r.From)-1 {
+			return &OptionError{Owner: owner, Option: "source", Value: r.From, Reason: "通配符 * 只能出现在末尾"}
+		}
+	}
+
+	if r.To == "" || strings.ContainsAny(r.To, " \t\r\n\"#") {
+		return &OptionError{Owner: owner, Option: "target", Value: r.To, Reason: "不能为空，不能包含空白或引号"}
+	}
+	if !strings.HasPrefix(r.To, "/") && !strings.HasPrefix(r.To, "http://") && !strings.HasPrefix(r.To, "https://") {
+		return &OptionError{Owner: owner, Option: "target", Value: r.To, Reason: "应为以 / 开头的路径或 http(s):// 开头的地址"}
+	}
+	if strings.Count(r.To, "*") > 1 || (strings.Contains(r.To, "*") && !strings.HasSuffix(r.From, "*")) {
+		return &OptionError{Owner: owner, Option: "target", Value: r.To, Reason: "目标中的 * 只能出现一次，且源路径必须以 * 结尾"}
+	}
+	return nil
+}
+
+// hostRedirect 某个主机名上的一条跳转规则
+type hostRedirect struct {
+	owner string
+	Redirect
+}
+
+// maxRedirectHops 超过该跳转次数视为循环（包括路径不断变长的情况）
+const maxRedirectHops = 10
+
+// checkRedirectLoops 从每条规则的源路径出发模拟跳转，经过同一地址或跳转次数过多时报告循环
+func checkRedirectLoops(redirects map[string][]hostRedirect) error {
+	hosts := make([]string, 0, len(redirects))
+	for host := range redirects {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		for _, start := range redirects[host] {
+			path := "/"
+			if start.From != "" {
+				path = strings.Replace(start.From, "*", "x", 1)
+			}
+
+			chain := []string{host + path}
+			seen := map[string]bool{host + path: true}
+			h, p := host, path
+			for hop := 0; ; hop++ {
+				r, captured, ok := matchRedirect(redirects[h], p)
+				if !ok {
+					break
+				}
+				var internal bool
+				h, p, internal = redirectTarget(h, p, captured, r.To)
+				if !internal {
+					break
+				}
+				if _, ok := redirects[h]; !ok {
+					break
+				}
+				chain = append(chain, h+p)
+				if seen[h+p] || hop >= maxRedirectHops {
+					return &RedirectLoopError{Owner: start.owner, Chain: chain}
+				}
+				seen[h+p] = true
+			}
+		}
+	}
+	return nil
+}
+
+// matchRedirect 找到匹配路径的规则：精确匹配优先，其次最长的通配符前缀，最后为整站跳转
+func matchRedirect(rules []hostRedirect, path string) (hostRedirect, string, bool) {
+	var best hostRedirect
+	var captured string
+	bestLen := -1
+	for _, r := range rules {
+		switch prefix, wildcard := wildcardPrefix(r.From); {
+		case r.From == "":
+			if bestLen < 0 {
+				best, captured, bestLen = r, "", 0
+			}
+		case !wildcard && r.From == path:
+			return r, "", true
+		case wildcard && strings.HasPrefix(path, prefix) && len(prefix) > bestLen:
+			best, captured, bestLen = r, path[len(prefix):], len(prefix)
+		}
+	}
+	return best, captured, bestLen >= 0
+}
+
+// redirectTarget 计算跳转后的主机名和路径，目标为外部地址或无法确定时 internal 为 false
+func redirectTarget(host, path, captured, to string) (string, string, bool) {
+	to = strings.Replace(to, "*", captured, 1)
+	to = strings.NewReplacer("{uri}", path, "{path}", path, "{http.request.uri}", path, "{http.request.uri.path}", path,
+		"{host}", host, "{http.request.host}", host).Replace(to)
+
+	if strings.HasPrefix(to, "/") {
+		return host, to, true
+	}
+	u, err := url.Parse(to)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	target := normalizeHost(u.Hostname())
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	return target, p, true
+}
+
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalHost 返回规范域名设置对应的另一个主机名：canonical 为 "apex" 时
+// www.example.com 跳转到 example.com，为 "www" 时 example.com 跳转到 www.example.com。
+// 返回需要跳转的主机名和跳转目标主机名，不适用时返回空字符串
+func CanonicalHost(domain, canonical string) (from, to string) {
+	domain = normalizeHost(domain)
+	if domain == "" || strings.HasPrefix(domain, "*.") || strings.Contains(domain, ":") || net.ParseIP(domain) != nil {
+		return "", ""
+	}
+	apex := strings.TrimPrefix(domain, "www.")
+	if !strings.Contains(apex, ".") {
+		return "", ""
+	}
+	switch canonical {
+	case "apex":
+		return "www." + apex, apex
+	case "www":
+		return apex, "www." + apex
+	}
+	return "", ""
+}
@@ -0,0 +1,293 @@
+package caddyfile
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// caddyfileTemplate 以 Caddy 官方格式（caddy fmt）输出，避免加载时出现格式警告
+const caddyfileTemplate = `
+{{- define "caddyfile" -}}
+# Caddy 配置文件
+# 由 Caddy 管理器自动生成，手动修改会在下次保存时被覆盖
+{{template "global" .Global}}
+{{- range .Sites}}
+{{template "site" .}}
+{{- end}}
+{{- end}}
+
+{{- define "global"}}
+{
+	admin {{.Admin}}
+{{- with .ACME}}
+{{- with .Email}}
+	email {{.}}
+{{- end}}
+{{- with .CA}}
+	acme_ca {{.}}
+{{- end}}
+{{- with .CARoot}}
+	acme_ca_root {{quote .}}
+{{- end}}
+{{- if .EABKeyID}}
+	acme_eab {
+		key_id {{.EABKeyID}}
+		mac_key {{.EABMACKey}}
+	}
+{{- end}}
+{{- end}}
+{{- if .TrustedProxies}}
+	servers {
+		trusted_proxies static {{join .TrustedProxies " "}}
+{{- with .ClientIPHeaders}}
+		client_ip_headers {{join . " "}}
+{{- end}}
+	}
+{{- end}}
+}
+{{end}}
+
+{{- define "site" -}}
+{{range .Routes}}# {{.Owner}}
+{{end -}}
+{{join .Addresses ", "}} {
+{{with .TLS}}{{include "tls" . | indent 1}}{{end}}
+{{- range .RedirectRules}}{{include "redirect" . | indent 1}}{{end}}
+{{- if .Routed}}{{range .Routes}}{{include "route" . | indent 1}}{{end}}
+{{- else}}{{range .Routes}}{{include "handler" . | indent 1}}{{end}}
+{{- end}}
+{{- with .ErrorPageRules}}{{include "handle_errors" . | indent 1}}{{end -}}
+}
+{{end}}
+
+{{- define "tls"}}
+{{- if eq .Mode "internal"}}tls internal
+{{else if eq .Mode "custom"}}tls {{quote .CertFile}} {{quote .KeyFile}}
+{{else if eq .Mode "acme"}}{{with .ACME}}tls{{with .Email}} {{.}}{{end}}
+{{- if or .CA .CARoot .EABKeyID .DNS}} {
+{{with .CA}}	ca {{.}}
+{{end}}
+{{- with .CARoot}}	ca_root {{quote .}}
+{{end}}
+{{- if .EABKeyID}}	eab {{.EABKeyID}} {{.EABMACKey}}
+{{end}}
+{{- with .DNS}}{{include "dns" . | indent 1}}{{end -}}
+}{{end}}
+{{end}}
+{{- end}}
+{{- end}}
+
+{{- define "dns"}}dns {{.Provider}}
+{{- range .Args}} {{quote .}}{{end}}
+{{- if .Options}} {
+{{range .Options}}	{{.Name}} {{quote .Value}}
+{{end}}}{{end}}
+{{end}}
+
+{{- define "redirect"}}
+{{- if .Matcher}}@{{.Matcher}} path_regexp {{.Matcher}} {{quote .Regexp}}
+redir @{{.Matcher}} {{.To}} {{.Status}}
+{{else}}redir{{with .Path}} {{.}}{{end}} {{.To}} {{.Status}}
+{{end}}
+{{- end}}
+
+{{- define "route"}}
+{{- if .Path}}redir {{.Path}} {{.Path}}/ 308
+{{if .StripPrefix}}handle_path{{else}}handle{{end}} {{.Path}}/* {
+{{- else}}handle {
+{{- end}}
+{{include "handler" . | indent 1}}}
+{{end}}
+
+{{- define "basic_auth"}}
+{{- if .Matcher}}@{{.Matcher}} path {{join .Paths " "}}
+basic_auth @{{.Matcher}} {
+{{else}}basic_auth {
+{{end}}
+{{- range .Users}}	{{.Username}} {{.Hash}}
+{{end}}}
+{{end}}
+
+{{- define "ip_access"}}@{{.Matcher}} {
+{{with .Paths}}	path {{join . " "}}
+{{end}}	{{if .Not}}not {{end}}client_ip {{join .Ranges " "}}
+}
+respond @{{.Matcher}}{{with .Body}} {{quote .}}{{end}} {{.Status}}
+{{end}}
+
+{{- define "handle_errors"}}handle_errors {
+{{- range .}}
+	@{{.Matcher}} {
+{{- with .Paths}}
+		path {{join . " "}}
+{{- end}}
+{{- with .Exclude}}
+		not path {{join . " "}}
+{{- end}}
+		file {
+			root {{quote .Root}}
+			try_files {{join .Files " "}}
+		}
+	}
+	handle @{{.Matcher}} {
+		root * {{quote .Root}}
+		rewrite * {file_match.relative}
+		file_server
+	}
+{{- end}}
+}
+{{end}}
+
+{{- define "maintenance"}}
+{{- if .Bypass}}@maintenance not client_ip {{join .Bypass " "}}
+handle @maintenance {
+{{- else}}handle {
+{{- end}}
+	header Retry-After {{quote .RetryAfter}}
+	header Cache-Control no-store
+	root * {{quote .Root}}
+	rewrite * /{{.File}}
+	file_server {
+		status 503
+	}
+}
+{{end}}
+
+{{- define "handler"}}
+{{- if or .IPAccess .Maintenance}}route {
+{{range .IPRules}}{{include "ip_access" . | indent 1}}{{end}}
+{{- with .Maintenance}}{{include "maintenance" . | indent 1}}{{end}}{{include "directives" . | indent 1}}}
+{{else}}{{include "directives" .}}{{end}}
+{{- end}}
+
+{{- define "directives"}}
+{{- with .ResponseHeaders}}header {
+{{if $.Proxy}}	defer
+{{end}}{{range .}}	{{.}}
+{{end}}}
+{{end}}
+{{- if not .Proxy}}{{range .RequestHeaders}}request_header {{.}}
+{{end}}{{end}}
+{{- range .AuthRules}}{{include "basic_auth" .}}{{end}}
+{{- with .Proxy}}reverse_proxy {{join .Upstreams " "}}
+{{- if or .HostHeader .HeaderUp $.RequestHeaders .LBPolicy .LBRetries .LBTryDuration .HealthURI .HealthInterval .FlushInterval .StreamTimeout .StreamCloseDelay .HasTransport}} {
+{{with .LBPolicy}}	lb_policy {{.}}
+{{end}}
+{{- with .LBRetries}}	lb_retries {{.}}
+{{end}}
+{{- with .LBTryDuration}}	lb_try_duration {{.}}
+{{end}}
+{{- with .HealthURI}}	health_uri {{.}}
+{{end}}
+{{- with .HealthInterval}}	health_interval {{.}}
+{{end}}
+{{- with .FlushInterval}}	flush_interval {{.}}
+{{end}}
+{{- with .StreamTimeout}}	stream_timeout {{.}}
+{{end}}
+{{- with .StreamCloseDelay}}	stream_close_delay {{.}}
+{{end}}
+{{- with .HostHeader}}	header_up Host {{.}}
+{{end}}
+{{- range .HeaderUp}}	header_up {{.}}
+{{end}}
+{{- range $.RequestHeaders}}	header_up {{.}}
+{{end}}
+{{- if .HasTransport}}{{with .Transport}}	transport http {
+{{if .TLS}}		tls
+{{end}}
+{{- with .TLSServerName}}		tls_server_name {{.}}
+{{end}}
+{{- if .TLSInsecureSkipVerify}}		tls_insecure_skip_verify
+{{end}}
+{{- if .H2C}}		versions h2c 2
+{{end}}
+{{- with .DialTimeout}}		dial_timeout {{.}}
+{{end}}
+{{- with .ReadTimeout}}		read_timeout {{.}}
+{{end}}
+{{- with .WriteTimeout}}		write_timeout {{.}}
+{{end}}	}
+{{end}}{{end}}}{{end}}
+{{end}}
+{{- with .Static}}root * {{quote .Root}}
+file_server
+{{end}}
+{{- with .SPA}}root * {{quote .Root}}
+{{if .CacheHashedAssets}}@hashed_assets path_regexp {{quote hashedAssetPattern}}
+header @hashed_assets Cache-Control "public, max-age=31536000, immutable"
+{{end -}}
+try_files {path} /index.html
+file_server{{if .Precompressed}} {
+	precompressed zstd br gzip
+}{{end}}
+{{end}}
+{{- with .PHP}}root * {{quote .Root}}
+php_fastcgi {{join .Upstreams " "}}
+file_server
+{{end}}
+{{- with .Respond}}respond {{quote .Body}} {{.Status}}
+{{end}}
+{{- end}}
+`
+
+var tmpl = template.Must(newTemplate())
+
+func newTemplate() (*template.Template, error) {
+	t := template.New("caddyfile")
+	t.Funcs(template.FuncMap{
+		"join":               strings.Join,
+		"hashedAssetPattern": func() string { return HashedAssetPattern },
+		"quote":              quote,
+		"indent":             indent,
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			err := t.ExecuteTemplate(&buf, name, data)
+			return buf.String(), err
+		},
+	})
+	return t.Parse(caddyfileTemplate)
+}
+
+// generatedHeader Render 输出的文件头，用于识别由管理器生成的配置文件
+const generatedHeader = "# Caddy 配置文件\n# 由 Caddy 管理器自动生成"
+
+// IsGenerated 判断配置文件是否由 Render 生成，手动维护的配置不应被自动覆盖
+func IsGenerated(content []byte) bool {
+	return bytes.HasPrefix(bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), []byte(generatedHeader))
+}
+
+// Render 校验模型并渲染为 Caddyfile 文本
+func Render(cfg *Config) ([]byte, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "caddyfile", cfg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// quote 在参数包含空白、引号或花括号时加上双引号，避免被 Caddyfile 解析为多个参数。
+// Caddyfile 只转义引号，Windows 路径中的反斜杠保持原样
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\r\n\"{}#") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// indent 为每个非空行增加 n 级 Tab 缩进
+func indent(n int, s string) string {
+	prefix := strings.Repeat("\t", n)
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,928 @@
+package caddyfile
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+func TestRenderGolden(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "fallback",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{{
+					Hosts:  []string{":80"},
+					Routes: []Route{{Owner: "默认站点", Respond: &Respond{Body: "Caddy 正在运行", Status: 200}}},
+				}},
+			},
+		},
+		{
+			name: "sites_and_projects",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts:  []string{"proxy.example.com"},
+						Routes: []Route{{Owner: "站点 #1", Proxy: &ReverseProxy{Upstreams: []string{"localhost:3000"}}}},
+					},
+					{
+						Hosts:  []string{"static.example.com"},
+						Routes: []Route{{Owner: "站点 #2", Static: &FileServer{Root: `C:\www\my site`}}},
+					},
+					{
+						Hosts:  []string{"php.example.com"},
+						Routes: []Route{{Owner: "站点 #3", PHP: &PHPFastCGI{Root: "/var/www/php", Upstreams: []string{"localhost:9000"}}}},
+					},
+					{
+						Hosts: []string{"app.example.com"},
+						Routes: []Route{{Owner: "项目 #1 app", Proxy: &ReverseProxy{
+							Upstreams: []string{"127.0.0.1:6481"},
+							HeaderUp:  []string{"X-Real-IP {remote_host}", "Host {upstream_hostport}"},
+						}}},
+					},
+				},
+			},
+		},
+		{
+			name: "shared_host",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{{
+					Hosts: []string{"example.com"},
+					Routes: []Route{
+						{Owner: "站点 #1", Static: &FileServer{Root: "/var/www/html"}},
+						{Owner: "项目 #2 api", Path: "/api", StripPrefix: true, Proxy: &ReverseProxy{
+							Upstreams: []string{"127.0.0.1:8080"},
+							HeaderUp:  []string{"X-Real-IP {remote_host}"},
+						}},
+						{Owner: "项目 #3 app", Path: "/app", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:3000"}}},
+					},
+				}},
+			},
+		},
+		{
+			name: "load_balancing",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{{
+					Hosts: []string{"app.example.com"},
+					Routes: []Route{{Owner: "项目 #1 app", Proxy: &ReverseProxy{
+						Upstreams:      []string{"127.0.0.1:8000", "127.0.0.1:8001", "127.0.0.1:8002"},
+						HeaderUp:       []string{"X-Real-IP {remote_host}"},
+						LBPolicy:       "least_conn",
+						LBRetries:      2,
+						LBTryDuration:  "5s",
+						HealthURI:      "/healthz",
+						HealthInterval: "10s",
+					}}},
+				}},
+			},
+		},
+		{
+			name: "health_check",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{{
+					Hosts: []string{"app.example.com"},
+					Routes: []Route{{Owner: "项目 #1 app", Proxy: &ReverseProxy{
+						Upstreams:      []string{"127.0.0.1:8000"},
+						HealthURI:      "/healthz",
+						HealthInterval: "30s",
+					}}},
+				}},
+			},
+		},
+		{
+			name: "tls_modes",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts:  []string{"nas.local"},
+						Routes: []Route{{Owner: "站点 #1", TLS: &TLS{Mode: "internal"}, Proxy: &ReverseProxy{Upstreams: []string{"192.168.1.10:5000"}}}},
+					},
+					{
+						Hosts: []string{"secure.example.com"},
+						Routes: []Route{{Owner: "站点 #2", Static: &FileServer{Root: "/var/www/secure"}, TLS: &TLS{
+							Mode:     "custom",
+							CertFile: `C:\caddy-manager\data\certs\secure.example.com\cert.pem`,
+							KeyFile:  `C:\caddy-manager\data\certs\secure.example.com\key.pem`,
+						}}},
+					},
+					{
+						Hosts: []string{"plain.example.com"},
+						Routes: []Route{
+							{Owner: "站点 #3", TLS: &TLS{Mode: "off"}, Static: &FileServer{Root: "/var/www/plain"}},
+							{Owner: "项目 #4 api", TLS: &TLS{Mode: "off"}, Path: "/api", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "acme",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019", ACME: ACME{
+					Email: "admin@example.com",
+					CA:    ACMEDirectories["staging"],
+				}},
+				Sites: []Site{
+					{
+						Hosts: []string{"shop.example.com"},
+						Routes: []Route{{Owner: "站点 #1", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}}, TLS: &TLS{Mode: "acme", ACME: ACME{
+							Email:     "shop@example.com",
+							CA:        ACMEDirectories["zerossl"],
+							EABKeyID:  "kid-123",
+							EABMACKey: "hmac-456",
+						}}}},
+					},
+					{
+						Hosts: []string{"pebble.example.com"},
+						Routes: []Route{{Owner: "站点 #2", Static: &FileServer{Root: "/srv"}, TLS: &TLS{Mode: "acme", ACME: ACME{
+							CA:     "https://localhost:14000/dir",
+							CARoot: "/etc/pebble/pebble.minica.pem",
+						}}}},
+					},
+					{
+						Hosts:  []string{"blog.example.com"},
+						Routes: []Route{{Owner: "站点 #3", Static: &FileServer{Root: "/srv/blog"}, TLS: &TLS{Mode: "acme", ACME: ACME{Email: "blog@example.com"}}}},
+					},
+				},
+			},
+		},
+		{
+			name: "dns_challenge",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts: []string{"*.example.com"},
+						Routes: []Route{{Owner: "项目 #1 saas", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8000"}}, TLS: &TLS{Mode: "acme", ACME: ACME{
+							Email: "admin@example.com",
+							DNS:   mustDNS("cloudflare", 1, map[string]string{"api_token": "cf-token"}),
+						}}}},
+					},
+					{
+						Hosts: []string{"*.lab.example.net"},
+						Routes: []Route{{Owner: "站点 #2", Static: &FileServer{Root: "/srv/lab"}, TLS: &TLS{Mode: "acme", ACME: ACME{
+							DNS: mustDNS("rfc2136", 2, map[string]string{"server": "127.0.0.1:53", "key_name": "acme.", "key": "c2VjcmV0"}),
+						}}}},
+					},
+				},
+			},
+		},
+		{
+			name: "redirects",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts: []string{"example.com"},
+						Routes: []Route{{Owner: "站点 #1", Static: &FileServer{Root: "/srv/www"}, Redirects: []Redirect{
+							{From: "/old-page", To: "/new-page", Status: 301},
+							{From: "/blog/*", To: "https://blog.example.com/*", Status: 308},
+							{From: "/docs/*", To: "/manual", Status: 302},
+						}}},
+					},
+					{
+						Hosts: []string{"www.example.com"},
+						Routes: []Route{{Owner: "站点 #1 规范域名", Redirects: []Redirect{
+							{To: "https://example.com{uri}", Status: 301},
+						}}},
+					},
+					{
+						Hosts: []string{"legacy.example.com"},
+						Routes: []Route{{Owner: "站点 #2", TLS: &TLS{AllowHTTP: true}, Redirects: []Redirect{
+							{From: "/", To: "/home/", Status: 307},
+						}, Static: &FileServer{Root: "/srv/legacy"}}},
+					},
+				},
+			},
+		},
+		{
+			name: "basic_auth",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts: []string{"staging.example.com"},
+						Routes: []Route{{Owner: "项目 #1 staging", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:3000"}}, BasicAuth: []BasicAuth{
+							{Users: []BasicAuthUser{{Username: "tester", Hash: testHash}}},
+						}}},
+					},
+					{
+						Hosts: []string{"example.com"},
+						Routes: []Route{
+							{Owner: "站点 #2", Static: &FileServer{Root: "/srv/www"}, BasicAuth: []BasicAuth{
+								{Path: "/admin", Users: []BasicAuthUser{{Username: "alice", Hash: testHash}, {Username: "bob", Hash: testHash}}},
+							}},
+							{Owner: "项目 #3 api", Path: "/api", StripPrefix: true, Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}}, BasicAuth: []BasicAuth{
+								{Path: "/api/internal", Users: []BasicAuthUser{{Username: "ops", Hash: testHash}}},
+							}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "ip_access",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019", TrustedProxies: []string{"173.245.48.0/20", "2400:cb00::/32"}, ClientIPHeaders: []string{"CF-Connecting-IP"}},
+				Sites: []Site{
+					{
+						Hosts: []string{"tools.example.com"},
+						Routes: []Route{{Owner: "站点 #1", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:9000"}},
+							IPAccess:  []IPAccess{{Allow: []string{"10.8.0.0/24", "203.0.113.7"}, Status: 403}},
+							BasicAuth: []BasicAuth{{Users: []BasicAuthUser{{Username: "ops", Hash: testHash}}}},
+						}},
+					},
+					{
+						Hosts: []string{"example.com"},
+						Routes: []Route{
+							{Owner: "站点 #2", Static: &FileServer{Root: "/srv/www"}, IPAccess: []IPAccess{
+								{Path: "/admin", Allow: []string{PrivateRanges}, Deny: []string{"192.168.1.13"}, Status: 404, Body: "Not Found"},
+							}},
+							{Owner: "项目 #3 api", Path: "/api", StripPrefix: true, Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}}, IPAccess: []IPAccess{
+								{Path: "/api/internal", Allow: []string{"10.0.0.0/8"}, Status: 403},
+							}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "headers",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts: []string{"app.example.com"},
+						Routes: []Route{{Owner: "项目 #1 app", Proxy: &ReverseProxy{
+							Upstreams: []string{"127.0.0.1:3000"},
+							HeaderUp:  []string{"X-Real-IP {remote_host}"},
+						}, Headers: append(HeaderPresets["security"].Headers,
+							Header{Direction: "request", Operation: "set", Name: "X-Forwarded-Prefix", Value: "/"},
+							Header{Direction: "request", Operation: "delete", Name: "Cookie"},
+							Header{Direction: "response", Operation: "add", Name: "Vary", Value: "Origin"},
+						)}},
+					},
+					{
+						Hosts: []string{"static.example.com"},
+						Routes: []Route{{Owner: "站点 #2", Static: &FileServer{Root: "/srv/www"}, Headers: append(
+							[]Header{{Direction: "request", Operation: "set", Name: "X-Site", Value: "static"}},
+							append(HeaderPresets["cors"].Headers, HeaderPresets["cache"].Headers...)...,
+						)}},
+					},
+				},
+			},
+		},
+		{
+			name: "maintenance",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts: []string{"app.example.com"},
+						Routes: []Route{{Owner: "项目 #1 app", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:3000"}},
+							Maintenance: &Maintenance{Root: `C:\caddy-manager\data\maintenance`, File: "project-1.html",
+								RetryAfter: "Sat, 17 Oct 2026 02:00:00 GMT", Bypass: []string{"127.0.0.1/8", "::1", "203.0.113.10"}},
+						}},
+					},
+					{
+						Hosts: []string{"example.com"},
+						Routes: []Route{
+							{Owner: "站点 #2", Static: &FileServer{Root: "/srv/www"}},
+							{Owner: "项目 #3 api", Path: "/api", StripPrefix: true, Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}},
+								IPAccess:    []IPAccess{{Deny: []string{"198.51.100.0/24"}, Status: 403}},
+								Maintenance: &Maintenance{Root: "/opt/caddy-manager/data/maintenance", File: "project-3.html", RetryAfter: "3600"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "error_pages",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts: []string{"app.example.com"},
+						Routes: []Route{{Owner: "项目 #1 app", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:3000"}},
+							ErrorPages: &ErrorPages{Root: "/opt/caddy-manager/data/error_pages/project-1",
+								Files: []string{"/starting-{err.status_code}.html", "/{err.status_code}.html"}},
+						}},
+					},
+					{
+						Hosts: []string{"example.com"},
+						Routes: []Route{
+							{Owner: "站点 #2", Static: &FileServer{Root: "/srv/www"},
+								ErrorPages: &ErrorPages{Root: `C:\caddy-manager\data\error_pages\site-2`}},
+							{Owner: "项目 #3 api", Path: "/api", StripPrefix: true, Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}},
+								ErrorPages: &ErrorPages{Root: "/opt/caddy-manager/data/error_pages/project-3"}},
+							// 没有错误页面的项目同样不使用站点的错误页面
+							{Owner: "项目 #4 admin", Path: "/admin", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:9000"}}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "php_pools",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts:  []string{"blog.example.com"},
+						Routes: []Route{{Owner: "站点 #1", PHP: &PHPFastCGI{Root: "/var/www/blog", Upstreams: []string{"unix//opt/caddy-manager/data/php/pool-1.sock"}}}},
+					},
+					{
+						Hosts: []string{"shop.example.com"},
+						Routes: []Route{{Owner: "站点 #2", PHP: &PHPFastCGI{Root: `C:\www\shop`,
+							Upstreams: []string{"127.0.0.1:9074", "127.0.0.1:9075", "127.0.0.1:9076"}}}},
+					},
+				},
+			},
+		},
+		{
+			name: "spa_and_redirect",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts:  []string{"app.example.com"},
+						Routes: []Route{{Owner: "站点 #1", SPA: &SPA{Root: "/srv/app/dist", Precompressed: true, CacheHashedAssets: true}}},
+					},
+					{
+						Hosts:  []string{"admin.example.com"},
+						Routes: []Route{{Owner: "站点 #2", SPA: &SPA{Root: `C:\www\admin`}}},
+					},
+					{
+						Hosts:  []string{"parked.example.net"},
+						Routes: []Route{{Owner: "站点 #3", Redirects: []Redirect{{To: "https://example.com{uri}", Status: 301}}}},
+					},
+				},
+			},
+		},
+		{
+			name: "upstream_transport",
+			cfg: Config{
+				Global: Global{Admin: "localhost:2019"},
+				Sites: []Site{
+					{
+						Hosts: []string{"api.example.com"},
+						Routes: []Route{{Owner: "站点 #1", Proxy: &ReverseProxy{
+							Upstreams:  []string{"backend.internal:443"},
+							HostHeader: UpstreamHostPlaceholder,
+							Transport: &Transport{TLS: true, TLSServerName: "backend.internal", TLSInsecureSkipVerify: true,
+								DialTimeout: "5s", ReadTimeout: "2m", WriteTimeout: "2m"},
+						}}},
+					},
+					{
+						Hosts: []string{"grpc.example.com"},
+						Routes: []Route{{Owner: "项目 #2 grpc", Proxy: &ReverseProxy{
+							Upstreams: []string{"10.0.0.5:50051"},
+							Transport: &Transport{H2C: true},
+						}}},
+					},
+					{
+						Hosts: []string{"live.example.com"},
+						Routes: []Route{{Owner: "项目 #3 live", Proxy: &ReverseProxy{
+							Upstreams:        []string{"127.0.0.1:4000"},
+							FlushInterval:    "-1",
+							StreamTimeout:    "24h",
+							StreamCloseDelay: "5m",
+						}}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Render(&tc.cfg)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("读取 golden 文件失败: %v（使用 -update 生成）", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("渲染结果与 %s 不一致\n--- got ---\n%s\n--- want ---\n%s", golden, got, want)
+			}
+			if !IsGenerated(got) {
+				t.Error("IsGenerated = false; 期望识别为管理器生成的配置")
+			}
+		})
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	for content, want := range map[string]bool{
+		"# Caddy 配置文件\r\n# 由 Caddy 管理器自动生成，手动修改会在下次保存时被覆盖\r\n": true,
+		"# Caddy 配置文件\n# 通过管理界面添加站点后会自动生成配置\n":                 false,
+		"example.com {\n\treverse_proxy localhost:8080\n}\n":   false,
+		"": false,
+	} {
+		if got := IsGenerated([]byte(content)); got != want {
+			t.Errorf("IsGenerated(%q) = %v; 期望 %v", content, got, want)
+		}
+	}
+}
+
+func TestRenderDuplicateHost(t *testing.T) {
+	cfg := Config{Global: Global{Admin: "localhost:2019"}}
+	cfg.SiteFor("example.com").Routes = append(cfg.SiteFor("example.com").Routes,
+		Route{Owner: "站点 #1", Static: &FileServer{Root: "/srv"}})
+	site := cfg.SiteFor("Example.com.")
+	site.Routes = append(site.Routes, Route{Owner: "项目 #2 api", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}}})
+
+	_, err := Render(&cfg)
+	var dup *DuplicateHostError
+	if !errors.As(err, &dup) {
+		t.Fatalf("期望 DuplicateHostError，实际 %v", err)
+	}
+	if dup.Host != "example.com" || len(dup.Owners) != 2 {
+		t.Errorf("错误信息不正确: %+v", dup)
+	}
+}
+
+func TestRenderPathConflict(t *testing.T) {
+	cases := []struct {
+		name  string
+		paths []string
+		want  bool
+	}{
+		{name: "不同路径", paths: []string{"/api", "/app"}, want: false},
+		{name: "相同前缀但不同路径段", paths: []string{"/api", "/apiv2"}, want: false},
+		{name: "路径相同", paths: []string{"/api", "/api/"}, want: true},
+		{name: "路径包含", paths: []string{"/api", "/api/v2"}, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Config{Global: Global{Admin: "localhost:2019"}}
+			site := cfg.SiteFor("example.com")
+			for i, path := range tc.paths {
+				site.Routes = append(site.Routes, Route{
+					Owner: "项目 #" + string(rune('1'+i)),
+					Path:  path,
+					Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}},
+				})
+			}
+
+			_, err := Render(&cfg)
+			var conflict *PathConflictError
+			if got := errors.As(err, &conflict); got != tc.want {
+				t.Fatalf("期望冲突=%v，实际错误 %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestRenderInvalidOption(t *testing.T) {
+	proxies := []ReverseProxy{
+		{Upstreams: []string{"127.0.0.1:8000"}, LBPolicy: "fastest"},
+		{Upstreams: []string{"127.0.0.1:8000"}, LBTryDuration: "5"},
+		{Upstreams: []string{"127.0.0.1:8000"}, HealthURI: "healthz"},
+		{Upstreams: []string{"127.0.0.1:8000"}, HealthInterval: "10s"},
+	}
+	for i := range proxies {
+		cfg := Config{Global: Global{Admin: "localhost:2019"}}
+		site := cfg.SiteFor("example.com")
+		site.Routes = append(site.Routes, Route{Owner: "项目 #1 app", Proxy: &proxies[i]})
+
+		var optErr *OptionError
+		if _, err := Render(&cfg); !errors.As(err, &optErr) {
+			t.Errorf("%+v: 期望 OptionError，实际 %v", proxies[i], err)
+		}
+	}
+}
+
+func TestRenderTLSConflict(t *testing.T) {
+	cfg := Config{Global: Global{Admin: "localhost:2019"}}
+	site := cfg.SiteFor("example.com")
+	site.Routes = append(site.Routes,
+		Route{Owner: "站点 #1", Static: &FileServer{Root: "/srv"}},
+		Route{Owner: "项目 #2 api", Path: "/api", TLS: &TLS{Mode: "off"}, Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}}},
+	)
+
+	var optErr *OptionError
+	if _, err := Render(&cfg); !errors.As(err, &optErr) || optErr.Option != "tls" {
+		t.Fatalf("期望 tls OptionError，实际 %v", err)
+	}
+}
+
+func TestRenderRedirectLoop(t *testing.T) {
+	cases := []struct {
+		name  string
+		hosts map[string][]Redirect
+		loop  bool
+	}{
+		{
+			name: "规范域名互相跳转",
+			hosts: map[string][]Redirect{
+				"example.com":     {{To: "https://www.example.com{uri}", Status: 301}},
+				"www.example.com": {{To: "https://example.com{uri}", Status: 301}},
+			},
+			loop: true,
+		},
+		{
+			name: "路径互相跳转",
+			hosts: map[string][]Redirect{
+				"example.com": {{From: "/a", To: "/b", Status: 302}, {From: "/b", To: "/a", Status: 302}},
+			},
+			loop: true,
+		},
+		{
+			name: "通配符目标仍匹配源路径",
+			hosts: map[string][]Redirect{
+				"example.com": {{From: "/old/*", To: "/old/new/*", Status: 301}},
+			},
+			loop: true,
+		},
+		{
+			name: "跳转到外部站点",
+			hosts: map[string][]Redirect{
+				"example.com":     {{From: "/docs/*", To: "https://docs.example.org/*", Status: 301}},
+				"www.example.com": {{To: "https://example.com{uri}", Status: 301}},
+			},
+			loop: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Config{Global: Global{Admin: "localhost:2019"}}
+			for host, redirects := range tc.hosts {
+				site := cfg.SiteFor(host)
+				site.Routes = append(site.Routes, Route{Owner: host, Redirects: redirects})
+			}
+
+			_, err := Render(&cfg)
+			var loop *RedirectLoopError
+			if got := errors.As(err, &loop); got != tc.loop {
+				t.Fatalf("期望循环=%v，实际错误 %v", tc.loop, err)
+			}
+		})
+	}
+}
+
+// testHash 示例 bcrypt 哈希，只用于渲染
+const testHash = "$2a$14$Zkx19XLiW6VYouLHR5NmfOFU0z2GTNmpkT/5qqR7hx4IjWJPDhjvG"
+
+func TestRenderBasicAuthInvalid(t *testing.T) {
+	cases := map[string]Route{
+		"路径在路由之外": {Owner: "项目 #1 api", Path: "/api", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8080"}},
+			BasicAuth: []BasicAuth{{Path: "/admin", Users: []BasicAuthUser{{Username: "a", Hash: testHash}}}}},
+		"路径重叠": {Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, BasicAuth: []BasicAuth{
+			{Path: "/admin", Users: []BasicAuthUser{{Username: "a", Hash: testHash}}},
+			{Path: "/admin/users", Users: []BasicAuthUser{{Username: "b", Hash: testHash}}},
+		}},
+		"明文密码": {Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, BasicAuth: []BasicAuth{
+			{Users: []BasicAuthUser{{Username: "a", Hash: "secret"}}},
+		}},
+	}
+	for name, route := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{Global: Global{Admin: "localhost:2019"}}
+			site := cfg.SiteFor("example.com")
+			site.Routes = append(site.Routes, route)
+
+			var optErr *OptionError
+			if _, err := Render(&cfg); !errors.As(err, &optErr) {
+				t.Fatalf("期望 OptionError，实际 %v", err)
+			}
+		})
+	}
+}
+
+func TestRuleMatchersUnique(t *testing.T) {
+	// /a-b 与 /a_b 不重叠，但由路径生成的匹配器名称相同
+	users := []BasicAuthUser{{Username: "a", Hash: testHash}}
+	route := Route{Owner: "站点 #1", Static: &FileServer{Root: "/srv"},
+		BasicAuth: []BasicAuth{{Path: "/a-b", Users: users}, {Path: "/a_b", Users: users}},
+		IPAccess: []IPAccess{
+			{Path: "/a-b", Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}, Status: 403},
+			{Path: "/a_b", Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}, Status: 403},
+		},
+	}
+
+	seen := make(map[string]bool)
+	var matchers []string
+	for _, rule := range route.AuthRules() {
+		matchers = append(matchers, rule.Matcher)
+	}
+	for _, rule := range route.IPRules() {
+		matchers = append(matchers, rule.Matcher)
+	}
+	for _, m := range matchers {
+		if seen[m] {
+			t.Errorf("匹配器 %q 重复: %v", m, matchers)
+		}
+		seen[m] = true
+	}
+	if len(matchers) != 6 {
+		t.Errorf("匹配器 = %v; 期望 6 个", matchers)
+	}
+}
+
+func TestErrorPageMatchersUnique(t *testing.T) {
+	site := Site{Hosts: []string{"example.com"}, Routes: []Route{
+		{Owner: "项目 #1", Path: "/a-b", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8001"}}, ErrorPages: &ErrorPages{Root: "/pages/1"}},
+		{Owner: "项目 #2", Path: "/a_b", Proxy: &ReverseProxy{Upstreams: []string{"127.0.0.1:8002"}}, ErrorPages: &ErrorPages{Root: "/pages/2"}},
+	}}
+	rules := site.ErrorPageRules()
+	if len(rules) != 2 || rules[0].Matcher == rules[1].Matcher {
+		t.Errorf("错误页面规则 = %+v; 期望两个不同的匹配器", rules)
+	}
+}
+
+func TestRenderIPAccessInvalid(t *testing.T) {
+	cases := map[string]Route{
+		"地址格式错误": {Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, IPAccess: []IPAccess{{Allow: []string{"10.0.0.0/33"}, Status: 403}}},
+		"列表为空":   {Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, IPAccess: []IPAccess{{Status: 403}}},
+		"响应码无效":  {Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, IPAccess: []IPAccess{{Deny: []string{"1.2.3.4"}, Status: 200}}},
+		"路径重叠": {Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, IPAccess: []IPAccess{
+			{Path: "/admin", Allow: []string{"10.0.0.0/8"}, Status: 403},
+			{Deny: []string{"1.2.3.4"}, Status: 403},
+		}},
+	}
+	for name, route := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{Global: Global{Admin: "localhost:2019"}}
+			site := cfg.SiteFor("example.com")
+			site.Routes = append(site.Routes, route)
+
+			var optErr *OptionError
+			if _, err := Render(&cfg); !errors.As(err, &optErr) {
+				t.Fatalf("期望 OptionError，实际 %v", err)
+			}
+		})
+	}
+
+	cfg := Config{Global: Global{Admin: "localhost:2019", TrustedProxies: []string{"cdn"}}}
+	var optErr *OptionError
+	if _, err := Render(&cfg); !errors.As(err, &optErr) {
+		t.Errorf("可信代理格式错误时期望 OptionError，实际 %v", err)
+	}
+}
+
+func TestRenderHeadersInvalid(t *testing.T) {
+	cases := map[string]Header{
+		"方向错误":   {Direction: "upstream", Operation: "set", Name: "X-A", Value: "1"},
+		"操作错误":   {Direction: "response", Operation: "replace", Name: "X-A", Value: "1"},
+		"名称包含空格": {Direction: "response", Operation: "set", Name: "X A", Value: "1"},
+		"缺少取值":   {Direction: "response", Operation: "set", Name: "X-A"},
+		"删除带取值":  {Direction: "request", Operation: "delete", Name: "X-A", Value: "1"},
+	}
+	for name, header := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{Global: Global{Admin: "localhost:2019"}}
+			site := cfg.SiteFor("example.com")
+			site.Routes = append(site.Routes, Route{Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, Headers: []Header{header}})
+
+			var optErr *OptionError
+			if _, err := Render(&cfg); !errors.As(err, &optErr) {
+				t.Fatalf("期望 OptionError，实际 %v", err)
+			}
+		})
+	}
+}
+
+func TestRenderTransportInvalid(t *testing.T) {
+	cases := map[string]*ReverseProxy{
+		"h2c 与 HTTPS":  {Upstreams: []string{"10.0.0.5:443"}, Transport: &Transport{TLS: true, H2C: true}},
+		"SNI 需要 HTTPS": {Upstreams: []string{"10.0.0.5:80"}, Transport: &Transport{TLSServerName: "a.internal"}},
+		"超时格式":         {Upstreams: []string{"10.0.0.5:80"}, Transport: &Transport{DialTimeout: "5"}},
+		"地址包含协议":       {Upstreams: []string{"http://10.0.0.5:80"}, Transport: &Transport{TLS: true}},
+		"刷新间隔":         {Upstreams: []string{"10.0.0.5:80"}, FlushInterval: "-2"},
+	}
+	for name, proxy := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{Global: Global{Admin: "localhost:2019"}}
+			site := cfg.SiteFor("example.com")
+			site.Routes = append(site.Routes, Route{Owner: "站点 #1", Proxy: proxy})
+
+			var optErr *OptionError
+			if _, err := Render(&cfg); !errors.As(err, &optErr) {
+				t.Fatalf("期望 OptionError，实际 %v", err)
+			}
+		})
+	}
+}
+
+func TestHashedAssetPattern(t *testing.T) {
+	re := regexp.MustCompile(HashedAssetPattern)
+	for _, path := range []string{"/static/js/main.3f2a1b9c.js", "/main.1b2c3d4e5f6a7b8c.css", "/assets/index-BXQ4f2kS.js", "/assets/vendor-react-Dk_9-a1Z.css"} {
+		if !re.MatchString(path) {
+			t.Errorf("%s 应匹配带哈希的资源", path)
+		}
+	}
+	for _, path := range []string{"/index.html", "/app-component.js", "/js/app.js", "/assets/logo.png", "/favicon.ico", "/assets/main.3f2a1b9c.html"} {
+		if re.MatchString(path) {
+			t.Errorf("%s 不应匹配", path)
+		}
+	}
+}
+
+func TestSplitUpstream(t *testing.T) {
+	cases := []struct {
+		target, scheme, address string
+	}{
+		{"localhost:3000", "http", "localhost:3000"},
+		{"https://api.example.com", "https", "api.example.com:443"},
+		{"https://api.example.com:8443/", "https", "api.example.com:8443"},
+		{"h2c://10.0.0.5:50051", "h2c", "10.0.0.5:50051"},
+		{"http://[::1]", "http", "[::1]:80"},
+		{"unix//run/app.sock", "http", "unix//run/app.sock"},
+	}
+	for _, tc := range cases {
+		scheme, address, err := SplitUpstream(tc.target)
+		if err != nil || scheme != tc.scheme || address != tc.address {
+			t.Errorf("SplitUpstream(%q) = %q, %q, %v，期望 %q, %q", tc.target, scheme, address, err, tc.scheme, tc.address)
+		}
+	}
+	for _, target := range []string{"ftp://a.com", "http://a.com/api", "a.com:99999", ""} {
+		if _, _, err := SplitUpstream(target); err == nil {
+			t.Errorf("SplitUpstream(%q) 应返回错误", target)
+		}
+	}
+}
+
+func TestACMEDirectory(t *testing.T) {
+	if dir, err := ACMEDirectory("staging", ""); err != nil || dir != ACMEDirectories["staging"] {
+		t.Errorf("staging: %q, %v", dir, err)
+	}
+	if dir, err := ACMEDirectory("custom", "https://localhost:14000/dir"); err != nil || dir != "https://localhost:14000/dir" {
+		t.Errorf("custom: %q, %v", dir, err)
+	}
+	for _, ca := range [][2]string{{"custom", "localhost:14000"}, {"letsencrypt", ""}} {
+		var optErr *OptionError
+		if _, err := ACMEDirectory(ca[0], ca[1]); !errors.As(err, &optErr) {
+			t.Errorf("ACMEDirectory(%q, %q) 期望 OptionError，实际 %v", ca[0], ca[1], err)
+		}
+	}
+
+	cfg := Config{Global: Global{Admin: "localhost:2019", ACME: ACME{CA: ACMEDirectories["zerossl"]}}}
+	var optErr *OptionError
+	if _, err := Render(&cfg); !errors.As(err, &optErr) {
+		t.Errorf("ZeroSSL 缺少 EAB 时期望 OptionError，实际 %v", err)
+	}
+}
+
+func mustDNS(provider string, id int, credentials map[string]string) *DNSChallenge {
+	dns, err := NewDNSChallenge(provider, DNSEnvPrefix(id), credentials)
+	if err != nil {
+		panic(err)
+	}
+	return dns
+}
+
+func TestRenderWildcardNeedsDNS(t *testing.T) {
+	cfg := Config{Global: Global{Admin: "localhost:2019"}}
+	site := cfg.SiteFor("*.example.com")
+	site.Routes = append(site.Routes, Route{Owner: "站点 #1", Static: &FileServer{Root: "/srv"}})
+
+	var optErr *OptionError
+	if _, err := Render(&cfg); !errors.As(err, &optErr) {
+		t.Fatalf("期望 OptionError，实际 %v", err)
+	}
+
+	if _, err := NewDNSChallenge("alidns", DNSEnvPrefix(1), map[string]string{"access_key_id": "id"}); !errors.As(err, &optErr) || optErr.Option != "access_key_secret" {
+		t.Errorf("缺少凭据时期望 OptionError，实际 %v", err)
+	}
+}
+
+func TestRenderDNSSecretsFromEnv(t *testing.T) {
+	cfg := Config{Global: Global{Admin: "localhost:2019"}}
+	site := cfg.SiteFor("*.example.com")
+	site.Routes = append(site.Routes, Route{Owner: "站点 #1", Static: &FileServer{Root: "/srv"}, TLS: &TLS{Mode: "acme", ACME: ACME{
+		DNS: mustDNS("cloudflare", 4, map[string]string{"api_token": "cf-token"}),
+	}}})
+	site = cfg.SiteFor("*.example.cn")
+	site.Routes = append(site.Routes, Route{Owner: "站点 #2", Static: &FileServer{Root: "/srv/cn"}, TLS: &TLS{Mode: "acme", ACME: ACME{
+		DNS: mustDNS("alidns", 3, map[string]string{"access_key_id": "LTAI-id", "access_key_secret": "ali-secret"}),
+	}}})
+
+	got, err := Render(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"ali-secret", "cf-token"} {
+		if strings.Contains(string(got), secret) {
+			t.Errorf("渲染结果包含凭据 %q:\n%s", secret, got)
+		}
+	}
+	for _, placeholder := range []string{"{env.CADDY_DNS_3_ACCESS_KEY_SECRET}", "{env.CADDY_DNS_4_API_TOKEN}", "LTAI-id"} {
+		if !strings.Contains(string(got), placeholder) {
+			t.Errorf("渲染结果缺少 %q:\n%s", placeholder, got)
+		}
+	}
+
+	want := map[string]string{"CADDY_DNS_3_ACCESS_KEY_SECRET": "ali-secret", "CADDY_DNS_4_API_TOKEN": "cf-token"}
+	if env := cfg.Env(); !reflect.DeepEqual(env, want) {
+		t.Errorf("Env() = %v; 期望 %v", env, want)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	cases := map[string]string{
+		"":        "",
+		"/":       "",
+		"/*":      "",
+		"api":     "/api",
+		"/api/":   "/api",
+		"/api/*":  "/api",
+		" /v1/x ": "/v1/x",
+	}
+	for in, want := range cases {
+		got, err := NormalizePath(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizePath(%q) = %q, %v; 期望 %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"/a b", "/api/{id}", "//api"} {
+		var pathErr *PathError
+		if _, err := NormalizePath(in); !errors.As(err, &pathErr) {
+			t.Errorf("NormalizePath(%q) 期望 PathError，实际 %v", in, err)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	cases := map[string]string{
+		"Example.COM.":         "example.com",
+		" *.example.com ":      "*.example.com",
+		"例子.中国":                "xn--fsqu00a.xn--fiqs8s",
+		"例子。中国":                "xn--fsqu00a.xn--fiqs8s",
+		"münchen.de":           "xn--mnchen-3ya.de",
+		"bücher.example":       "xn--bcher-kva.example",
+		"xn--mnchen-3ya.de":    "xn--mnchen-3ya.de",
+		"ＭÜＮＣＨＥＮ.de":           "xn--mnchen-3ya.de",
+		"ｅｘａｍｐｌｅ．ｃｏｍ":          "example.com",
+		"localhost":            "localhost",
+		"192.168.1.10":         "192.168.1.10",
+		"::1":                  "[::1]",
+		"[2001:DB8::1]":        "[2001:db8::1]",
+		"[2001:db8::1]:8443":   "[2001:db8::1]:8443",
+		"example.com:8443":     "example.com:8443",
+		"192.168.1.10:8080":    "192.168.1.10:8080",
+		"_acme.example.com":    "_acme.example.com",
+		"api-v2.example.co.uk": "api-v2.example.co.uk",
+	}
+	for in, want := range cases {
+		got, err := NormalizeHost(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeHost(%q) = %q, %v; 期望 %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "https://example.com", "example.com/api", "a b.com", "*.com", "api.*.example.com",
+		"*api.example.com", "example..com", "-example.com", "example.com:0", "example.com:99999", "example.com:",
+		"[::1", "[127.0.0.1]", "2001:db8::zz", "xn--zz-.com", "xn--mnchen-3ya-.de", strings.Repeat("a", 64) + ".com",
+		"😀.example.com", "i❤.ws", "xn--e28h.example.com"} {
+		var hostErr *HostError
+		if got, err := NormalizeHost(in); !errors.As(err, &hostErr) {
+			t.Errorf("NormalizeHost(%q) = %q, %v; 期望 HostError", in, got, err)
+		}
+	}
+}
+
+func TestDisplayHost(t *testing.T) {
+	for _, host := range []string{"例子.中国", "münchen.de", "*.bücher.example", "ñandú.example:8443"} {
+		ascii, err := NormalizeHost(host)
+		if err != nil {
+			t.Fatalf("NormalizeHost(%q): %v", host, err)
+		}
+		if got := DisplayHost(ascii); got != host {
+			t.Errorf("DisplayHost(%q) = %q; 期望 %q", ascii, got, host)
+		}
+	}
+	if got := DisplayHost("example.com"); got != "example.com" {
+		t.Errorf("DisplayHost(example.com) = %q", got)
+	}
+}
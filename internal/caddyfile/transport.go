@@ -0,0 +1,113 @@
+package caddyfile
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpstreamHostPlaceholder 使用上游地址作为 Host 头，HTTPS 上游和远程主机通常需要
+const UpstreamHostPlaceholder = "{upstream_hostport}"
+
+// UpstreamSchemes 上游协议：http（默认）、https、h2c（明文 HTTP/2，用于 gRPC）
+var UpstreamSchemes = []string{"http", "https", "h2c"}
+
+// Transport 反向代理连接上游的传输设置，渲染为 transport http 块
+type Transport struct {
+	// TLS 使用 HTTPS 连接上游
+	TLS bool
+	// TLSServerName SNI 和证书校验使用的名称，为空时使用上游地址中的主机名
+	TLSServerName string
+	// TLSInsecureSkipVerify 不校验上游证书，用于自签名证书
+	TLSInsecureSkipVerify bool
+	// H2C 以明文 HTTP/2 连接上游
+	H2C bool
+	// DialTimeout 建立连接的超时，ReadTimeout/WriteTimeout 为读写上游的超时，如 "30s"
+	DialTimeout  string
+	ReadTimeout  string
+	WriteTimeout string
+}
+
+// Empty 没有任何传输设置时不渲染 transport 块
+func (t *Transport) Empty() bool {
+	return t == nil || *t == Transport{}
+}
+
+// validate 检查协议组合和超时
+func (t *Transport) validate(owner string) error {
+	if t.TLS && t.H2C {
+		return &OptionError{Owner: owner, Option: "transport", Value: "h2c", Reason: "h2c 为明文 HTTP/2，不能与 HTTPS 同时使用"}
+	}
+	if !t.TLS && (t.TLSServerName != "" || t.TLSInsecureSkipVerify) {
+		return &OptionError{Owner: owner, Option: "tls_server_name", Value: t.TLSServerName, Reason: "SNI 和跳过证书校验只适用于 HTTPS 上游"}
+	}
+	if t.TLSServerName != "" && strings.ContainsAny(t.TLSServerName, " \t\"{}/") {
+		return &OptionError{Owner: owner, Option: "tls_server_name", Value: t.TLSServerName, Reason: "应为主机名"}
+	}
+	for _, d := range []struct{ option, value string }{
+		{"dial_timeout", t.DialTimeout}, {"read_timeout", t.ReadTimeout}, {"write_timeout", t.WriteTimeout},
+	} {
+		if err := validateDuration(owner, d.option, d.value, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateStreaming 检查刷新间隔和 WebSocket 等长连接的超时设置
+func (p *ReverseProxy) validateStreaming(owner string) error {
+	if err := validateDuration(owner, "flush_interval", p.FlushInterval, true); err != nil {
+		return err
+	}
+	if err := validateDuration(owner, "stream_timeout", p.StreamTimeout, false); err != nil {
+		return err
+	}
+	return validateDuration(owner, "stream_close_delay", p.StreamCloseDelay, false)
+}
+
+// validateDuration 检查时长，allowNegative 时允许 -1（flush_interval 表示每次写入后立即刷新）
+func validateDuration(owner, option, value string, allowNegative bool) error {
+	if value == "" || (allowNegative && value == "-1") {
+		return nil
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		reason := "应为时长，如 5s、1m"
+		if allowNegative {
+			reason += "，-1 表示立即刷新"
+		}
+		return &OptionError{Owner: owner, Option: option, Value: value, Reason: reason}
+	}
+	return nil
+}
+
+// SplitUpstream 拆分上游地址中的协议，如 "https://api.example.com" -> ("https", "api.example.com:443")。
+// 没有协议时返回 http；Unix 套接字（unix/...）原样返回
+func SplitUpstream(target string) (scheme, address string, err error) {
+	target = strings.TrimSpace(target)
+	if strings.HasPrefix(target, "unix/") {
+		return "http", target, nil
+	}
+	scheme = "http"
+	if i := strings.Index(target, "://"); i >= 0 {
+		scheme, target = strings.ToLower(target[:i]), target[i+3:]
+		if !contains(UpstreamSchemes, scheme) {
+			return "", "", &OptionError{Owner: "上游地址", Option: "scheme", Value: scheme, Reason: "可选值: " + strings.Join(UpstreamSchemes, ", ")}
+		}
+	}
+	target = strings.TrimSuffix(target, "/")
+	if target == "" || strings.ContainsAny(target, "/?# \t") {
+		return "", "", &OptionError{Owner: "上游地址", Option: "upstream", Value: target, Reason: "应为 主机:端口，不能包含路径"}
+	}
+	if _, port, err := net.SplitHostPort(target); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", "", &OptionError{Owner: "上游地址", Option: "upstream", Value: target, Reason: "端口应为 1-65535"}
+		}
+		return scheme, target, nil
+	}
+	port := "80"
+	if scheme == "https" {
+		port = "443"
+	}
+	return scheme, net.JoinHostPort(strings.Trim(target, "[]"), port), nil
+}
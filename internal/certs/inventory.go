@@ -0,0 +1,219 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExpiryWarning 证书剩余有效期少于该值时标记为即将过期。
+// Caddy 在剩余 1/3 有效期（Let's Encrypt 约 30 天）时自动续期，低于 14 天通常说明续期失败
+const ExpiryWarning = 14 * 24 * time.Hour
+
+// ManagedCert 证书清单中的一项
+type ManagedCert struct {
+	Domain string `json:"domain"`
+	// Status: valid / expiring / expired / missing
+	Status string `json:"status"`
+	// Source: acme / internal / custom，缺少证书时为空
+	Source string `json:"source,omitempty"`
+	// IssuerKey Caddy 存储中的签发方目录，如 acme-v02.api.letsencrypt.org-directory
+	IssuerKey string    `json:"issuer_key,omitempty"`
+	Issuer    string    `json:"issuer,omitempty"`
+	SANs      []string  `json:"sans,omitempty"`
+	NotBefore time.Time `json:"not_before,omitempty"`
+	NotAfter  time.Time `json:"not_after,omitempty"`
+	DaysLeft  int       `json:"days_left"`
+	KeyType   string    `json:"key_type,omitempty"`
+	File      string    `json:"file,omitempty"`
+}
+
+// StorageDir 返回 Caddy 默认的数据目录，与 Caddy 的 AppDataDir 规则一致：
+// 优先 XDG_DATA_HOME，Windows 为 %AppData%\Caddy，macOS 为 ~/Library/Application Support/Caddy，
+// 其余系统为 ~/.local/share/caddy
+func StorageDir() string {
+	if base := os.Getenv("XDG_DATA_HOME"); base != "" {
+		return filepath.Join(base, "caddy")
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("AppData"); appData != "" {
+			return filepath.Join(appData, "Caddy")
+		}
+	case "darwin", "ios":
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, "Library", "Application Support", "Caddy")
+		}
+	default:
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "share", "caddy")
+		}
+	}
+	return "./caddy"
+}
+
+// Inventory 为每个域名查找覆盖它的证书（Caddy 存储中的证书和已上传的证书），
+// 有多张时取到期最晚的一张，并根据剩余有效期标记状态
+func Inventory(domains []string, warn time.Duration) ([]ManagedCert, error) {
+	stored, err := scanStorage(filepath.Join(StorageDir(), "certificates"))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	list := []ManagedCert{}
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" || seen[domain] {
+			continue
+		}
+		seen[domain] = true
+
+		candidates := stored
+		if Exists(domain) {
+			certFile, _ := Paths(domain)
+			if c, err := readCert(certFile, "custom", ""); err == nil {
+				candidates = append(append([]ManagedCert{}, stored...), *c)
+			}
+		}
+
+		host := certHost(domain)
+		var best *ManagedCert
+		for i := range candidates {
+			if covers(candidates[i].SANs, host) && (best == nil || candidates[i].NotAfter.After(best.NotAfter)) {
+				best = &candidates[i]
+			}
+		}
+		if best == nil {
+			list = append(list, ManagedCert{Domain: domain, Status: "missing"})
+			continue
+		}
+
+		item := *best
+		item.Domain = domain
+		item.DaysLeft = int(item.NotAfter.Sub(now).Hours() / 24)
+		item.Status = expiryStatus(item.NotAfter, now, warn)
+		list = append(list, item)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	return list, nil
+}
+
+// expiryStatus 根据到期时间返回 expired、expiring（剩余不足 warn）或 valid
+func expiryStatus(notAfter, now time.Time, warn time.Duration) string {
+	switch {
+	case now.After(notAfter):
+		return "expired"
+	case notAfter.Sub(now) < warn:
+		return "expiring"
+	}
+	return "valid"
+}
+
+// scanStorage 读取 Caddy 存储目录 certificates/<签发方>/<域名>/<域名>.crt 中的证书
+func scanStorage(dir string) ([]ManagedCert, error) {
+	issuers, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []ManagedCert
+	for _, issuer := range issuers {
+		if !issuer.IsDir() {
+			continue
+		}
+		source := "acme"
+		if issuer.Name() == "local" {
+			source = "internal"
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, issuer.Name(), "*", "*.crt"))
+		for _, file := range files {
+			if c, err := readCert(file, source, issuer.Name()); err == nil {
+				list = append(list, *c)
+			}
+		}
+	}
+	return list, nil
+}
+
+// readCert 解析 PEM 文件中的第一张证书
+func readCert(file, source, issuerKey string) (*ManagedCert, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s 不是 PEM 格式的证书", file)
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	sans := append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	if len(sans) == 0 && leaf.Subject.CommonName != "" {
+		sans = append(sans, leaf.Subject.CommonName)
+	}
+
+	issuer := leaf.Issuer.CommonName
+	if issuer == "" && len(leaf.Issuer.Organization) > 0 {
+		issuer = leaf.Issuer.Organization[0]
+	}
+	return &ManagedCert{
+		Source:    source,
+		IssuerKey: issuerKey,
+		Issuer:    issuer,
+		SANs:      sans,
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		KeyType:   keyType(leaf),
+		File:      file,
+	}, nil
+}
+
+// covers 证书的 SAN 是否覆盖域名，通配符只匹配一级子域名
+func covers(sans []string, domain string) bool {
+	for _, san := range sans {
+		san = strings.ToLower(san)
+		if san == domain {
+			return true
+		}
+		if strings.HasPrefix(san, "*.") && !strings.HasPrefix(domain, "*.") {
+			if i := strings.Index(domain, "."); i > 0 && domain[i:] == san[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keyType 返回证书公钥的类型和长度，如 "ECDSA P-256"、"RSA 2048"
+func keyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return cert.PublicKeyAlgorithm.String()
+}
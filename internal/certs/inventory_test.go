@@ -0,0 +1,160 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"caddy-manager/internal/config"
+)
+
+// newCert 生成自签名证书，返回解析后的证书和 PEM 内容
+func newCert(t *testing.T, key crypto.Signer, sans []string, notAfter time.Time) (*x509.Certificate, []byte) {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: sans[0]},
+		Issuer:       pkix.Name{CommonName: "Test CA"},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, san)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCovers(t *testing.T) {
+	cases := []struct {
+		sans   []string
+		domain string
+		want   bool
+	}{
+		{[]string{"example.com"}, "example.com", true},
+		{[]string{"Example.COM"}, "example.com", true},
+		{[]string{"example.com"}, "www.example.com", false},
+		{[]string{"*.example.com"}, "a.example.com", true},
+		{[]string{"*.example.com"}, "a.b.example.com", false},
+		{[]string{"*.example.com"}, "example.com", false},
+		{[]string{"*.example.com"}, "aexample.com", false},
+		{[]string{"*.example.com"}, "*.example.com", true},
+		{[]string{"*.example.com"}, "*.a.example.com", false},
+		{[]string{"example.com", "*.example.com"}, "example.com", true},
+		{[]string{"192.168.1.10"}, "192.168.1.10", true},
+		{nil, "example.com", false},
+	}
+	for _, tc := range cases {
+		if got := covers(tc.sans, tc.domain); got != tc.want {
+			t.Errorf("covers(%v, %q) = %v; 期望 %v", tc.sans, tc.domain, got, tc.want)
+		}
+	}
+}
+
+func TestExpiryStatus(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	cases := map[time.Duration]string{
+		60 * day:          "valid",
+		ExpiryWarning:     "valid",
+		ExpiryWarning - 1: "expiring",
+		day:               "expiring",
+		0:                 "expiring",
+		-time.Second:      "expired",
+		-30 * day:         "expired",
+	}
+	for left, want := range cases {
+		if got := expiryStatus(now.Add(left), now, ExpiryWarning); got != want {
+			t.Errorf("剩余 %v: expiryStatus = %q; 期望 %q", left, got, want)
+		}
+	}
+}
+
+func TestKeyType(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := map[string]crypto.Signer{
+		"ECDSA P-256": ecKey,
+		"ECDSA P-384": ec384Key,
+		"RSA 2048":    rsaKey,
+		"Ed25519":     edKey,
+	}
+	for want, key := range cases {
+		cert, _ := newCert(t, key, []string{"example.com"}, time.Now().Add(time.Hour))
+		if got := keyType(cert); got != want {
+			t.Errorf("keyType = %q; 期望 %q", got, want)
+		}
+	}
+}
+
+func TestInventory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	config.DataDir = t.TempDir()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now := time.Now()
+
+	write := func(issuer, name string, sans []string, notAfter time.Time) {
+		_, data := newCert(t, key, sans, notAfter)
+		dir := filepath.Join(StorageDir(), "certificates", issuer, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".crt"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("acme-v02.api.letsencrypt.org-directory", "wildcard_.example.com", []string{"*.example.com"}, now.Add(5*24*time.Hour))
+	write("acme-v02.api.letsencrypt.org-directory", "old.example.org", []string{"old.example.org"}, now.Add(-time.Hour))
+	write("acme-v02.api.letsencrypt.org-directory", "gone.example.net", []string{"gone.example.net"}, now.Add(-24*time.Hour))
+	write("local", "app.lan", []string{"app.lan"}, now.Add(7*24*time.Hour))
+	// 同一域名有多张证书时取到期最晚的一张
+	write("acme-v02.api.letsencrypt.org-directory", "new.example.org", []string{"old.example.org", "new.example.org"}, now.Add(60*24*time.Hour))
+
+	list, err := Inventory([]string{"A.example.com.", "a.b.example.com", "example.com", "old.example.org", "app.lan", "gone.example.net", "a.example.com"}, ExpiryWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct{ status, source string }{
+		"a.example.com":    {"expiring", "acme"},
+		"a.b.example.com":  {"missing", ""},
+		"app.lan":          {"expiring", "internal"},
+		"example.com":      {"missing", ""},
+		"gone.example.net": {"expired", "acme"},
+		"old.example.org":  {"valid", "acme"},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("Inventory 返回 %d 项; 期望 %d 项: %+v", len(list), len(want), list)
+	}
+	for _, c := range list {
+		w, ok := want[c.Domain]
+		if !ok || c.Status != w.status || c.Source != w.source {
+			t.Errorf("%s: status=%q source=%q; 期望 %+v", c.Domain, c.Status, c.Source, w)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"caddy-manager/internal/config"
+)
+
+// Info 已上传证书的信息
+type Info struct {
+	Domain    string    `json:"domain"`
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSNames  []string  `json:"dns_names"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	CertFile  string    `json:"cert_file"`
+	KeyFile   string    `json:"key_file"`
+}
+
+// CertError 证书或私钥校验失败
+type CertError struct {
+	Domain string `json:"domain"`
+	Reason string `json:"reason"`
+}
+
+func (e *CertError) Error() string {
+	return fmt.Sprintf("域名 %s 的证书无效: %s", e.Domain, e.Reason)
+}
+
+// Root 上传证书的存放目录
+func Root() string {
+	return filepath.Join(config.DataDir, "certs")
+}
+
+// Paths 返回域名对应的证书和私钥文件路径
+func Paths(domain string) (certFile, keyFile string) {
+	dir := filepath.Join(Root(), dirName(domain))
+	return filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+}
+
+// dirName 将域名转换为目录名，通配符 * 替换为 _wildcard，IPv6 地址中的冒号替换为 _
+func dirName(domain string) string {
+	name := strings.ReplaceAll(certHost(domain), "*", "_wildcard")
+	return strings.ReplaceAll(name, ":", "_")
+}
+
+// domainFromDir 由目录名还原域名
+func domainFromDir(name string) string {
+	if strings.Contains(name, "_") {
+		if ip := net.ParseIP(strings.ReplaceAll(name, "_", ":")); ip != nil {
+			return ip.String()
+		}
+	}
+	return strings.ReplaceAll(name, "_wildcard", "*")
+}
+
+// certHost 返回证书需要覆盖的主机名：去掉端口、末尾的点和 IPv6 地址的方括号，IP 地址转换为标准形式。
+// 同一主机名的不同端口使用同一张证书
+func certHost(domain string) string {
+	host := strings.ToLower(strings.TrimSpace(domain))
+	if h, port, err := net.SplitHostPort(host); err == nil && isPort(port) {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// isPort 判断是否为 1-65535 的端口号
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port > 0 && port <= 65535 && strconv.Itoa(port) == s
+}
+
+// Exists 域名是否已上传证书
+func Exists(domain string) bool {
+	certFile, keyFile := Paths(domain)
+	if _, err := os.Stat(certFile); err != nil {
+		return false
+	}
+	_, err := os.Stat(keyFile)
+	return err == nil
+}
+
+// Save 校验私钥与证书匹配且证书覆盖该域名后保存
+func Save(domain string, certPEM, keyPEM []byte) (*Info, error) {
+	domain, err := checkDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := parse(domain, certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(info.NotAfter) {
+		return nil, &CertError{Domain: domain, Reason: "证书已于 " + info.NotAfter.Format("2006-01-02") + " 过期"}
+	}
+
+	certFile, keyFile := Paths(domain)
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return nil, err
+	}
+
+	info.CertFile, info.KeyFile = certFile, keyFile
+	return info, nil
+}
+
+// Load 读取已上传证书的信息
+func Load(domain string) (*Info, error) {
+	certFile, keyFile := Paths(domain)
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := parse(certHost(domain), certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	info.CertFile, info.KeyFile = certFile, keyFile
+	return info, nil
+}
+
+// List 列出所有已上传的证书
+func List() ([]Info, error) {
+	entries, err := os.ReadDir(Root())
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Info{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		domain := domainFromDir(entry.Name())
+		info, err := Load(domain)
+		if err != nil {
+			// 损坏的证书也列出，便于在界面上删除或重新上传
+			list = append(list, Info{Domain: domain, Subject: err.Error()})
+			continue
+		}
+		list = append(list, *info)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	return list, nil
+}
+
+// Delete 删除域名的证书，只删除证书目录下该域名自己的子目录
+func Delete(domain string) error {
+	domain, err := checkDomain(domain)
+	if err != nil {
+		return err
+	}
+	certFile, _ := Paths(domain)
+	dir := filepath.Clean(filepath.Dir(certFile))
+	if rel, err := filepath.Rel(Root(), dir); err != nil || rel == "." || rel != filepath.Base(dir) {
+		return &CertError{Domain: domain, Reason: "域名格式不正确"}
+	}
+	return os.RemoveAll(dir)
+}
+
+// checkDomain 返回证书需要覆盖的主机名，可以带有端口或为 IP 地址；主机名用作目录名，
+// 除 IPv6 地址外不能为空或包含路径分隔符、冒号和 ..
+func checkDomain(domain string) (string, error) {
+	host := certHost(domain)
+	if net.ParseIP(host) == nil && (host == "" || strings.ContainsAny(host, `/\:`) || strings.Contains(host, "..")) {
+		return "", &CertError{Domain: strings.TrimSpace(domain), Reason: "域名格式不正确"}
+	}
+	return host, nil
+}
+
+// parse 解析证书链和私钥，检查两者是否匹配以及证书是否覆盖域名
+func parse(domain string, certPEM, keyPEM []byte) (*Info, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, &CertError{Domain: domain, Reason: "证书与私钥不匹配或格式错误: " + err.Error()}
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, &CertError{Domain: domain, Reason: "无法解析证书: " + err.Error()}
+	}
+
+	// 证书域名包括 IP 地址，IP 地址的站点需要证书的 IP SAN 覆盖
+	names := append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	// 通配符域名用一个子域名检查证书是否覆盖
+	host := domain
+	if strings.HasPrefix(host, "*.") {
+		host = "wildcard-check" + host[1:]
+	}
+	if err := leaf.VerifyHostname(host); err != nil {
+		return nil, &CertError{Domain: domain, Reason: fmt.Sprintf("证书不包含该域名（证书域名: %s）", strings.Join(names, ", "))}
+	}
+
+	return &Info{
+		Domain:    domain,
+		Subject:   leaf.Subject.CommonName,
+		Issuer:    leaf.Issuer.CommonName,
+		DNSNames:  names,
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+	}, nil
+}
+
+// IsInternalHost 判断是否为局域网或本机主机名，这类主机名无法通过公共 CA 申请证书
+func IsInternalHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range []string{".local", ".lan", ".internal", ".intranet", ".home.arpa", ".localhost", ".test"} {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
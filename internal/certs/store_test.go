@@ -0,0 +1,144 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"caddy-manager/internal/config"
+)
+
+func TestDelete(t *testing.T) {
+	config.DataDir = t.TempDir()
+	for _, dir := range []string{"example.com", "_wildcard.example.com"} {
+		if err := os.MkdirAll(filepath.Join(Root(), dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outside := filepath.Join(config.DataDir, "keep")
+	if err := os.WriteFile(outside, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 空域名、.. 等会解析到证书目录本身或之外的路径，不能删除
+	for _, domain := range []string{"", " ", ".", "..", "../..", "../keep", "a/../..", `..\..`, "c:", "example.com/.."} {
+		var certErr *CertError
+		if err := Delete(domain); !errors.As(err, &certErr) {
+			t.Errorf("Delete(%q) = %v; 期望 CertError", domain, err)
+		}
+	}
+	for _, path := range []string{Root(), outside, filepath.Join(Root(), "example.com")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s 被删除: %v", path, err)
+		}
+	}
+
+	for _, domain := range []string{"Example.COM.", "*.example.com"} {
+		if err := Delete(domain); err != nil {
+			t.Errorf("Delete(%q) = %v", domain, err)
+		}
+	}
+	if entries, _ := os.ReadDir(Root()); len(entries) != 0 {
+		t.Errorf("证书目录剩余 %d 项; 期望 0", len(entries))
+	}
+}
+
+// newKey 生成 ECDSA 私钥，返回私钥和 PEM 内容
+func newKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestSave(t *testing.T) {
+	config.DataDir = t.TempDir()
+	key, keyPEM := newKey(t)
+	_, otherKeyPEM := newKey(t)
+	valid := time.Now().Add(30 * 24 * time.Hour)
+
+	_, certPEM := newCert(t, key, []string{"example.com", "*.example.com"}, valid)
+	_, expiredPEM := newCert(t, key, []string{"example.com"}, time.Now().Add(-time.Hour))
+	_, ipPEM := newCert(t, key, []string{"2001:db8::1", "192.0.2.1"}, valid)
+
+	cases := []struct {
+		name    string
+		domain  string
+		cert    []byte
+		key     []byte
+		invalid string // CertError.Reason 应包含的内容，为空表示保存成功
+	}{
+		{"域名匹配", "Example.COM.", certPEM, keyPEM, ""},
+		{"通配符覆盖子域名", "www.example.com", certPEM, keyPEM, ""},
+		{"通配符域名", "*.example.com", certPEM, keyPEM, ""},
+		{"通配符只覆盖一级", "a.b.example.com", certPEM, keyPEM, "证书不包含该域名"},
+		{"域名不在证书中", "example.org", certPEM, keyPEM, "证书不包含该域名"},
+		{"私钥不匹配", "example.com", certPEM, otherKeyPEM, "证书与私钥不匹配"},
+		{"不是 PEM", "example.com", []byte("not a cert"), keyPEM, "证书与私钥不匹配"},
+		{"证书已过期", "example.com", expiredPEM, keyPEM, "过期"},
+		{"域名格式不正确", "../example.com", certPEM, keyPEM, "域名格式不正确"},
+		{"带端口的域名", "example.com:8443", certPEM, keyPEM, ""},
+		{"端口无效", "example.com:http", certPEM, keyPEM, "域名格式不正确"},
+		{"IPv6 地址", "[2001:DB8::1]:8443", ipPEM, keyPEM, ""},
+		{"IPv4 地址", "192.0.2.1", ipPEM, keyPEM, ""},
+		{"IP 不在证书中", "192.0.2.2", ipPEM, keyPEM, "证书不包含该域名"},
+	}
+	for _, tc := range cases {
+		info, err := Save(tc.domain, tc.cert, tc.key)
+		if tc.invalid == "" {
+			if err != nil {
+				t.Errorf("%s: Save(%q) = %v", tc.name, tc.domain, err)
+				continue
+			}
+			if !Exists(tc.domain) || info.NotAfter.Unix() != valid.Unix() {
+				t.Errorf("%s: 证书未保存或信息有误: %+v", tc.name, info)
+			}
+			continue
+		}
+		var certErr *CertError
+		if !errors.As(err, &certErr) || !strings.Contains(certErr.Reason, tc.invalid) {
+			t.Errorf("%s: Save(%q) = %v; 期望包含 %q 的 CertError", tc.name, tc.domain, err, tc.invalid)
+		}
+	}
+
+	if Exists("example.org") || Exists("a.b.example.com") {
+		t.Error("校验失败的证书不应保存")
+	}
+	if info, err := Load("*.example.com"); err != nil || len(info.DNSNames) != 2 {
+		t.Errorf("Load(*.example.com) = %+v, %v", info, err)
+	}
+
+	// 同一主机名的不同端口、IPv6 地址的不同写法使用同一张证书，目录名中不含冒号
+	certFile, _ := Paths("[2001:db8::1]")
+	if dir := filepath.Base(filepath.Dir(certFile)); strings.Contains(dir, ":") {
+		t.Errorf("IPv6 地址的证书目录 = %q; 不应包含冒号", dir)
+	}
+	if !Exists("example.com") || !Exists("2001:db8::1") {
+		t.Error("带端口或方括号保存的证书应按主机名查找")
+	}
+	list, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var domains []string
+	for _, info := range list {
+		domains = append(domains, info.Domain)
+	}
+	if got := strings.Join(domains, " "); got != "*.example.com 192.0.2.1 2001:db8::1 example.com www.example.com" {
+		t.Errorf("List() = %s", got)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 )
 
 var (
@@ -12,7 +13,11 @@ var (
 	CaddyBin     string
 	CaddyConfig  string
 	CaddyLogFile string
+	CaddyPIDFile string
 	DatabasePath string
+
+	// CaddyAdminAddr Caddy 管理 API 地址（Caddy 默认值）
+	CaddyAdminAddr = "localhost:2019"
 )
 
 func Init() error {
@@ -26,9 +31,10 @@ func Init() error {
 	// 设置数据目录
 	DataDir = filepath.Join(AppDir, "data")
 	CaddyDir = filepath.Join(DataDir, "caddy")
-	CaddyBin = filepath.Join(CaddyDir, "caddy.exe")
+	CaddyBin = filepath.Join(CaddyDir, CaddyBinName())
 	CaddyConfig = filepath.Join(CaddyDir, "Caddyfile")
 	CaddyLogFile = filepath.Join(CaddyDir, "caddy.log")
+	CaddyPIDFile = filepath.Join(CaddyDir, "caddy.pid")
 	DatabasePath = filepath.Join(DataDir, "caddy-manager.db")
 
 	// 创建必要的目录
@@ -41,3 +47,11 @@ func Init() error {
 
 	return nil
 }
+
+// CaddyBinName 返回当前平台下 Caddy 可执行文件名
+func CaddyBinName() string {
+	if runtime.GOOS == "windows" {
+		return "caddy.exe"
+	}
+	return "caddy"
+}
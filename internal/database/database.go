@@ -11,7 +11,8 @@ var db *sql.DB
 
 func Init() error {
 	var err error
-	db, err = sql.Open("sqlite", config.DatabasePath)
+	// 项目监控协程与请求会并发写入，等待锁释放而不是立即返回 SQLITE_BUSY
+	db, err = sql.Open("sqlite", config.DatabasePath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return err
 	}
@@ -69,6 +70,148 @@ func Init() error {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS dns_providers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		credentials TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS redirect_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		site_id INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		target TEXT NOT NULL,
+		status INTEGER DEFAULT 301,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS basic_auth_users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		path TEXT NOT NULL DEFAULT '',
+		username TEXT NOT NULL,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(owner_type, owner_id, path, username)
+	);
+
+	CREATE TABLE IF NOT EXISTS ip_access_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		path TEXT NOT NULL DEFAULT '',
+		allow TEXT NOT NULL DEFAULT '',
+		deny TEXT NOT NULL DEFAULT '',
+		status INTEGER NOT NULL DEFAULT 403,
+		body TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(owner_type, owner_id, path)
+	);
+
+	CREATE TABLE IF NOT EXISTS maintenance (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		enabled BOOLEAN DEFAULT 0,
+		starts_at TEXT NOT NULL DEFAULT '',
+		ends_at TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL DEFAULT '',
+		retry_after INTEGER NOT NULL DEFAULT 3600,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(owner_type, owner_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS header_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		direction TEXT NOT NULL,
+		operation TEXT NOT NULL DEFAULT 'set',
+		name TEXT NOT NULL,
+		value TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS upstream_transports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		host TEXT NOT NULL DEFAULT '',
+		scheme TEXT NOT NULL DEFAULT '',
+		tls_server_name TEXT NOT NULL DEFAULT '',
+		tls_skip_verify BOOLEAN DEFAULT 0,
+		dial_timeout TEXT NOT NULL DEFAULT '',
+		read_timeout TEXT NOT NULL DEFAULT '',
+		write_timeout TEXT NOT NULL DEFAULT '',
+		flush_interval TEXT NOT NULL DEFAULT '',
+		stream_timeout TEXT NOT NULL DEFAULT '',
+		stream_close_delay TEXT NOT NULL DEFAULT '',
+		host_header TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(owner_type, owner_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS php_installs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version TEXT NOT NULL,
+		path TEXT NOT NULL UNIQUE,
+		kind TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS php_pools (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		install_id INTEGER NOT NULL UNIQUE,
+		listen TEXT NOT NULL,
+		workers INTEGER NOT NULL DEFAULT 4,
+		ini TEXT NOT NULL DEFAULT '',
+		auto_start BOOLEAN DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS domains (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host TEXT NOT NULL,
+		path TEXT NOT NULL DEFAULT '',
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(host, path)
+	);
+
+	CREATE TABLE IF NOT EXISTS project_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project_id INTEGER NOT NULL,
+		instance INTEGER NOT NULL DEFAULT 0,
+		port INTEGER NOT NULL DEFAULT 0,
+		event TEXT NOT NULL,
+		pid INTEGER NOT NULL DEFAULT 0,
+		exit_code INTEGER,
+		signal TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS project_env (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL DEFAULT '',
+		UNIQUE(project_id, key)
+	);
+
+	CREATE TABLE IF NOT EXISTS secrets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		value TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
 	CREATE TABLE IF NOT EXISTS settings (
 		key TEXT PRIMARY KEY,
 		value TEXT,
@@ -87,6 +230,59 @@ func Init() error {
 	
 	// 添加 use_ipv4 列（如果不存在）- 兼容旧数据库
 	db.Exec("ALTER TABLE projects ADD COLUMN use_ipv4 BOOLEAN DEFAULT 1")
+	db.Exec("ALTER TABLE projects ADD COLUMN strip_path_prefix BOOLEAN DEFAULT 0")
+
+	// 多实例与负载均衡
+	db.Exec("ALTER TABLE projects ADD COLUMN instances INTEGER DEFAULT 1")
+	db.Exec("ALTER TABLE projects ADD COLUMN lb_policy TEXT DEFAULT ''")
+	db.Exec("ALTER TABLE projects ADD COLUMN lb_retries INTEGER DEFAULT 0")
+	db.Exec("ALTER TABLE projects ADD COLUMN lb_try_duration TEXT DEFAULT ''")
+	db.Exec("ALTER TABLE projects ADD COLUMN health_uri TEXT DEFAULT ''")
+	db.Exec("ALTER TABLE projects ADD COLUMN health_interval TEXT DEFAULT ''")
+	// 没有 health_uri 时 health_interval 不生效，清除旧数据中这样的设置，避免生成配置时校验失败
+	db.Exec("UPDATE projects SET health_interval = '' WHERE COALESCE(health_uri, '') = '' AND COALESCE(health_interval, '') != ''")
+
+	// HTTPS 模式: auto / internal / custom / off
+	db.Exec("ALTER TABLE sites ADD COLUMN tls_mode TEXT DEFAULT ''")
+	db.Exec("ALTER TABLE projects ADD COLUMN tls_mode TEXT DEFAULT ''")
+
+	// 站点和项目单独的 ACME 设置，留空时使用全局设置
+	db.Exec("ALTER TABLE sites ADD COLUMN ssl_email TEXT DEFAULT ''")
+	for _, table := range []string{"sites", "projects"} {
+		db.Exec("ALTER TABLE " + table + " ADD COLUMN acme_ca TEXT DEFAULT ''")
+		db.Exec("ALTER TABLE " + table + " ADD COLUMN acme_ca_url TEXT DEFAULT ''")
+		db.Exec("ALTER TABLE " + table + " ADD COLUMN acme_eab_key_id TEXT DEFAULT ''")
+		db.Exec("ALTER TABLE " + table + " ADD COLUMN acme_eab_hmac_key TEXT DEFAULT ''")
+		db.Exec("ALTER TABLE " + table + " ADD COLUMN acme_ca_root TEXT DEFAULT ''")
+		db.Exec("ALTER TABLE " + table + " ADD COLUMN dns_provider_id INTEGER DEFAULT 0")
+	}
+
+	// 规范域名和 HTTP 访问
+	db.Exec("ALTER TABLE sites ADD COLUMN canonical_host TEXT DEFAULT ''")
+	db.Exec("ALTER TABLE sites ADD COLUMN force_https BOOLEAN DEFAULT 1")
+
+	// 项目停止时显示"正在启动"页面
+	db.Exec("ALTER TABLE projects ADD COLUMN starting_page BOOLEAN DEFAULT 0")
+
+	// 单页应用和跳转站点的选项
+	db.Exec("ALTER TABLE sites ADD COLUMN precompressed BOOLEAN DEFAULT 1")
+	db.Exec("ALTER TABLE sites ADD COLUMN cache_hashed_assets BOOLEAN DEFAULT 1")
+	db.Exec("ALTER TABLE sites ADD COLUMN redirect_status INTEGER DEFAULT 301")
+	db.Exec("ALTER TABLE sites ADD COLUMN redirect_keep_path BOOLEAN DEFAULT 1")
+
+	// 项目的停止方式
+	db.Exec("ALTER TABLE projects ADD COLUMN stop_signal TEXT DEFAULT 'SIGTERM'")
+	db.Exec("ALTER TABLE projects ADD COLUMN stop_command TEXT DEFAULT ''")
+	db.Exec("ALTER TABLE projects ADD COLUMN stop_timeout INTEGER DEFAULT 10")
+	db.Exec("ALTER TABLE projects ADD COLUMN kill_process_group BOOLEAN DEFAULT 1")
+
+	// 项目的重启策略
+	db.Exec("ALTER TABLE projects ADD COLUMN restart_policy TEXT DEFAULT 'never'")
+	db.Exec("ALTER TABLE projects ADD COLUMN restart_max_retries INTEGER DEFAULT 5")
+	db.Exec("ALTER TABLE projects ADD COLUMN restart_window INTEGER DEFAULT 300")
+
+	// 项目的 .env 文件
+	db.Exec("ALTER TABLE projects ADD COLUMN env_file TEXT DEFAULT ''")
 	
 	return nil
 }
@@ -102,6 +298,41 @@ func GetDB() *sql.DB {
 	return db
 }
 
+// Querier 由 *sql.DB 和 *sql.Tx 实现，通过事务查询时可以读到尚未提交的修改
+type Querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// GetSetting 读取设置项，不存在时返回空字符串
+func GetSetting(key string) string {
+	return GetSettingFrom(db, key)
+}
+
+// GetSettingFrom 通过 q 读取设置项，不存在时返回空字符串
+func GetSettingFrom(q Querier, key string) string {
+	var value sql.NullString
+	q.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	return value.String
+}
+
+// Execer 由 *sql.DB 和 *sql.Tx 实现，通过事务写入时修改在提交后才生效
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SetSetting 写入设置项
+func SetSetting(key, value string) error {
+	return SetSettingTo(db, key, value)
+}
+
+// SetSettingTo 通过 ex 写入设置项
+func SetSettingTo(ex Execer, key, value string) error {
+	_, err := ex.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`, key, value)
+	return err
+}
+
 func IsFirstRun() bool {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
@@ -5,8 +5,9 @@ import (
 	"net"
 	"os/exec"
 	"strings"
-	"syscall"
 	"time"
+
+	"caddy-manager/internal/system"
 )
 
 type Issue struct {
@@ -37,12 +38,9 @@ func RunDiagnostics() *DiagnosticResult {
 		result.Issues = append(result.Issues, Issue{
 			Code:        "PRIV_001",
 			Severity:    "error",
-			Title:       "缺少管理员权限",
-			Description: "程序未以管理员身份运行，无法绑定 80 和 443 端口",
-			Solutions: []string{
-				"右键程序图标，选择'以管理员身份运行'",
-				"或在设置中点击'请求管理员权限'",
-			},
+			Title:       "缺少" + system.PrivilegeName + "权限",
+			Description: "程序未以" + system.PrivilegeName + "身份运行，无法绑定 80 和 443 端口",
+			Solutions:   system.PrivilegeSuggestions(),
 			AutoFix: false,
 		})
 		result.HasErrors = true
@@ -102,17 +100,29 @@ func RunDiagnostics() *DiagnosticResult {
 
 // CheckSSLIssues 检查 SSL 相关问题
 func CheckSSLIssues(domain string) []Issue {
-	issues := []Issue{}
+	_, issues := CheckSSL(domain, true)
+	return issues
+}
 
-	// 1. 先检查 SSL 证书是否已存在并有效
+// CheckSSL 检查 SSL 相关问题，同时返回 TLS 握手检测结果；
+// httpsRedirect 为 false 时站点有意同时提供 HTTP，不报告 HTTP 未跳转
+func CheckSSL(domain string, httpsRedirect bool) (*SSLStatus, []Issue) {
 	sslStatus := checkSSLCertificate(domain)
+	return sslStatus, sslIssues(domain, sslStatus, httpsRedirect)
+}
+
+// sslIssues 根据 TLS 检测结果给出问题；证书无效或无法握手时继续检查域名解析和端口
+func sslIssues(domain string, sslStatus *SSLStatus, httpsRedirect bool) []Issue {
+	issues := certificateIssues(domain, sslStatus, httpsRedirect)
+
+	// 1. 证书有效时只报告证书本身的提醒
 	if sslStatus.Valid {
-		// SSL 证书已有效，返回成功状态
 		issues = append(issues, Issue{
 			Code:        "SSL_OK",
 			Severity:    "info",
 			Title:       "SSL 证书正常",
-			Description: fmt.Sprintf("域名 %s 的 SSL 证书有效\n颁发者: %s\n有效期: %s", domain, sslStatus.Issuer, sslStatus.Expiry),
+			Description: fmt.Sprintf("域名 %s 的 SSL 证书有效\n颁发者: %s\n有效期至: %s（剩余 %d 天）\n协议: %s，加密套件: %s\nOCSP 装订: %s",
+				domain, sslStatus.Issuer, sslStatus.Expiry, sslStatus.DaysLeft, sslStatus.Protocol, sslStatus.CipherSuite, sslStatus.OCSPStatus),
 			Solutions: []string{
 				"SSL 已正常工作",
 			},
@@ -157,11 +167,11 @@ func CheckSSLIssues(domain string) []Issue {
 			Code:        "SSL_002",
 			Severity:    "info",
 			Title:       "检测到 Cloudflare CDN",
-			Description: fmt.Sprintf("域名解析到 Cloudflare IP: %s\n如使用 Cloudflare 代理，建议使用 Flexible SSL 模式", strings.Join(cloudflareIPs, ", ")),
+			Description: fmt.Sprintf("域名解析到 Cloudflare IP: %s\n代理后 HTTP 验证无法到达本服务器，建议使用 DNS 验证申请证书", strings.Join(cloudflareIPs, ", ")),
 			Solutions: []string{
-				"推荐: 使用 Cloudflare Flexible SSL 模式（Cloudflare 到用户为 HTTPS）",
-				"或: 使用 Full SSL 模式（需要在服务器上有自签名证书）",
-				"注意: 本工具无法为 Cloudflare 代理的域名自动申请证书",
+				"推荐: 在「DNS 服务商」中添加 Cloudflare API Token，并在站点或项目中选择 DNS 验证，之后可使用 Full (strict) SSL 模式",
+				"或: 使用 Cloudflare Flexible SSL 模式（Cloudflare 到用户为 HTTPS）",
+				"注意: DNS 验证需要包含 dns.providers.cloudflare 模块的 Caddy",
 			},
 			AutoFix: false,
 		})
@@ -218,10 +228,7 @@ func CheckSSLIssues(domain string) []Issue {
 // 辅助函数
 
 func checkAdminPrivileges() bool {
-	cmd := exec.Command("net", "session")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	err := cmd.Run()
-	return err == nil
+	return system.IsAdmin()
 }
 
 func checkPortOccupied(port int) (bool, string) {
@@ -229,7 +236,7 @@ func checkPortOccupied(port int) (bool, string) {
 	if err != nil {
 		// 端口被占用，尝试找到占用的进程
 		cmd := exec.Command("netstat", "-ano")
-		cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+		system.HideWindow(cmd)
 		output, err := cmd.Output()
 		if err == nil {
 			lines := strings.Split(string(output), "\n")
@@ -240,7 +247,7 @@ func checkPortOccupied(port int) (bool, string) {
 					if len(fields) > 4 {
 						pid := fields[len(fields)-1]
 						pidCmd := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %s", pid), "/FO", "CSV", "/NH")
-						pidCmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+						system.HideWindow(pidCmd)
 						pidOutput, _ := pidCmd.Output()
 						if len(pidOutput) > 0 {
 							parts := strings.Split(string(pidOutput), ",")
@@ -262,7 +269,7 @@ func checkPortOccupied(port int) (bool, string) {
 
 func checkFirewallRules() bool {
 	cmd := exec.Command("netsh", "advfirewall", "firewall", "show", "rule", "name=all")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	system.HideWindow(cmd)
 	output, err := cmd.Output()
 	if err != nil {
 		return false
@@ -292,34 +299,6 @@ func getLocalIPs() []string {
 	return ips
 }
 
-// SSLStatus SSL 证书状态
-type SSLStatus struct {
-	Valid  bool
-	Issuer string
-	Expiry string
-}
-
-// checkSSLCertificate 检查 SSL 证书是否有效
-func checkSSLCertificate(domain string) SSLStatus {
-	status := SSLStatus{Valid: false}
-	
-	// 尝试连接 HTTPS 端口
-	conn, err := net.DialTimeout("tcp", domain+":443", 5*time.Second)
-	if err != nil {
-		return status
-	}
-	defer conn.Close()
-	
-	// 这里可以添加更复杂的 TLS 检测
-	// 简单起见，如果能连接 443 端口就认为 SSL 可能有效
-	// 实际生产环境应该使用 crypto/tls 包进行详细检查
-	status.Valid = true
-	status.Issuer = "Unknown (需要TLS检查)"
-	status.Expiry = "Unknown"
-	
-	return status
-}
-
 // checkPortReachable 检查端口是否可达
 func checkPortReachable(host string, port int) bool {
 	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), 5*time.Second)
@@ -346,7 +325,7 @@ func AutoFix(issueCode string) error {
 
 func stopProcessOnPort(port int) error {
 	cmd := exec.Command("netstat", "-ano")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	system.HideWindow(cmd)
 	output, err := cmd.Output()
 	if err != nil {
 		return err
@@ -361,7 +340,7 @@ func stopProcessOnPort(port int) error {
 			if len(fields) > 4 {
 				pid := fields[len(fields)-1]
 				killCmd := exec.Command("taskkill", "/F", "/PID", pid)
-				killCmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+				system.HideWindow(killCmd)
 				return killCmd.Run()
 			}
 		}
@@ -373,13 +352,13 @@ func stopProcessOnPort(port int) error {
 func configureFirewall() error {
 	cmd1 := exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
 		"name=Caddy HTTP", "dir=in", "action=allow", "protocol=TCP", "localport=80")
-	cmd1.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	system.HideWindow(cmd1)
 	if err := cmd1.Run(); err != nil {
 		return err
 	}
 	
 	cmd2 := exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
 		"name=Caddy HTTPS", "dir=in", "action=allow", "protocol=TCP", "localport=443")
-	cmd2.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	system.HideWindow(cmd2)
 	return cmd2.Run()
 }
@@ -0,0 +1,290 @@
+package diagnostics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"caddy-manager/internal/certs"
+)
+
+// SSLStatus TLS 握手检测结果
+type SSLStatus struct {
+	// Reachable 443 端口能否完成 TLS 握手
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+
+	// Valid 证书链受系统信任、域名匹配且未过期
+	Valid       bool   `json:"valid"`
+	Protocol    string `json:"protocol,omitempty"`
+	CipherSuite string `json:"cipher_suite,omitempty"`
+
+	Subject   string    `json:"subject,omitempty"`
+	Issuer    string    `json:"issuer,omitempty"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	DaysLeft  int       `json:"days_left"`
+	Expiry    string    `json:"expiry,omitempty"`
+
+	Expired          bool   `json:"expired"`
+	SelfSigned       bool   `json:"self_signed"`
+	HostnameMismatch bool   `json:"hostname_mismatch"`
+	IncompleteChain  bool   `json:"incomplete_chain"`
+	Untrusted        bool   `json:"untrusted"`
+	VerifyError      string `json:"verify_error,omitempty"`
+
+	// OCSPStatus: none（未装订）/ good / revoked / unknown / invalid
+	OCSPStatus     string    `json:"ocsp_status"`
+	OCSPNextUpdate time.Time `json:"ocsp_next_update,omitempty"`
+
+	// HTTPStatus 访问 http:// 时的响应码，HTTPRedirect 为跳转地址
+	HTTPStatus       int    `json:"http_status"`
+	HTTPRedirect     string `json:"http_redirect,omitempty"`
+	RedirectsToHTTPS bool   `json:"redirects_to_https"`
+}
+
+// checkSSLCertificate 与域名的 443 端口进行 TLS 握手，检查协议、证书链、域名、有效期和 OCSP 装订，
+// 并检查 HTTP 是否跳转到 HTTPS
+func checkSSLCertificate(domain string) *SSLStatus {
+	// 规范化后的 IPv6 地址带有方括号，拼接端口前去掉
+	host := strings.TrimSuffix(strings.TrimPrefix(domain, "["), "]")
+	status := probeTLS(net.JoinHostPort(host, "443"), host)
+	if status.Reachable {
+		checkHTTPRedirect(host, status)
+	}
+	return status
+}
+
+// probeTLS 与 addr 进行 TLS 握手（SNI 为 domain），检查返回的证书
+func probeTLS(addr, domain string) *SSLStatus {
+	status := &SSLStatus{OCSPStatus: "none"}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	// 跳过内置校验以便取得证书，随后手动校验并区分具体问题
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName:         domain,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	state := conn.ConnectionState()
+	conn.Close()
+
+	status.Reachable = true
+	status.Protocol = tls.VersionName(state.Version)
+	status.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	if len(state.PeerCertificates) == 0 {
+		status.Error = "服务器未返回证书"
+		return status
+	}
+
+	leaf := state.PeerCertificates[0]
+	status.Subject = leaf.Subject.CommonName
+	status.Issuer = leaf.Issuer.CommonName
+	if status.Issuer == "" && len(leaf.Issuer.Organization) > 0 {
+		status.Issuer = leaf.Issuer.Organization[0]
+	}
+	status.DNSNames = leaf.DNSNames
+	status.NotBefore, status.NotAfter = leaf.NotBefore, leaf.NotAfter
+	status.Expiry = leaf.NotAfter.Format("2006-01-02 15:04")
+	status.DaysLeft = int(time.Until(leaf.NotAfter).Hours() / 24)
+	status.Expired = time.Now().After(leaf.NotAfter)
+	status.HostnameMismatch = leaf.VerifyHostname(domain) != nil
+	status.SelfSigned = isSelfSigned(leaf)
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates}); err != nil {
+		status.VerifyError = err.Error()
+		var unknown x509.UnknownAuthorityError
+		if errors.As(err, &unknown) && !status.SelfSigned {
+			// 服务器只发送了叶证书时，通常是缺少中间证书；否则为不受信任的 CA（如内部 CA）
+			if len(state.PeerCertificates) == 1 {
+				status.IncompleteChain = true
+			} else {
+				status.Untrusted = true
+			}
+		}
+	}
+	status.Valid = status.VerifyError == "" && !status.HostnameMismatch && !status.Expired
+
+	if len(state.OCSPResponse) > 0 {
+		status.OCSPStatus = "invalid"
+		if len(state.PeerCertificates) > 1 {
+			if resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, leaf, state.PeerCertificates[1]); err == nil {
+				status.OCSPNextUpdate = resp.NextUpdate
+				switch resp.Status {
+				case ocsp.Good:
+					status.OCSPStatus = "good"
+				case ocsp.Revoked:
+					status.OCSPStatus = "revoked"
+				default:
+					status.OCSPStatus = "unknown"
+				}
+			}
+		}
+	}
+	return status
+}
+
+// checkHTTPRedirect 访问 http://domain/，不跟随跳转，记录响应码和跳转地址
+func checkHTTPRedirect(domain string, status *SSLStatus) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(httpProbeURL(domain))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	status.HTTPStatus = resp.StatusCode
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		status.HTTPRedirect = resp.Header.Get("Location")
+		status.RedirectsToHTTPS = strings.HasPrefix(strings.ToLower(status.HTTPRedirect), "https://")
+	}
+}
+
+// httpProbeURL 返回访问 host 的 HTTP 地址，IPv6 地址加上方括号
+func httpProbeURL(host string) string {
+	return "http://" + net.JoinHostPort(host, "80") + "/"
+}
+
+// isSelfSigned 证书的签发者与主体相同且签名可用自身公钥验证
+func isSelfSigned(cert *x509.Certificate) bool {
+	if cert.Issuer.String() != cert.Subject.String() {
+		return false
+	}
+	// 叶证书通常没有 CA 标记，CheckSignatureFrom 会拒绝，直接校验签名
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// certificateIssues 将 TLS 检测结果转换为具体问题，httpsRedirect 为 false 表示站点有意同时提供 HTTP，不检查跳转
+func certificateIssues(domain string, s *SSLStatus, httpsRedirect bool) []Issue {
+	issues := []Issue{}
+	if !s.Reachable {
+		return issues
+	}
+
+	if s.Expired {
+		issues = append(issues, Issue{
+			Code:        "SSL_EXPIRED",
+			Severity:    "error",
+			Title:       "SSL 证书已过期",
+			Description: fmt.Sprintf("域名 %s 的证书已于 %s 过期（颁发者: %s）", domain, s.Expiry, s.Issuer),
+			Solutions: []string{
+				"查看 Caddy 日志中的证书续期错误",
+				"确认 80/443 端口可从外部访问，或改用 DNS 验证",
+				"使用上传证书时，请上传新的证书",
+			},
+		})
+	} else if s.DaysLeft < int(certs.ExpiryWarning.Hours()/24) {
+		issues = append(issues, Issue{
+			Code:        "SSL_EXPIRING",
+			Severity:    "warning",
+			Title:       "SSL 证书即将过期",
+			Description: fmt.Sprintf("域名 %s 的证书将于 %s 过期（剩余 %d 天），自动续期可能失败", domain, s.Expiry, s.DaysLeft),
+			Solutions: []string{
+				"查看 Caddy 日志中的证书续期错误",
+				"使用上传证书时，请及时上传新的证书",
+			},
+		})
+	}
+
+	if s.HostnameMismatch {
+		issues = append(issues, Issue{
+			Code:        "SSL_HOSTNAME_MISMATCH",
+			Severity:    "error",
+			Title:       "证书与域名不匹配",
+			Description: fmt.Sprintf("服务器返回的证书不包含域名 %s\n证书域名: %s", domain, strings.Join(s.DNSNames, ", ")),
+			Solutions: []string{
+				"确认域名已添加到站点或项目中，并已重新加载配置",
+				"使用上传证书时，确认证书包含该域名",
+				"如域名经过 CDN，检查 CDN 上配置的证书",
+			},
+		})
+	}
+
+	switch {
+	case s.SelfSigned:
+		issues = append(issues, Issue{
+			Code:        "SSL_SELF_SIGNED",
+			Severity:    "warning",
+			Title:       "使用自签名证书",
+			Description: fmt.Sprintf("域名 %s 使用自签名证书（%s），浏览器会提示不安全", domain, s.Subject),
+			Solutions: []string{
+				"公网域名请将 HTTPS 模式设为自动，由 Caddy 申请受信任的证书",
+				"局域网域名可将 Caddy 内部 CA 根证书安装到客户端",
+			},
+		})
+	case s.IncompleteChain:
+		issues = append(issues, Issue{
+			Code:        "SSL_INCOMPLETE_CHAIN",
+			Severity:    "error",
+			Title:       "证书链不完整",
+			Description: fmt.Sprintf("服务器只发送了叶证书，缺少中间证书（颁发者: %s），部分客户端无法验证", s.Issuer),
+			Solutions: []string{
+				"上传证书时使用包含中间证书的完整链（fullchain.pem）",
+			},
+		})
+	case s.Untrusted:
+		issues = append(issues, Issue{
+			Code:        "SSL_UNTRUSTED",
+			Severity:    "warning",
+			Title:       "证书颁发者不受信任",
+			Description: fmt.Sprintf("证书由 %s 签发，该 CA 不在系统信任列表中\n%s", s.Issuer, s.VerifyError),
+			Solutions: []string{
+				"使用 Caddy 内部 CA 时，将其根证书安装到客户端",
+				"公网域名请改用公共 CA 签发的证书",
+			},
+		})
+	}
+
+	if s.OCSPStatus == "revoked" {
+		issues = append(issues, Issue{
+			Code:        "SSL_REVOKED",
+			Severity:    "error",
+			Title:       "证书已被吊销",
+			Description: fmt.Sprintf("服务器装订的 OCSP 响应显示域名 %s 的证书已被吊销", domain),
+			Solutions: []string{
+				"删除 Caddy 存储中的该证书后重新加载，以重新申请",
+			},
+		})
+	}
+
+	if httpsRedirect && !s.RedirectsToHTTPS {
+		description := fmt.Sprintf("访问 http://%s/ 未跳转到 HTTPS", domain)
+		if s.HTTPStatus == 0 {
+			description = fmt.Sprintf("无法访问 http://%s/，80 端口可能未开放", domain)
+		} else if s.HTTPRedirect != "" {
+			description = fmt.Sprintf("访问 http://%s/ 跳转到 %s", domain, s.HTTPRedirect)
+		}
+		issues = append(issues, Issue{
+			Code:        "SSL_NO_REDIRECT",
+			Severity:    "info",
+			Title:       "HTTP 未跳转到 HTTPS",
+			Description: description,
+			Solutions: []string{
+				"Caddy 自动 HTTPS 会将 HTTP 跳转到 HTTPS，确认站点未关闭 HTTPS",
+				"确认 80 端口已开放并映射到本服务器",
+			},
+		})
+	}
+
+	return issues
+}
@@ -0,0 +1,245 @@
+package diagnostics
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var serial int64
+
+// newTestCert 生成证书，parent 为 nil 时自签名
+func newTestCert(t *testing.T, name string, notAfter time.Time, isCA bool, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if !isCA {
+		tmpl.DNSNames = []string{name}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// serveTLS 启动使用指定证书链的 HTTPS 服务，返回监听地址
+func serveTLS(t *testing.T, chain []*x509.Certificate, key crypto.Signer, staple []byte) string {
+	t.Helper()
+	cert := tls.Certificate{PrivateKey: key, OCSPStaple: staple}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().String()
+}
+
+func issueCodes(issues []Issue) []string {
+	codes := []string{}
+	for _, issue := range issues {
+		codes = append(codes, issue.Code)
+	}
+	return codes
+}
+
+func TestProbeTLS(t *testing.T) {
+	year := time.Now().Add(365 * 24 * time.Hour)
+	root, rootKey := newTestCert(t, "Test Root CA", year, true, nil, nil)
+	inter, interKey := newTestCert(t, "Test Intermediate CA", year, true, root, rootKey)
+
+	t.Run("self_signed", func(t *testing.T) {
+		leaf, key := newTestCert(t, "example.test", year, false, nil, nil)
+		s := probeTLS(serveTLS(t, []*x509.Certificate{leaf}, key, nil), "example.test")
+		if !s.Reachable || !s.SelfSigned || s.IncompleteChain || s.Untrusted || s.HostnameMismatch || s.Valid {
+			t.Errorf("自签名证书: %+v", s)
+		}
+		if got := issueCodes(certificateIssues("example.test", s, false)); !reflect.DeepEqual(got, []string{"SSL_SELF_SIGNED"}) {
+			t.Errorf("问题 = %v", got)
+		}
+	})
+
+	t.Run("incomplete_chain", func(t *testing.T) {
+		leaf, key := newTestCert(t, "example.test", year, false, inter, interKey)
+		s := probeTLS(serveTLS(t, []*x509.Certificate{leaf}, key, nil), "example.test")
+		if s.SelfSigned || !s.IncompleteChain || s.Untrusted || s.Valid {
+			t.Errorf("缺少中间证书: %+v", s)
+		}
+		if got := issueCodes(certificateIssues("example.test", s, false)); !reflect.DeepEqual(got, []string{"SSL_INCOMPLETE_CHAIN"}) {
+			t.Errorf("问题 = %v", got)
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		leaf, key := newTestCert(t, "example.test", year, false, inter, interKey)
+		s := probeTLS(serveTLS(t, []*x509.Certificate{leaf, inter}, key, nil), "example.test")
+		if s.SelfSigned || s.IncompleteChain || !s.Untrusted || s.Valid || s.VerifyError == "" {
+			t.Errorf("不受信任的 CA: %+v", s)
+		}
+		if got := issueCodes(certificateIssues("example.test", s, false)); !reflect.DeepEqual(got, []string{"SSL_UNTRUSTED"}) {
+			t.Errorf("问题 = %v", got)
+		}
+	})
+
+	t.Run("hostname_mismatch", func(t *testing.T) {
+		leaf, key := newTestCert(t, "example.test", year, false, inter, interKey)
+		s := probeTLS(serveTLS(t, []*x509.Certificate{leaf, inter}, key, nil), "other.test")
+		if !s.HostnameMismatch {
+			t.Errorf("域名不匹配: %+v", s)
+		}
+		if got := issueCodes(certificateIssues("other.test", s, false)); !reflect.DeepEqual(got, []string{"SSL_HOSTNAME_MISMATCH", "SSL_UNTRUSTED"}) {
+			t.Errorf("问题 = %v", got)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		leaf, key := newTestCert(t, "example.test", time.Now().Add(-time.Minute), false, inter, interKey)
+		s := probeTLS(serveTLS(t, []*x509.Certificate{leaf, inter}, key, nil), "example.test")
+		if !s.Expired {
+			t.Errorf("已过期: %+v", s)
+		}
+		if got := issueCodes(certificateIssues("example.test", s, false)); len(got) == 0 || got[0] != "SSL_EXPIRED" {
+			t.Errorf("问题 = %v", got)
+		}
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		leaf, key := newTestCert(t, "example.test", time.Now().Add(3*24*time.Hour), false, inter, interKey)
+		s := probeTLS(serveTLS(t, []*x509.Certificate{leaf, inter}, key, nil), "example.test")
+		if s.Expired || s.DaysLeft > 3 {
+			t.Errorf("即将过期: %+v", s)
+		}
+		if got := issueCodes(certificateIssues("example.test", s, false)); len(got) == 0 || got[0] != "SSL_EXPIRING" {
+			t.Errorf("问题 = %v", got)
+		}
+	})
+
+	ocspCases := map[string]struct {
+		status int
+		want   string
+	}{
+		"ocsp_good":    {ocsp.Good, "good"},
+		"ocsp_revoked": {ocsp.Revoked, "revoked"},
+		"ocsp_unknown": {ocsp.Unknown, "unknown"},
+	}
+	for name, tc := range ocspCases {
+		t.Run(name, func(t *testing.T) {
+			leaf, key := newTestCert(t, "example.test", year, false, inter, interKey)
+			staple, err := ocsp.CreateResponse(inter, inter, ocsp.Response{
+				Status:       tc.status,
+				SerialNumber: leaf.SerialNumber,
+				ThisUpdate:   time.Now().Add(-time.Hour),
+				NextUpdate:   time.Now().Add(24 * time.Hour),
+				RevokedAt:    time.Now().Add(-time.Hour),
+			}, interKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := probeTLS(serveTLS(t, []*x509.Certificate{leaf, inter}, key, staple), "example.test")
+			if s.OCSPStatus != tc.want || s.OCSPNextUpdate.IsZero() {
+				t.Errorf("OCSPStatus = %q, NextUpdate = %v; 期望 %q", s.OCSPStatus, s.OCSPNextUpdate, tc.want)
+			}
+			revoked := false
+			for _, code := range issueCodes(certificateIssues("example.test", s, false)) {
+				revoked = revoked || code == "SSL_REVOKED"
+			}
+			if revoked != (tc.want == "revoked") {
+				t.Errorf("SSL_REVOKED = %v", revoked)
+			}
+		})
+	}
+
+	t.Run("ocsp_invalid", func(t *testing.T) {
+		leaf, key := newTestCert(t, "example.test", year, false, inter, interKey)
+		s := probeTLS(serveTLS(t, []*x509.Certificate{leaf, inter}, key, []byte("not ocsp")), "example.test")
+		if s.OCSPStatus != "invalid" {
+			t.Errorf("OCSPStatus = %q; 期望 invalid", s.OCSPStatus)
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		s := probeTLS("127.0.0.1:1", "example.test")
+		if s.Reachable || s.Error == "" {
+			t.Errorf("无法连接: %+v", s)
+		}
+	})
+}
+
+func TestCertificateIssues(t *testing.T) {
+	valid := SSLStatus{Reachable: true, Valid: true, DaysLeft: 60, OCSPStatus: "none"}
+	cases := []struct {
+		name          string
+		status        SSLStatus
+		httpsRedirect bool
+		want          []string
+	}{
+		{"unreachable", SSLStatus{}, true, []string{}},
+		{"valid_with_redirect", SSLStatus{Reachable: true, Valid: true, DaysLeft: 60, RedirectsToHTTPS: true}, true, []string{}},
+		{"no_redirect", valid, true, []string{"SSL_NO_REDIRECT"}},
+		{"http_allowed", valid, false, []string{}},
+		{"self_signed_over_untrusted", SSLStatus{Reachable: true, DaysLeft: 60, SelfSigned: true, Untrusted: true}, false, []string{"SSL_SELF_SIGNED"}},
+		{"incomplete_chain", SSLStatus{Reachable: true, DaysLeft: 60, IncompleteChain: true}, false, []string{"SSL_INCOMPLETE_CHAIN"}},
+		{"expired_not_expiring", SSLStatus{Reachable: true, Expired: true, DaysLeft: -3}, false, []string{"SSL_EXPIRED"}},
+		{"expiring", SSLStatus{Reachable: true, DaysLeft: 5}, false, []string{"SSL_EXPIRING"}},
+		{"revoked", SSLStatus{Reachable: true, DaysLeft: 60, OCSPStatus: "revoked"}, false, []string{"SSL_REVOKED"}},
+		{"mismatch", SSLStatus{Reachable: true, DaysLeft: 60, HostnameMismatch: true}, true, []string{"SSL_HOSTNAME_MISMATCH", "SSL_NO_REDIRECT"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := issueCodes(certificateIssues("example.test", &tc.status, tc.httpsRedirect)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("问题 = %v; 期望 %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTTPProbeURL(t *testing.T) {
+	for host, want := range map[string]string{
+		"example.com": "http://example.com:80/",
+		"192.0.2.1":   "http://192.0.2.1:80/",
+		"2001:db8::1": "http://[2001:db8::1]:80/",
+	} {
+		got := httpProbeURL(host)
+		if got != want {
+			t.Errorf("httpProbeURL(%q) = %q; 期望 %q", host, got, want)
+		}
+		if _, err := url.Parse(got); err != nil {
+			t.Errorf("httpProbeURL(%q) 不是有效的地址: %v", host, err)
+		}
+	}
+}
@@ -1,5 +1,15 @@
 package models
 
+// ACMESettings 站点或项目单独的证书申请设置，留空时使用全局设置
+type ACMESettings struct {
+	ACMECA         string `json:"acme_ca"` // production / staging / zerossl / custom
+	ACMECAURL      string `json:"acme_ca_url"`
+	ACMEEABKeyID   string `json:"acme_eab_key_id"`
+	ACMEEABHMACKey string `json:"acme_eab_hmac_key"`
+	ACMECARoot     string `json:"acme_ca_root"`
+	DNSProviderID  int    `json:"dns_provider_id"` // 使用 DNS 验证申请证书，0 表示使用 HTTP 验证
+}
+
 type Site struct {
 	ID          int    `json:"id"`
 	Domain      string `json:"domain"`
@@ -8,6 +18,28 @@ type Site struct {
 	SSLEnabled  bool   `json:"ssl_enabled"`
 	Environment string `json:"environment"`
 	PHPVersion  string `json:"php_version"`
+	TLSMode     string `json:"tls_mode"`
+	SSLEmail    string `json:"ssl_email"`
+	// CanonicalHost 规范域名: www / apex，另一种写法跳转到规范域名；为空不跳转
+	CanonicalHost string `json:"canonical_host"`
+	// ForceHTTPS 为 false 时同时提供 HTTP 访问，不跳转到 HTTPS
+	ForceHTTPS bool `json:"force_https"`
+	// Precompressed 单页应用优先返回预压缩文件，CacheHashedAssets 长期缓存带哈希的资源
+	Precompressed     bool `json:"precompressed"`
+	CacheHashedAssets bool `json:"cache_hashed_assets"`
+	// RedirectStatus 跳转站点的状态码，RedirectKeepPath 跳转时保留请求路径和参数；跳转目标为 Target
+	RedirectStatus   int  `json:"redirect_status"`
+	RedirectKeepPath bool `json:"redirect_keep_path"`
+	ACMESettings
+}
+
+// RedirectRule 站点的跳转规则
+type RedirectRule struct {
+	ID     int    `json:"id"`
+	SiteID int    `json:"site_id"`
+	Source string `json:"source"` // 源路径，以 * 结尾时捕获其余部分
+	Target string `json:"target"` // 目标路径或地址，可用 * 引用捕获的部分
+	Status int    `json:"status"` // 301 / 302 / 307 / 308
 }
 
 type Project struct {
@@ -24,9 +56,188 @@ type Project struct {
 	SSLEnabled       bool   `json:"ssl_enabled"`
 	SSLEmail         string `json:"ssl_email"`
 	ReverseProxyPath string `json:"reverse_proxy_path"`
+	StripPathPrefix  bool   `json:"strip_path_prefix"`
 	ExtraHeaders     string `json:"extra_headers"`
 	Description      string `json:"description"`
 	UseIPv4          bool   `json:"use_ipv4"`
+	Instances        int    `json:"instances"`
+	LBPolicy         string `json:"lb_policy"`
+	LBRetries        int    `json:"lb_retries"`
+	LBTryDuration    string `json:"lb_try_duration"`
+	HealthURI        string `json:"health_uri"`
+	HealthInterval   string `json:"health_interval"`
+	TLSMode          string `json:"tls_mode"`
+	ACMESettings
+}
+
+// StopSettings 项目的停止方式：先发送 SIGTERM/SIGINT 或运行停止命令，Timeout 秒内未退出则强制结束。
+// KillGroup 时信号和强制结束作用于整个进程组，包括 npm、python 等包装器启动的子进程
+type StopSettings struct {
+	ProjectID int    `json:"project_id"`
+	Signal    string `json:"signal"`  // SIGTERM / SIGINT / command
+	Command   string `json:"command"` // Signal 为 command 时运行，环境变量 PORT、MAIN_PID 为实例的端口和进程号
+	Timeout   int    `json:"timeout"`
+	KillGroup bool   `json:"kill_group"`
+}
+
+// RestartPolicy 项目进程退出后的重启策略：never 不重启，on-failure 仅在非零退出码或被信号结束时重启，always 总是重启。
+// Window 秒内最多重启 MaxRetries 次，超过后不再重启
+type RestartPolicy struct {
+	ProjectID  int    `json:"project_id"`
+	Policy     string `json:"policy"`
+	MaxRetries int    `json:"max_retries"`
+	Window     int    `json:"window"`
+}
+
+// ProjectEvent 项目实例的启动、退出和重启记录
+type ProjectEvent struct {
+	ID         int    `json:"id"`
+	ProjectID  int    `json:"project_id"`
+	Instance   int    `json:"instance"`
+	Port       int    `json:"port"`
+	Event      string `json:"event"` // start / exit / restart / give_up / start_failed
+	PID        int    `json:"pid,omitempty"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	Signal     string `json:"signal,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"` // 退出时为本次运行时长
+	Reason     string `json:"reason"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// ProjectEnvVar 项目的一个环境变量，值中可以用 ${PORT}、${其他变量} 和 ${secret:NAME} 引用
+type ProjectEnvVar struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Literal .env 文件中用单引号括起的值，原样使用，不展开 ${...}
+	Literal bool `json:"-"`
+}
+
+// ProjectEnv 项目的环境变量和 .env 文件（相对于项目目录），项目中的变量优先于文件中的同名变量
+type ProjectEnv struct {
+	ProjectID int             `json:"project_id"`
+	EnvFile   string          `json:"env_file"`
+	Vars      []ProjectEnvVar `json:"vars"`
+}
+
+// EnvEntry 实例启动时实际使用的环境变量，Source 为 .env / project / manager，
+// 引用了密钥的值在预览中以 Masked 标记并隐藏
+type EnvEntry struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Masked bool   `json:"masked"`
+}
+
+// Secret 加密保存的密钥，列表中不返回值
+type Secret struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// DNSProvider DNS 验证使用的服务商凭据
+type DNSProvider struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Provider    string            `json:"provider"` // cloudflare / alidns / dnspod / rfc2136
+	Credentials map[string]string `json:"credentials"`
+	CreatedAt   string            `json:"created_at"`
+}
+
+// BasicAuthUser 站点或项目的 HTTP 基本认证用户，只保存 bcrypt 哈希
+type BasicAuthUser struct {
+	ID        int    `json:"id"`
+	OwnerType string `json:"owner_type"` // site / project
+	OwnerID   int    `json:"owner_id"`
+	Path      string `json:"path"` // 受保护的路径，为空表示整个站点或项目
+	Username  string `json:"username"`
+	CreatedAt string `json:"created_at"`
+}
+
+// IPAccessRule 站点或项目的 IP 访问规则，Allow/Deny 为 IP 或 CIDR 列表
+type IPAccessRule struct {
+	ID        int      `json:"id"`
+	OwnerType string   `json:"owner_type"` // site / project
+	OwnerID   int      `json:"owner_id"`
+	Path      string   `json:"path"` // 限制的路径，为空表示整个站点或项目
+	Allow     []string `json:"allow"`
+	Deny      []string `json:"deny"`
+	Status    int      `json:"status"` // 被拒绝时的响应码，默认 403
+	Body      string   `json:"body"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// HeaderRule 站点或项目的请求头/响应头规则
+type HeaderRule struct {
+	ID        int    `json:"id"`
+	OwnerType string `json:"owner_type"` // site / project
+	OwnerID   int    `json:"owner_id"`
+	Direction string `json:"direction"` // request（发往上游）/ response（返回客户端）
+	Operation string `json:"operation"` // set / add / delete
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Maintenance 站点或项目的维护模式设置，StartsAt/EndsAt 为 RFC 3339 时间，为空表示不限
+type Maintenance struct {
+	OwnerType  string `json:"owner_type"` // site / project
+	OwnerID    int    `json:"owner_id"`
+	Enabled    bool   `json:"enabled"`
+	StartsAt   string `json:"starts_at"`
+	EndsAt     string `json:"ends_at"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after"` // 未设置结束时间时的 Retry-After 秒数
+}
+
+// UpstreamTransport 站点或项目连接上游的传输设置，时长如 "30s"，为空表示使用 Caddy 默认值
+type UpstreamTransport struct {
+	OwnerType        string `json:"owner_type"` // site / project
+	OwnerID          int    `json:"owner_id"`
+	Host             string `json:"host"`   // 项目的上游主机，为空表示本机
+	Scheme           string `json:"scheme"` // http / https / h2c，为空时按上游地址中的协议
+	TLSServerName    string `json:"tls_server_name"`
+	TLSSkipVerify    bool   `json:"tls_skip_verify"`
+	DialTimeout      string `json:"dial_timeout"`
+	ReadTimeout      string `json:"read_timeout"`
+	WriteTimeout     string `json:"write_timeout"`
+	FlushInterval    string `json:"flush_interval"` // -1 表示立即刷新（SSE）
+	StreamTimeout    string `json:"stream_timeout"`
+	StreamCloseDelay string `json:"stream_close_delay"`
+	HostHeader       string `json:"host_header"` // 为空保留客户端的 Host，upstream 表示使用上游地址，其他为固定主机名
+}
+
+// PHPInstall 登记的 PHP 安装，Version 为主次版本号（如 8.2），由可执行文件的 -v 输出检测
+type PHPInstall struct {
+	ID        int    `json:"id"`
+	Version   string `json:"version"`
+	Path      string `json:"path"`
+	Kind      string `json:"kind"` // cgi（php-cgi）/ fpm（php-fpm）
+	CreatedAt string `json:"created_at"`
+}
+
+// PHPPool 一个 PHP 安装的 FastCGI 进程池，版本相同的 PHP 站点都转发到该进程池
+type PHPPool struct {
+	ID        int    `json:"id"`
+	InstallID int    `json:"install_id"`
+	Listen    string `json:"listen"`  // 端口号或 Unix 套接字路径
+	Workers   int    `json:"workers"` // 工作进程数
+	INI       string `json:"ini"`     // php.ini 覆盖，每行一个 key=value
+	AutoStart bool   `json:"auto_start"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Domain 站点或项目使用的一个主机名，Host 为规范形式（小写、punycode），Path 为项目的反向代理路径。
+// 同一主机名和路径在整个系统中只能属于一个站点或项目
+type Domain struct {
+	ID        int    `json:"id"`
+	Host      string `json:"host"`
+	Display   string `json:"display"` // 国际化域名还原为原文，如 例子.中国
+	Path      string `json:"path"`
+	OwnerType string `json:"owner_type"` // site / project
+	OwnerID   int    `json:"owner_id"`
+	Owner     string `json:"owner"`
+	CreatedAt string `json:"created_at"`
 }
 
 type Task struct {
@@ -2,9 +2,7 @@ package system
 
 import (
 	"runtime"
-	"syscall"
 	"time"
-	"unsafe"
 )
 
 // MemoryInfo 内存信息
@@ -39,104 +37,6 @@ type SystemStats struct {
 	Goroutines int        `json:"goroutines"`
 }
 
-var (
-	kernel32                = syscall.NewLazyDLL("kernel32.dll")
-	procGetDiskFreeSpaceEx  = kernel32.NewProc("GetDiskFreeSpaceExW")
-	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
-	
-	lastIdleTime   uint64
-	lastKernelTime uint64
-	lastUserTime   uint64
-	lastUpdateTime time.Time
-)
-
-type memoryStatusEx struct {
-	dwLength                uint32
-	dwMemoryLoad            uint32
-	ullTotalPhys            uint64
-	ullAvailPhys            uint64
-	ullTotalPageFile        uint64
-	ullAvailPageFile        uint64
-	ullTotalVirtual         uint64
-	ullAvailVirtual         uint64
-	ullAvailExtendedVirtual uint64
-}
-
-// GetMemoryInfo 获取内存信息
-func GetMemoryInfo() MemoryInfo {
-	var memInfo memoryStatusEx
-	memInfo.dwLength = uint32(unsafe.Sizeof(memInfo))
-	
-	ret, _, _ := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&memInfo)))
-	
-	if ret == 0 {
-		// 如果调用失败，返回基本信息
-		var m runtime.MemStats
-		runtime.ReadMemStats(&m)
-		return MemoryInfo{
-			TotalMB:     0,
-			UsedMB:      m.Alloc / 1024 / 1024,
-			FreeMB:      0,
-			UsedPercent: 0,
-		}
-	}
-	
-	totalMB := memInfo.ullTotalPhys / 1024 / 1024
-	availMB := memInfo.ullAvailPhys / 1024 / 1024
-	usedMB := totalMB - availMB
-	usedPercent := float64(usedMB) / float64(totalMB) * 100
-	
-	return MemoryInfo{
-		TotalMB:     totalMB,
-		UsedMB:      usedMB,
-		FreeMB:      availMB,
-		UsedPercent: usedPercent,
-	}
-}
-
-// GetDiskInfo 获取磁盘信息
-func GetDiskInfo() []DiskInfo {
-	drives := []string{"C:", "D:", "E:", "F:", "G:", "H:"}
-	var disks []DiskInfo
-	
-	for _, drive := range drives {
-		var freeBytesAvailable uint64
-		var totalNumberOfBytes uint64
-		var totalNumberOfFreeBytes uint64
-		
-		drivePath, _ := syscall.UTF16PtrFromString(drive + "\\")
-		ret, _, _ := procGetDiskFreeSpaceEx.Call(
-			uintptr(unsafe.Pointer(drivePath)),
-			uintptr(unsafe.Pointer(&freeBytesAvailable)),
-			uintptr(unsafe.Pointer(&totalNumberOfBytes)),
-			uintptr(unsafe.Pointer(&totalNumberOfFreeBytes)),
-		)
-		
-		if ret == 0 {
-			continue // 驱动器不存在
-		}
-		
-		if totalNumberOfBytes == 0 {
-			continue
-		}
-		
-		totalGB := totalNumberOfBytes / 1024 / 1024 / 1024
-		freeGB := totalNumberOfFreeBytes / 1024 / 1024 / 1024
-		usedGB := totalGB - freeGB
-		usedPercent := float64(usedGB) / float64(totalGB) * 100
-		
-		disks = append(disks, DiskInfo{
-			Drive:       drive,
-			TotalGB:     totalGB,
-			UsedGB:      usedGB,
-			FreeGB:      freeGB,
-			UsedPercent: usedPercent,
-		})
-	}
-	
-	return disks
-}
-
 // GetCPUPercent 获取 CPU 使用率
 func GetCPUPercent() float64 {
 	// 使用 runtime 包获取 CPU 核心数
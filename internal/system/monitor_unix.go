@@ -0,0 +1,78 @@
+//go:build !windows
+
+package system
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// GetMemoryInfo 获取内存信息（读取 /proc/meminfo）
+func GetMemoryInfo() MemoryInfo {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		// 非 Linux 系统没有 /proc，返回基本信息
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return MemoryInfo{
+			UsedMB: m.Alloc / 1024 / 1024,
+		}
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式: MemTotal:       16314092 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = kb
+	}
+
+	totalMB := values["MemTotal"] / 1024
+	availMB := values["MemAvailable"] / 1024
+	if totalMB == 0 {
+		return MemoryInfo{}
+	}
+	usedMB := totalMB - availMB
+
+	return MemoryInfo{
+		TotalMB:     totalMB,
+		UsedMB:      usedMB,
+		FreeMB:      availMB,
+		UsedPercent: float64(usedMB) / float64(totalMB) * 100,
+	}
+}
+
+// GetDiskInfo 获取磁盘信息（根分区）
+func GetDiskInfo() []DiskInfo {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs("/", &stat); err != nil {
+		return nil
+	}
+
+	totalGB := stat.Blocks * uint64(stat.Bsize) / 1024 / 1024 / 1024
+	freeGB := stat.Bavail * uint64(stat.Bsize) / 1024 / 1024 / 1024
+	if totalGB == 0 {
+		return nil
+	}
+	usedGB := totalGB - freeGB
+
+	return []DiskInfo{{
+		Drive:       "/",
+		TotalGB:     totalGB,
+		UsedGB:      usedGB,
+		FreeGB:      freeGB,
+		UsedPercent: float64(usedGB) / float64(totalGB) * 100,
+	}}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package system
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// PrivilegeName 绑定低端口所需权限的名称
+const PrivilegeName = "root"
+
+// IsAdmin 检查是否以 root 权限运行
+func IsAdmin() bool {
+	return os.Geteuid() == 0
+}
+
+// CanBindPort 检查是否有权限监听端口，只有 1 到 1023 的端口需要 root 权限
+func CanBindPort(port int) bool {
+	return port <= 0 || port >= 1024 || IsAdmin()
+}
+
+// PrivilegeSuggestions 获取 root 权限或避免使用低端口的操作建议
+func PrivilegeSuggestions() []string {
+	suggestions := []string{"使用 sudo 运行: sudo ./caddy-manager"}
+	if runtime.GOOS == "linux" {
+		suggestions = append(suggestions, "或以 systemd 服务运行，并设置 AmbientCapabilities=CAP_NET_BIND_SERVICE")
+	}
+	return append(suggestions, "或改用 1024 以上的端口")
+}
+
+// HideWindow 非 Windows 平台无控制台窗口，无需处理
+func HideWindow(cmd *exec.Cmd) {}
@@ -0,0 +1,35 @@
+package system
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// PrivilegeName 绑定端口和配置防火墙所需权限的名称
+const PrivilegeName = "管理员"
+
+// IsAdmin 检查是否以管理员权限运行
+func IsAdmin() bool {
+	cmd := exec.Command("net", "session")
+	HideWindow(cmd)
+	err := cmd.Run()
+	return err == nil
+}
+
+// CanBindPort 检查是否有权限监听端口，Windows 上启动项目和绑定端口需要管理员权限
+func CanBindPort(port int) bool {
+	return IsAdmin()
+}
+
+// PrivilegeSuggestions 以管理员身份运行的操作建议
+func PrivilegeSuggestions() []string {
+	return []string{
+		"右键点击程序图标选择'以管理员身份运行'",
+		"或在 PowerShell 中以管理员身份运行: .\\caddy-manager.exe",
+	}
+}
+
+// HideWindow 启动子进程时隐藏控制台窗口
+func HideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+}
@@ -23,6 +23,8 @@ import (
 func main() {
 	port := flag.Int("port", 8989, "Web UI 端口")
 	noTray := flag.Bool("no-tray", false, "禁用系统托盘")
+	importFile := flag.String("import", "", "导入现有的 Caddyfile 或 caddy adapt 输出的 JSON 后退出")
+	dryRun := flag.Bool("dry-run", false, "配合 -import 使用，只显示导入结果不写入数据库")
 	flag.Parse()
 
 	// 初始化配置
@@ -35,10 +37,23 @@ func main() {
 		log.Fatalf("数据库初始化失败: %v", err)
 	}
 	defer database.Close()
+
+	// 旧版本的域名保存在 sites.domain 和 projects.domains 中，迁移到 domains 表
+	api.MigrateDomains()
+	// 旧版本以明文保存的 DNS 服务商凭据改为加密保存
+	api.EncryptDNSCredentials()
+
+	if *importFile != "" {
+		runImport(*importFile, *dryRun)
+		return
+	}
 	
 	// 检查管理员权限
 	checkAdminPrivileges()
 
+	// Caddyfile 中的 DNS 凭据只写占位符，实际值通过环境变量传给 Caddy
+	api.LoadCaddyEnv()
+
 	// 检查并下载 Caddy
 	if err := caddy.CheckAndDownload(); err != nil {
 		log.Printf("⚠️  Caddy 自动下载失败: %v", err)
@@ -48,8 +63,12 @@ func main() {
 		go caddy.AutoStart()
 	}
 	
-	// 自动启动设置为自动启动的项目
+	// 自动启动设置为自动启动的项目和 PHP 进程池
 	go autoStartProjects()
+	go api.StartPHPPools()
+
+	// 在维护时间窗口开始和结束时重新生成配置
+	go api.RunMaintenanceScheduler()
 
 	// 检查是否首次运行
 	if database.IsFirstRun() {
@@ -76,12 +95,58 @@ func main() {
 	mux.HandleFunc("/api/sites/add", auth.AuthMiddleware(api.AddSiteHandler))
 	mux.HandleFunc("/api/sites/edit", auth.AuthMiddleware(api.EditSiteHandler))
 	mux.HandleFunc("/api/sites/delete", auth.AuthMiddleware(api.DeleteSiteHandler))
+	mux.HandleFunc("/api/redirects", auth.AuthMiddleware(api.RedirectsHandler))
+	mux.HandleFunc("/api/redirects/save", auth.AuthMiddleware(api.SaveRedirectHandler))
+	mux.HandleFunc("/api/redirects/delete", auth.AuthMiddleware(api.DeleteRedirectHandler))
+	mux.HandleFunc("/api/basic-auth", auth.AuthMiddleware(api.BasicAuthUsersHandler))
+	mux.HandleFunc("/api/basic-auth/save", auth.AuthMiddleware(api.SaveBasicAuthUserHandler))
+	mux.HandleFunc("/api/basic-auth/delete", auth.AuthMiddleware(api.DeleteBasicAuthUserHandler))
+	mux.HandleFunc("/api/ip-access", auth.AuthMiddleware(api.IPAccessRulesHandler))
+	mux.HandleFunc("/api/ip-access/save", auth.AuthMiddleware(api.SaveIPAccessRuleHandler))
+	mux.HandleFunc("/api/ip-access/delete", auth.AuthMiddleware(api.DeleteIPAccessRuleHandler))
+	mux.HandleFunc("/api/headers", auth.AuthMiddleware(api.HeaderRulesHandler))
+	mux.HandleFunc("/api/headers/save", auth.AuthMiddleware(api.SaveHeaderRuleHandler))
+	mux.HandleFunc("/api/headers/delete", auth.AuthMiddleware(api.DeleteHeaderRuleHandler))
+	mux.HandleFunc("/api/headers/presets", auth.AuthMiddleware(api.HeaderPresetsHandler))
+	mux.HandleFunc("/api/headers/apply-preset", auth.AuthMiddleware(api.ApplyHeaderPresetHandler))
+	mux.HandleFunc("/api/maintenance", auth.AuthMiddleware(api.MaintenanceHandler))
+	mux.HandleFunc("/api/maintenance/save", auth.AuthMiddleware(api.SaveMaintenanceHandler))
+	mux.HandleFunc("/api/maintenance/template", auth.AuthMiddleware(api.MaintenanceTemplateHandler))
+	mux.HandleFunc("/api/error-pages", auth.AuthMiddleware(api.ErrorPagesHandler))
+	mux.HandleFunc("/api/error-pages/save", auth.AuthMiddleware(api.SaveErrorPageHandler))
+	mux.HandleFunc("/api/error-pages/delete", auth.AuthMiddleware(api.DeleteErrorPageHandler))
+	mux.HandleFunc("/api/error-pages/starting", auth.AuthMiddleware(api.StartingPageHandler))
+	mux.HandleFunc("/api/domains", auth.AuthMiddleware(api.DomainsHandler))
+	mux.HandleFunc("/api/upstream-transport", auth.AuthMiddleware(api.UpstreamTransportHandler))
+	mux.HandleFunc("/api/upstream-transport/save", auth.AuthMiddleware(api.SaveUpstreamTransportHandler))
+	mux.HandleFunc("/api/upstream-transport/delete", auth.AuthMiddleware(api.DeleteUpstreamTransportHandler))
+	mux.HandleFunc("/api/php/installs", auth.AuthMiddleware(api.PHPInstallsHandler))
+	mux.HandleFunc("/api/php/installs/detect", auth.AuthMiddleware(api.DetectPHPInstallsHandler))
+	mux.HandleFunc("/api/php/installs/save", auth.AuthMiddleware(api.SavePHPInstallHandler))
+	mux.HandleFunc("/api/php/installs/delete", auth.AuthMiddleware(api.DeletePHPInstallHandler))
+	mux.HandleFunc("/api/php/pools", auth.AuthMiddleware(api.PHPPoolsHandler))
+	mux.HandleFunc("/api/php/pools/save", auth.AuthMiddleware(api.SavePHPPoolHandler))
+	mux.HandleFunc("/api/php/pools/delete", auth.AuthMiddleware(api.DeletePHPPoolHandler))
+	mux.HandleFunc("/api/php/pools/start", auth.AuthMiddleware(api.StartPHPPoolHandler))
+	mux.HandleFunc("/api/php/pools/stop", auth.AuthMiddleware(api.StopPHPPoolHandler))
 	mux.HandleFunc("/api/caddy/status", auth.AuthMiddleware(api.CaddyStatusHandler))
 	mux.HandleFunc("/api/caddy/start", auth.AuthMiddleware(api.CaddyStartHandler))
 	mux.HandleFunc("/api/caddy/stop", auth.AuthMiddleware(api.CaddyStopHandler))
 	mux.HandleFunc("/api/caddy/restart", auth.AuthMiddleware(api.CaddyRestartHandler))
 	mux.HandleFunc("/api/caddy/reload", auth.AuthMiddleware(api.CaddyReloadHandler))
+	mux.HandleFunc("/api/caddy/history", auth.AuthMiddleware(api.CaddyHistoryHandler))
+	mux.HandleFunc("/api/caddy/history/restore", auth.AuthMiddleware(api.CaddyHistoryRestoreHandler))
+	mux.HandleFunc("/api/caddy/import", auth.AuthMiddleware(api.ImportCaddyfileHandler))
 	mux.HandleFunc("/api/caddy/ssl-status", auth.AuthMiddleware(api.CaddySSLStatusHandler))
+	mux.HandleFunc("/api/certs", auth.AuthMiddleware(api.CertsHandler))
+	mux.HandleFunc("/api/certs/inventory", auth.AuthMiddleware(api.CertInventoryHandler))
+	mux.HandleFunc("/api/certs/upload", auth.AuthMiddleware(api.UploadCertHandler))
+	mux.HandleFunc("/api/certs/delete", auth.AuthMiddleware(api.DeleteCertHandler))
+	mux.HandleFunc("/api/dns-providers", auth.AuthMiddleware(api.DNSProvidersHandler))
+	mux.HandleFunc("/api/dns-providers/save", auth.AuthMiddleware(api.SaveDNSProviderHandler))
+	mux.HandleFunc("/api/dns-providers/delete", auth.AuthMiddleware(api.DeleteDNSProviderHandler))
+	mux.HandleFunc("/api/dns-providers/test", auth.AuthMiddleware(api.TestDNSProviderHandler))
+	mux.HandleFunc("/api/caddy/modules", auth.AuthMiddleware(api.CaddyModulesHandler))
 	mux.HandleFunc("/api/caddy/logs", auth.AuthMiddleware(api.CaddyLogsHandler))
 	mux.HandleFunc("/api/files/browse", auth.AuthMiddleware(api.BrowseFilesHandler))
 	mux.HandleFunc("/api/files/upload", auth.AuthMiddleware(api.UploadFileHandler))
@@ -110,6 +175,17 @@ func main() {
 	mux.HandleFunc("/api/projects/restart", auth.AuthMiddleware(api.RestartProjectHandler))
 	mux.HandleFunc("/api/projects/logs", auth.AuthMiddleware(api.GetProjectLogsHandler))
 	mux.HandleFunc("/api/projects/status", auth.AuthMiddleware(api.GetProjectStatusHandler))
+	mux.HandleFunc("/api/projects/stop-settings", auth.AuthMiddleware(api.StopSettingsHandler))
+	mux.HandleFunc("/api/projects/stop-settings/save", auth.AuthMiddleware(api.SaveStopSettingsHandler))
+	mux.HandleFunc("/api/projects/restart-policy", auth.AuthMiddleware(api.RestartPolicyHandler))
+	mux.HandleFunc("/api/projects/restart-policy/save", auth.AuthMiddleware(api.SaveRestartPolicyHandler))
+	mux.HandleFunc("/api/projects/events", auth.AuthMiddleware(api.ProjectEventsHandler))
+	mux.HandleFunc("/api/projects/env", auth.AuthMiddleware(api.ProjectEnvHandler))
+	mux.HandleFunc("/api/projects/env/save", auth.AuthMiddleware(api.SaveProjectEnvHandler))
+	mux.HandleFunc("/api/projects/env/preview", auth.AuthMiddleware(api.ProjectEnvPreviewHandler))
+	mux.HandleFunc("/api/secrets", auth.AuthMiddleware(api.SecretsHandler))
+	mux.HandleFunc("/api/secrets/save", auth.AuthMiddleware(api.SaveSecretHandler))
+	mux.HandleFunc("/api/secrets/delete", auth.AuthMiddleware(api.DeleteSecretHandler))
 	
 	// 任务管理
 	mux.HandleFunc("/api/tasks", auth.AuthMiddleware(api.TasksHandler))
@@ -193,6 +269,10 @@ func gracefulShutdown(server *http.Server) {
 	fmt.Println("停止所有项目...")
 	api.StopAllProjects()
 	
+	// 停止 PHP 进程池
+	fmt.Println("停止 PHP 进程池...")
+	api.StopPHPPools()
+	
 	// 关闭 HTTP 服务器
 	fmt.Println("关闭 HTTP 服务器...")
 	if err := server.Shutdown(ctx); err != nil {
@@ -206,21 +286,60 @@ func gracefulShutdown(server *http.Server) {
 	fmt.Println("✓ 服务已安全关闭")
 }
 
+// runImport 命令行导入现有配置并打印报告
+func runImport(path string, dryRun bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("读取配置文件失败: %v", err)
+	}
+
+	// 命令行导入后立即退出，只写入配置文件，由管理器或已运行的 Caddy 之后加载
+	report, err := api.ImportCaddyfile(data, dryRun, false)
+	if report == nil {
+		log.Fatalf("导入失败: %v", err)
+	}
+
+	if dryRun {
+		fmt.Println("🔍 预览模式，未写入数据库")
+	}
+	for _, item := range report.Sites {
+		fmt.Printf("✓ 站点 %s → %s (%s)\n", item.Host, item.Target, item.Type)
+	}
+	for _, item := range report.Projects {
+		fmt.Printf("✓ 项目 #%d %s 新增域名 %s\n", item.ID, item.Name, item.Host)
+	}
+	for _, item := range report.Skipped {
+		fmt.Printf("⏭️  跳过 %s: %s\n", item.Host, item.Reason)
+	}
+	for _, u := range report.Unmapped {
+		fmt.Printf("⚠️  未导入 [%s] 第 %d 行 %s: %s\n", u.Block, u.Line, u.Directive, u.Reason)
+	}
+	if err != nil {
+		log.Fatalf("数据已导入，但 Caddy 配置未能生成: %v", err)
+	}
+	if !dryRun && (len(report.Sites) > 0 || len(report.Projects) > 0) {
+		fmt.Println("✅ 已写入新的 Caddyfile，Caddy 重新加载或下次启动时生效")
+	}
+}
+
 func checkAdminPrivileges() {
 	if !system.IsAdmin() {
 		fmt.Println("============================================================")
-		fmt.Println("⚠️  警告: 未以管理员身份运行")
+		fmt.Printf("⚠️  警告: 未以%s身份运行\n", system.PrivilegeName)
 		fmt.Println("============================================================")
 		fmt.Println("")
-		fmt.Println("当前程序未以管理员权限运行，这可能导致以下问题：")
+		fmt.Printf("当前程序未以%s权限运行，这可能导致以下问题：\n", system.PrivilegeName)
 		fmt.Println("  • 无法绑定 80 和 443 端口")
 		fmt.Println("  • 无法自动申请 SSL 证书")
 		fmt.Println("  • 无法配置防火墙规则")
 		fmt.Println("")
 		fmt.Println("建议操作：")
 		fmt.Println("  1. 关闭本程序")
-		fmt.Println("  2. 右键程序图标 → 选择'以管理员身份运行'")
-		fmt.Println("  3. 或在 Web 界面的诊断页面查看详细说明")
+		suggestions := system.PrivilegeSuggestions()
+		for i, suggestion := range suggestions {
+			fmt.Printf("  %d. %s\n", i+2, suggestion)
+		}
+		fmt.Printf("  %d. 或在 Web 界面的诊断页面查看详细说明\n", len(suggestions)+2)
 		fmt.Println("")
 		fmt.Println("如果只使用非标准端口（如 8080）可以忽略此警告")
 		fmt.Println("============================================================")
@@ -231,7 +350,7 @@ func checkAdminPrivileges() {
 		fmt.Scanln()
 		fmt.Println("")
 	} else {
-		fmt.Println("✓ 已以管理员权限运行")
+		fmt.Printf("✓ 已以%s权限运行\n", system.PrivilegeName)
 	}
 }
 